@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// field is one indexed struct field, parsed as redisft's parseSchema does.
+type field struct {
+	goName   string
+	hashName string
+	typ      string // TEXT, NUMERIC, TAG or GEO
+	sortable bool
+}
+
+type structInfo struct {
+	name   string
+	fields []field
+}
+
+// handleTypes maps an index type to its redisft handle types.
+var handleTypes = map[string][2]string{
+	"TEXT":    {"TextField", "SortableTextField"},
+	"NUMERIC": {"NumericField", "SortableNumericField"},
+	"TAG":     {"TagField", "SortableTagField"},
+	"GEO":     {"GeoField", "GeoField"},
+}
+
+// parseDir reads the non-test Go files of dir, skipping skip (the output
+// file), and returns the package name and the requested structs. With no
+// names, every struct with at least one indexed field is returned.
+func parseDir(dir string, names []string, skip string) (string, []structInfo, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && filepath.Join(dir, fi.Name()) != filepath.Clean(skip)
+	}, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(pkgs) != 1 {
+		return "", nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+	var pkgName string
+	found := map[string]structInfo{}
+	for name, pkg := range pkgs {
+		pkgName = name
+		for _, f := range pkg.Files {
+			for _, decl := range f.Decls {
+				gd, ok := decl.(*ast.GenDecl)
+				if !ok || gd.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gd.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if st, ok := ts.Type.(*ast.StructType); ok {
+						s, err := parseStruct(ts.Name.Name, st)
+						if err != nil {
+							return "", nil, err
+						}
+						found[s.name] = s
+					}
+				}
+			}
+		}
+	}
+
+	var out []structInfo
+	if len(names) == 0 {
+		for _, s := range found {
+			if len(s.fields) > 0 {
+				out = append(out, s)
+			}
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+		if len(out) == 0 {
+			return "", nil, fmt.Errorf("no structs with indexed fields in %s", dir)
+		}
+		return pkgName, out, nil
+	}
+	for _, n := range names {
+		s, ok := found[strings.TrimSpace(n)]
+		if !ok {
+			return "", nil, fmt.Errorf("struct %s not found in %s", n, dir)
+		}
+		if len(s.fields) == 0 {
+			return "", nil, fmt.Errorf("struct %s has no indexed fields", n)
+		}
+		out = append(out, s)
+	}
+	return pkgName, out, nil
+}
+
+func parseStruct(name string, st *ast.StructType) (structInfo, error) {
+	s := structInfo{name: name}
+	for _, f := range st.Fields.List {
+		if f.Tag == nil {
+			continue
+		}
+		tag, err := strconv.Unquote(f.Tag.Value)
+		if err != nil {
+			return s, err
+		}
+		fd := field{}
+		for _, tok := range strings.Fields(reflect.StructTag(tag).Get("redis")) {
+			switch tok = strings.ToUpper(tok); tok {
+			case "SORTABLE":
+				fd.sortable = true
+			case "TEXT", "NUMERIC", "TAG", "GEO":
+				fd.typ = tok
+			}
+		}
+		if fd.typ == "" {
+			continue
+		}
+		for _, id := range f.Names {
+			if !id.IsExported() {
+				continue
+			}
+			fd.goName, fd.hashName = id.Name, strings.ToLower(id.Name)
+			s.fields = append(s.fields, fd)
+		}
+	}
+	return s, nil
+}
+
+// generate renders the handles of structs as a gofmt'ed file of package pkg.
+func generate(pkg string, structs []structInfo) ([]byte, error) {
+	qual := "redisft."
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by redisft-gen. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if pkg == "redisft" {
+		qual = ""
+	} else {
+		b.WriteString("import \"github.com/bariscan97/redis-ftsearch/redisft\"\n")
+	}
+	for _, s := range structs {
+		fmt.Fprintf(&b, "\n// %sFields holds typed handles for the indexed fields of %s.\n", s.name, s.name)
+		fmt.Fprintf(&b, "var %sFields = struct {\n", s.name)
+		for _, f := range s.fields {
+			fmt.Fprintf(&b, "%s %s%s\n", f.goName, qual, handleType(f))
+		}
+		b.WriteString("}{\n")
+		for _, f := range s.fields {
+			plain := handleTypes[f.typ][0]
+			lit := fmt.Sprintf("%s%s{Name: %q}", qual, plain, f.hashName)
+			if t := handleType(f); t != plain {
+				lit = fmt.Sprintf("%s%s{%s: %s}", qual, t, plain, lit)
+			}
+			fmt.Fprintf(&b, "%s: %s,\n", f.goName, lit)
+		}
+		b.WriteString("}\n")
+	}
+	return format.Source(b.Bytes())
+}
+
+func handleType(f field) string {
+	if f.sortable {
+		return handleTypes[f.typ][1]
+	}
+	return handleTypes[f.typ][0]
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const src = `package store
+
+import "time"
+
+type Product struct {
+	ID        string    ` + "`redis:\"text sortable\"`" + `
+	Name      string    ` + "`redis:\"text\"`" + `
+	Price     float64   ` + "`redis:\"numeric sortable\"`" + `
+	Color     string    ` + "`redis:\"tag\"`" + `
+	Location  string    ` + "`redis:\"geo\"`" + `
+	ExpiresAt time.Time ` + "`redis:\"expire\"`" + `
+	secret    string    ` + "`redis:\"tag\"`" + `
+}
+
+type plain struct{ N int }
+`
+
+const want = `// Code generated by redisft-gen. DO NOT EDIT.
+
+package store
+
+import "github.com/bariscan97/redis-ftsearch/redisft"
+
+// ProductFields holds typed handles for the indexed fields of Product.
+var ProductFields = struct {
+	ID       redisft.SortableTextField
+	Name     redisft.TextField
+	Price    redisft.SortableNumericField
+	Color    redisft.TagField
+	Location redisft.GeoField
+}{
+	ID:       redisft.SortableTextField{TextField: redisft.TextField{Name: "id"}},
+	Name:     redisft.TextField{Name: "name"},
+	Price:    redisft.SortableNumericField{NumericField: redisft.NumericField{Name: "price"}},
+	Color:    redisft.TagField{Name: "color"},
+	Location: redisft.GeoField{Name: "location"},
+}
+`
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "store.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "product_fields.go")
+	// A stale output file must not be parsed.
+	if err := os.WriteFile(out, []byte("package store\n\nvar ProductFields = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, names := range [][]string{nil, {"Product"}} {
+		pkg, structs, err := parseDir(dir, names, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := generate(pkg, structs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("types %v: generated\n%s\nwant\n%s", names, got, want)
+		}
+	}
+
+	if _, _, err := parseDir(dir, []string{"plain"}, out); err == nil {
+		t.Error("struct without indexed fields: want error")
+	}
+	if _, _, err := parseDir(dir, []string{"Missing"}, out); err == nil {
+		t.Error("missing struct: want error")
+	}
+}
@@ -0,0 +1,69 @@
+// Command redisft-gen generates typed field handles for structs with
+// `redis` tags, so queries refer to fields by Go identifier instead of by
+// string:
+//
+//	//go:generate go run github.com/bariscan97/redis-ftsearch/cmd/redisft-gen -type Product
+//
+// produces product_fields.go with
+//
+//	var ProductFields = struct {
+//		Price redisft.SortableNumericField
+//		Color redisft.TagField
+//		...
+//	}{...}
+//
+// Each handle only offers the builders valid for the field's index type,
+// e.g. ProductFields.Price.Between(1, 10), ProductFields.Color.Any("red")
+// and ProductFields.Price.SortAsc().
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("redisft-gen: ")
+	var (
+		types  = flag.String("type", "", "comma-separated struct names; default all structs with indexed fields")
+		output = flag.String("output", "", "output file; default <type>_fields.go or redisft_fields.go")
+		dir    = flag.String("dir", ".", "package directory")
+	)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: redisft-gen [-type T,...] [-output file] [-dir dir]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var names []string
+	if *types != "" {
+		names = strings.Split(*types, ",")
+	}
+	out := *output
+	if out == "" {
+		out = "redisft_fields.go"
+		if len(names) == 1 {
+			out = strings.ToLower(names[0]) + "_fields.go"
+		}
+	}
+	if !filepath.IsAbs(out) {
+		out = filepath.Join(*dir, out)
+	}
+
+	pkg, structs, err := parseDir(*dir, names, out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(pkg, structs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func (c *cli) listIndexes(ctx context.Context) error {
+	names, err := c.rc.Do(ctx, "FT._LIST").StringSlice()
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	if c.out.json {
+		return c.out.JSON(names)
+	}
+	rows := make([][]string, len(names))
+	for i, n := range names {
+		rows[i] = []string{n}
+	}
+	return c.out.Table([]string{"INDEX"}, rows)
+}
+
+func (c *cli) index(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return usageErr("index info|drop|alias ...")
+	}
+	switch args[0] {
+	case "info":
+		if len(args) != 2 {
+			return usageErr("index info <index>")
+		}
+		return c.indexInfo(ctx, args[1])
+	case "drop":
+		fs := flag.NewFlagSet("index drop", flag.ContinueOnError)
+		dd := fs.Bool("dd", false, "also delete the documents")
+		pos, err := parseArgs(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(pos) != 1 {
+			return usageErr("index drop [-dd] <index>")
+		}
+		cmd := []any{"FT.DROPINDEX", pos[0]}
+		if *dd {
+			cmd = append(cmd, "DD")
+		}
+		if err := c.rc.Do(ctx, cmd...).Err(); err != nil {
+			return err
+		}
+		return c.out.Status("dropped " + pos[0])
+	case "alias":
+		return c.alias(ctx, args[1:])
+	}
+	return usageErr("unknown index subcommand %q", args[0])
+}
+
+func (c *cli) alias(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return usageErr("index alias add|update|del <alias> [index]")
+	}
+	var cmd []any
+	switch {
+	case (args[0] == "add" || args[0] == "update") && len(args) == 3:
+		cmd = []any{"FT.ALIAS" + strings.ToUpper(args[0]), args[1], args[2]}
+	case args[0] == "del" && len(args) == 2:
+		cmd = []any{"FT.ALIASDEL", args[1]}
+	default:
+		return usageErr("index alias add|update <alias> <index> | index alias del <alias>")
+	}
+	if err := c.rc.Do(ctx, cmd...).Err(); err != nil {
+		return err
+	}
+	return c.out.Status("OK")
+}
+
+// indexInfo is the part of FT.INFO the CLI shows.
+type indexInfo struct {
+	Name       string      `json:"name"`
+	Prefixes   []string    `json:"prefixes"`
+	NumDocs    int64       `json:"num_docs"`
+	Attributes []attribute `json:"attributes"`
+}
+
+type attribute struct {
+	Identifier string `json:"identifier"`
+	Attribute  string `json:"attribute"`
+	Type       string `json:"type"`
+	Sortable   bool   `json:"sortable"`
+}
+
+func (c *cli) fetchInfo(ctx context.Context, index string) (*indexInfo, error) {
+	raw, err := c.rc.Do(ctx, "FT.INFO", index).Slice()
+	if err != nil {
+		return nil, err
+	}
+	info := &indexInfo{}
+	for i := 0; i+1 < len(raw); i += 2 {
+		key, _ := raw[i].(string)
+		switch key {
+		case "index_name":
+			info.Name = fmt.Sprint(raw[i+1])
+		case "num_docs":
+			info.NumDocs, _ = strconv.ParseInt(fmt.Sprint(raw[i+1]), 10, 64)
+		case "index_definition":
+			def, _ := raw[i+1].([]any)
+			for j := 0; j+1 < len(def); j += 2 {
+				if def[j] == "prefixes" {
+					ps, _ := def[j+1].([]any)
+					for _, p := range ps {
+						info.Prefixes = append(info.Prefixes, fmt.Sprint(p))
+					}
+				}
+			}
+		case "attributes":
+			attrs, _ := raw[i+1].([]any)
+			for _, a := range attrs {
+				info.Attributes = append(info.Attributes, parseAttribute(a))
+			}
+		}
+	}
+	return info, nil
+}
+
+func parseAttribute(raw any) attribute {
+	var a attribute
+	kv, _ := raw.([]any)
+	for j := 0; j < len(kv); j++ {
+		switch s := fmt.Sprint(kv[j]); s {
+		case "identifier", "attribute", "type":
+			if j+1 < len(kv) {
+				v := fmt.Sprint(kv[j+1])
+				switch s {
+				case "identifier":
+					a.Identifier = v
+				case "attribute":
+					a.Attribute = v
+				default:
+					a.Type = v
+				}
+				j++
+			}
+		case "SORTABLE":
+			a.Sortable = true
+		}
+	}
+	return a
+}
+
+func (c *cli) indexInfo(ctx context.Context, index string) error {
+	info, err := c.fetchInfo(ctx, index)
+	if err != nil {
+		return err
+	}
+	if c.out.json {
+		return c.out.JSON(info)
+	}
+	c.out.Printf("index:    %s\nprefixes: %s\ndocs:     %d\n\n", info.Name, strings.Join(info.Prefixes, ", "), info.NumDocs)
+	rows := make([][]string, len(info.Attributes))
+	for i, a := range info.Attributes {
+		sortable := ""
+		if a.Sortable {
+			sortable = "yes"
+		}
+		rows[i] = []string{a.Attribute, a.Identifier, a.Type, sortable}
+	}
+	return c.out.Table([]string{"ATTRIBUTE", "FIELD", "TYPE", "SORTABLE"}, rows)
+}
+
+func (c *cli) search(ctx context.Context, args []string) error {
+	var q searchFlags
+	fs := q.flagSet("search")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) < 1 || len(pos) > 2 {
+		return usageErr("search <index> [query] [search flags]")
+	}
+	cmd, err := q.command(pos[0], strings.Join(pos[1:], " "))
+	if err != nil {
+		return err
+	}
+	raw, err := c.rc.Do(ctx, cmd...).Slice()
+	if err != nil {
+		return err
+	}
+	total, docs := parseSearch(raw, q.ids)
+	return c.out.Records(total, docs, q.ret)
+}
+
+func (c *cli) explain(ctx context.Context, args []string) error {
+	var q searchFlags
+	fs := q.flagSet("explain")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) < 1 || len(pos) > 2 {
+		return usageErr("explain <index> [query] [search flags]")
+	}
+	query, err := q.query(strings.Join(pos[1:], " "))
+	if err != nil {
+		return err
+	}
+	plan, err := c.rc.Do(ctx, "FT.EXPLAIN", pos[0], query).Text()
+	if err != nil {
+		return err
+	}
+	if c.out.json {
+		return c.out.JSON(map[string]string{"query": query, "plan": plan})
+	}
+	c.out.Printf("%s\n", strings.TrimRight(plan, "\n"))
+	return nil
+}
+
+// aggregate passes everything after the query to FT.AGGREGATE verbatim.
+func (c *cli) aggregate(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return usageErr("aggregate <index> <query> [pipeline args...]")
+	}
+	cmd := []any{"FT.AGGREGATE", args[0], args[1]}
+	for _, a := range args[2:] {
+		cmd = append(cmd, a)
+	}
+	raw, err := c.rc.Do(ctx, cmd...).Slice()
+	if err != nil {
+		return err
+	}
+	var total int64
+	var rows []record
+	if len(raw) > 0 {
+		total, _ = raw[0].(int64)
+		for _, r := range raw[1:] {
+			rows = append(rows, record{Fields: pairs(r)})
+		}
+	}
+	return c.out.Records(total, rows, "")
+}
+
+func (c *cli) suggest(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("suggest", flag.ContinueOnError)
+	fuzzy := fs.Bool("fuzzy", false, "allow one edit in the prefix")
+	max := fs.Int("max", 5, "maximum number of suggestions")
+	scores := fs.Bool("scores", false, "show scores")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageErr("suggest [-fuzzy] [-max n] [-scores] <key> <prefix>")
+	}
+	cmd := []any{"FT.SUGGET", pos[0], pos[1], "MAX", *max}
+	if *fuzzy {
+		cmd = append(cmd, "FUZZY")
+	}
+	if *scores {
+		cmd = append(cmd, "WITHSCORES")
+	}
+	raw, err := c.rc.Do(ctx, cmd...).StringSlice()
+	if err != nil {
+		return err
+	}
+	type suggestion struct {
+		Text  string  `json:"text"`
+		Score float64 `json:"score,omitempty"`
+	}
+	var out []suggestion
+	for i := 0; i < len(raw); i++ {
+		s := suggestion{Text: raw[i]}
+		if *scores && i+1 < len(raw) {
+			s.Score, _ = strconv.ParseFloat(raw[i+1], 64)
+			i++
+		}
+		out = append(out, s)
+	}
+	if c.out.json {
+		return c.out.JSON(out)
+	}
+	header := []string{"SUGGESTION"}
+	if *scores {
+		header = append(header, "SCORE")
+	}
+	rows := make([][]string, len(out))
+	for i, s := range out {
+		rows[i] = []string{s.Text}
+		if *scores {
+			rows[i] = append(rows[i], strconv.FormatFloat(s.Score, 'g', -1, 64))
+		}
+	}
+	return c.out.Table(header, rows)
+}
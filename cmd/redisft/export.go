@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bariscan97/redis-ftsearch/redisft"
+)
+
+// export writes every hash under the index prefixes, one SCAN batch at a
+// time. JSONL lines are {"id", "doc"} objects whose doc maps each hash field
+// to its value as stored, always a string, so they are not the typed JSONL
+// of Repository.Export. CSV has an "_id" column followed by the indexed
+// fields, values as stored, which is the CSV that Repository.Import reads;
+// fields outside the index are not written.
+func (c *cli) export(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "jsonl", "jsonl or csv")
+	out := fs.String("out", "", "output file (default stdout)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageErr("export [-format jsonl|csv] [-out file] <index>")
+	}
+	if *format != "jsonl" && *format != "csv" {
+		return usageErr("export: unknown format %q", *format)
+	}
+	info, err := c.fetchInfo(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+
+	w := c.out.w
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var write func(id string, m map[string]string) error
+	flush := func() error { return nil }
+	if *format == "jsonl" {
+		enc := json.NewEncoder(w)
+		write = func(id string, m map[string]string) error {
+			return enc.Encode(map[string]any{"id": id, "doc": m})
+		}
+	} else {
+		cw := csv.NewWriter(w)
+		cols := make([]string, 0, len(info.Attributes))
+		for _, a := range info.Attributes {
+			cols = append(cols, a.Identifier)
+		}
+		sort.Strings(cols)
+		if err := cw.Write(append([]string{"_id"}, cols...)); err != nil {
+			return err
+		}
+		row := make([]string, len(cols)+1)
+		write = func(id string, m map[string]string) error {
+			row[0] = id
+			for i, f := range cols {
+				row[i+1] = m[f]
+			}
+			return cw.Write(row)
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	}
+
+	n := 0
+	ft := redisft.NewClientFromPool(&redisft.UniversalPool{Client: c.rc})
+	for _, prefix := range info.Prefixes {
+		err := ft.ScanHashes(ctx, prefix, func(keys []string, hashes []map[string]string) error {
+			for i, m := range hashes {
+				if err := write(strings.TrimPrefix(keys[i], prefix), m); err != nil {
+					return err
+				}
+				n++
+			}
+			return flush()
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if *out != "" {
+		c.out.Printf("exported %d documents to %s\n", n, *out)
+	}
+	return nil
+}
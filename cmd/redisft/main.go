@@ -0,0 +1,187 @@
+// Command redisft administers RediSearch indexes and runs ad-hoc searches.
+//
+//	redisft [global flags] <command> [flags] [args]
+//
+// Commands:
+//
+//	indexes list
+//	index info <index>
+//	index drop [-dd] <index>
+//	index alias add|update|del <alias> [index]
+//	search <index> [query] [--text f=terms] [--tag f=a,b] [--range f=lo:hi]
+//	       [--near f=lon,lat,5km] [--sort f:asc] [--limit off:n] [--return f,g] [--ids]
+//	aggregate <index> <query> [pipeline args...]
+//	explain <index> [query] [search flags]
+//	suggest [-fuzzy] [-max n] [-scores] <key> <prefix>
+//	export [-format jsonl|csv] [-out file] <index>
+//
+// Global flags select the server (-addr, -password, -db, -cluster) and the
+// output format (-json; tables otherwise). REDISFT_ADDR and REDISFT_PASSWORD
+// provide defaults for -addr and -password.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// errUsage marks command-line mistakes; main prints the usage and exits 2.
+var errUsage = errors.New("usage")
+
+func usageErr(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errUsage, fmt.Sprintf(format, args...))
+}
+
+const usage = `usage: redisft [global flags] <command> [flags] [args]
+
+commands:
+  indexes list
+  index info <index>
+  index drop [-dd] <index>
+  index alias add|update|del <alias> [index]
+  search <index> [query] [search flags]
+  aggregate <index> <query> [pipeline args...]
+  explain <index> [query] [search flags]
+  suggest [-fuzzy] [-max n] [-scores] <key> <prefix>
+  export [-format jsonl|csv] [-out file] <index>
+
+search flags (repeatable, combined with AND):
+  --text field=terms      all terms in a TEXT field
+  --tag field=a,b         any of the tags
+  --range field=lo:hi     numeric range, inclusive; empty or -inf/+inf for open ends
+  --near field=lon,lat,5km  GEO radius (units m, km, mi, ft)
+  --sort field[:asc|desc]
+  --limit [off:]n
+  --return field,field
+  --ids                   document IDs only
+
+global flags:
+`
+
+// config holds the global flags.
+type config struct {
+	addr     string
+	password string
+	db       int
+	cluster  bool
+	json     bool
+	timeout  time.Duration
+}
+
+func (c config) dial() redis.UniversalClient {
+	if c.cluster {
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    strings.Split(c.addr, ","),
+			Password: c.password,
+		})
+	}
+	return redis.NewClient(&redis.Options{Addr: c.addr, Password: c.password, DB: c.db})
+}
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdout, os.Stderr, nil)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errUsage):
+		fmt.Fprintln(os.Stderr, "redisft:", err)
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "redisft:", err)
+		os.Exit(1)
+	}
+}
+
+// run executes one command line. dial, if non-nil, replaces the connection
+// built from the global flags.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer, dial func(config) redis.UniversalClient) error {
+	fs := flag.NewFlagSet("redisft", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var cfg config
+	fs.StringVar(&cfg.addr, "addr", envOr("REDISFT_ADDR", "localhost:6379"), "server address; comma-separated seeds with -cluster")
+	fs.StringVar(&cfg.password, "password", os.Getenv("REDISFT_PASSWORD"), "password")
+	fs.IntVar(&cfg.db, "db", 0, "database number")
+	fs.BoolVar(&cfg.cluster, "cluster", false, "connect to a Redis Cluster")
+	fs.BoolVar(&cfg.json, "json", false, "print JSON instead of tables")
+	fs.DurationVar(&cfg.timeout, "timeout", 30*time.Second, "overall command timeout")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return usageErr("no command")
+	}
+
+	if dial == nil {
+		dial = config.dial
+	}
+	rc := dial(cfg)
+	defer rc.Close()
+	ctx, cancel := context.WithTimeout(ctx, cfg.timeout)
+	defer cancel()
+
+	c := &cli{rc: rc, out: newPrinter(stdout, cfg.json), stderr: stderr}
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "indexes":
+		if len(rest) != 1 || rest[0] != "list" {
+			return usageErr("indexes list")
+		}
+		return c.listIndexes(ctx)
+	case "index":
+		return c.index(ctx, rest)
+	case "search":
+		return c.search(ctx, rest)
+	case "aggregate":
+		return c.aggregate(ctx, rest)
+	case "explain":
+		return c.explain(ctx, rest)
+	case "suggest":
+		return c.suggest(ctx, rest)
+	case "export":
+		return c.export(ctx, rest)
+	}
+	return usageErr("unknown command %q", cmd)
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// cli carries the connection and output of one invocation.
+type cli struct {
+	rc     redis.UniversalClient
+	out    *printer
+	stderr io.Writer
+}
+
+// parseArgs parses flags interleaved with positional arguments, which the
+// flag package alone stops at, and returns the positional ones.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, usageErr("%s: %v", fs.Name(), err)
+		}
+		if fs.NArg() == 0 {
+			return pos, nil
+		}
+		pos = append(pos, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
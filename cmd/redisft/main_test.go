@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bariscan97/redis-ftsearch/redisft"
+	"github.com/bariscan97/redis-ftsearch/redisft/redisfttest"
+	"github.com/go-redis/redis/v8"
+)
+
+type Product struct {
+	ID       string  `redis:"text sortable"`
+	Name     string  `redis:"text"`
+	Price    float64 `redis:"numeric sortable"`
+	Location string  `redis:"geo"`
+	Color    string  `redis:"tag"`
+}
+
+func newServer(t *testing.T) *redisfttest.Server {
+	t.Helper()
+	srv := redisfttest.NewServer()
+	cli := srv.Client()
+	t.Cleanup(func() { cli.Close() })
+	ctx := context.Background()
+	repo := redisft.NewRepo[Product](cli)
+	if err := repo.CreateIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	err := repo.InsertMany(ctx, map[string]*Product{
+		"1": {ID: "1", Name: "Warcraft strategy guide", Price: 19.9, Location: "29.0,41.0", Color: "red"},
+		"2": {ID: "2", Name: "Go programming", Price: 45, Location: "29.1,41.0", Color: "blue"},
+		"3": {ID: "3", Name: "Redis in action", Price: 60, Location: "-122.4,37.7", Color: "red,green"},
+		"4": {ID: "4", Name: "Starcraft tutorial", Price: 120, Location: "28.9,41.1", Color: "green"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func runCLI(t *testing.T, srv *redisfttest.Server, args ...string) (string, error) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	err := run(context.Background(), args, &out, &errOut, func(config) redis.UniversalClient { return srv.Dial() })
+	return out.String(), err
+}
+
+func TestCLI(t *testing.T) {
+	srv := newServer(t)
+
+	out, err := runCLI(t, srv, "indexes", "list")
+	if err != nil || !strings.Contains(out, "idx:product") {
+		t.Fatalf("indexes list = %q, %v", out, err)
+	}
+
+	out, err = runCLI(t, srv, "-json", "index", "info", "idx:product")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info indexInfo
+	if err := json.Unmarshal([]byte(out), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.NumDocs != 4 || !reflect.DeepEqual(info.Prefixes, []string{"product:"}) || len(info.Attributes) != 5 {
+		t.Fatalf("index info = %+v", info)
+	}
+
+	out, err = runCLI(t, srv, "-json", "search", "idx:product",
+		"--tag", "color=red,green", "--range", "price=:100", "--sort", "price:desc", "--return", "name,price")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Total   int64    `json:"total"`
+		Results []record `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range res.Results {
+		got = append(got, r.ID)
+	}
+	if res.Total != 2 || !reflect.DeepEqual(got, []string{"product:3", "product:1"}) {
+		t.Fatalf("search = %+v", res)
+	}
+	if _, ok := res.Results[0].Fields["color"]; ok || res.Results[0].Fields["name"] != "Redis in action" {
+		t.Fatalf("RETURN not applied: %v", res.Results[0].Fields)
+	}
+
+	out, err = runCLI(t, srv, "search", "idx:product", "--near", "location=29,41,20km", "--text", "name=starcraft", "--return", "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ID         NAME\nproduct:4  Starcraft tutorial\n(1 of 1)\n"; out != want {
+		t.Fatalf("search table = %q, want %q", out, want)
+	}
+
+	out, err = runCLI(t, srv, "-json", "aggregate", "idx:product", "*", "GROUPBY", "1", "@color", "REDUCE", "COUNT", "0", "AS", "n")
+	if err != nil || !strings.Contains(out, `"color": "blue"`) {
+		t.Fatalf("aggregate = %q, %v", out, err)
+	}
+
+	if err := srv.Dial().Do(context.Background(), "FT.SUGADD", "sug", "starcraft", 2).Err(); err != nil {
+		t.Fatal(err)
+	}
+	out, err = runCLI(t, srv, "suggest", "-fuzzy", "sug", "stsr")
+	if err != nil || out != "SUGGESTION\nstarcraft\n" {
+		t.Fatalf("suggest = %q, %v", out, err)
+	}
+
+	out, err = runCLI(t, srv, "export", "-format", "csv", "idx:product")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 || rows[0][0] != "_id" || rows[1][0] != "1" {
+		t.Fatalf("export = %q", out)
+	}
+	dst := redisfttest.NewServer().Client()
+	defer dst.Close()
+	imported := redisft.NewRepo[Product](dst)
+	if res, err := imported.Import(context.Background(), strings.NewReader(out), redisft.CSV, redisft.BulkOptions{}); err != nil || res.Written != 4 {
+		t.Fatalf("Import of CSV export = %+v, %v", res, err)
+	}
+	if p, err := imported.Get(context.Background(), "3"); err != nil || p.Price != 60 || p.Color != "red,green" {
+		t.Fatalf("imported product = %+v, %v", p, err)
+	}
+
+	if _, err := runCLI(t, srv, "index", "alias", "add", "products", "idx:product"); err != nil {
+		t.Fatal(err)
+	}
+	out, err = runCLI(t, srv, "search", "products", "--ids", "--limit", "1")
+	if err != nil || out != "ID\nproduct:1\n(1 of 4)\n" {
+		t.Fatalf("search via alias = %q, %v", out, err)
+	}
+
+	if _, err := runCLI(t, srv, "index", "drop", "idx:product"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCLI(t, srv, "search", "products"); err == nil {
+		t.Fatal("search after drop succeeded")
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	t.Parallel()
+	srv := redisfttest.NewServer()
+	for _, args := range [][]string{
+		nil,
+		{"bogus"},
+		{"index"},
+		{"search"},
+		{"search", "idx", "--range", "price=a:b"},
+		{"search", "idx", "--sort", "price:up"},
+		{"suggest", "key"},
+	} {
+		if _, err := runCLI(t, srv, args...); !errors.Is(err, errUsage) {
+			t.Errorf("%q: err = %v, want usage error", args, err)
+		}
+	}
+}
+
+func TestSearchQuery(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "*"},
+		{[]string{"hello"}, "hello"},
+		{[]string{"--tag", "color=red,blue"}, "@color:{red|blue}"},
+		{[]string{"--range", "price=10:50"}, "@price:[10 50]"},
+		{[]string{"--range", "price=10:"}, "@price:[10 +inf]"},
+		{[]string{"--range", "price=-inf:5"}, "@price:[-inf 5]"},
+		{[]string{"--near", "loc=1.5,2,300m"}, "@loc:[1.500000 2.000000 300.0000 m]"},
+		{[]string{"--near", "loc=1,2,5"}, "@loc:[1.000000 2.000000 5.0000 km]"},
+		{[]string{"--tag", "c=x", "--range", "p=1:2", "raw"}, "@c:{x} @p:[1 2] (raw)"},
+		{[]string{"--tag", "c=x", "a | b"}, "@c:{x} (a | b)"},
+		{[]string{"a | b"}, "a | b"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			t.Parallel()
+			var q searchFlags
+			pos, err := parseArgs(q.flagSet("search"), tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := q.query(strings.Join(pos, " "))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("query = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
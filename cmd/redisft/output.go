@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer writes results as aligned tables or, with -json, as indented JSON.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func newPrinter(w io.Writer, json bool) *printer { return &printer{w: w, json: json} }
+
+func (p *printer) Printf(format string, args ...any) { fmt.Fprintf(p.w, format, args...) }
+
+func (p *printer) JSON(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (p *printer) Table(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, r := range rows {
+		fmt.Fprintln(tw, strings.Join(r, "\t"))
+	}
+	return tw.Flush()
+}
+
+// Status reports the outcome of a command without a result set.
+func (p *printer) Status(msg string) error {
+	if p.json {
+		return p.JSON(map[string]string{"status": msg})
+	}
+	p.Printf("%s\n", msg)
+	return nil
+}
+
+// Records prints search hits or aggregate rows. columns fixes the field
+// columns of the table; otherwise they are the sorted union of all fields.
+func (p *printer) Records(total int64, recs []record, columns string) error {
+	if p.json {
+		if recs == nil {
+			recs = []record{}
+		}
+		return p.JSON(struct {
+			Total   int64    `json:"total"`
+			Results []record `json:"results"`
+		}{total, recs})
+	}
+	var cols []string
+	if columns != "" {
+		cols = strings.Split(columns, ",")
+	} else {
+		seen := map[string]bool{}
+		for _, r := range recs {
+			for f := range r.Fields {
+				if !seen[f] {
+					seen[f] = true
+					cols = append(cols, f)
+				}
+			}
+		}
+		sort.Strings(cols)
+	}
+	withID := len(recs) > 0 && recs[0].ID != ""
+	var header []string
+	if withID {
+		header = append(header, "ID")
+	}
+	for _, c := range cols {
+		header = append(header, strings.ToUpper(c))
+	}
+	rows := make([][]string, len(recs))
+	for i, r := range recs {
+		if withID {
+			rows[i] = append(rows[i], r.ID)
+		}
+		for _, c := range cols {
+			rows[i] = append(rows[i], r.Fields[c])
+		}
+	}
+	if len(header) > 0 {
+		if err := p.Table(header, rows); err != nil {
+			return err
+		}
+	}
+	p.Printf("(%d of %d)\n", len(recs), total)
+	return nil
+}
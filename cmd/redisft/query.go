@@ -0,0 +1,234 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bariscan97/redis-ftsearch/redisft"
+)
+
+// multiFlag collects every occurrence of a repeatable flag.
+type multiFlag []string
+
+func (m *multiFlag) String() string     { return strings.Join(*m, " ") }
+func (m *multiFlag) Set(v string) error { *m = append(*m, v); return nil }
+
+// searchFlags mirror the redisft builders on the command line.
+type searchFlags struct {
+	text, tag, rng, near multiFlag
+	sort, limit, ret     string
+	ids                  bool
+}
+
+func (q *searchFlags) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Var(&q.text, "text", "field=terms")
+	fs.Var(&q.tag, "tag", "field=a,b")
+	fs.Var(&q.rng, "range", "field=lo:hi")
+	fs.Var(&q.near, "near", "field=lon,lat,radius")
+	fs.StringVar(&q.sort, "sort", "", "field[:asc|desc]")
+	fs.StringVar(&q.limit, "limit", "", "[off:]n")
+	fs.StringVar(&q.ret, "return", "", "field,field")
+	fs.BoolVar(&q.ids, "ids", false, "document IDs only")
+	return fs
+}
+
+// query combines the builder flags and the raw query with AND.
+func (q *searchFlags) query(raw string) (string, error) {
+	var parts []string
+	add := func(b redisft.Builder) { parts = append(parts, b.Build()) }
+	for _, v := range q.text {
+		field, terms, err := splitFlag("text", v)
+		if err != nil {
+			return "", err
+		}
+		add(redisft.NewTextQuery(field).All(strings.Fields(terms)...))
+	}
+	for _, v := range q.tag {
+		field, tags, err := splitFlag("tag", v)
+		if err != nil {
+			return "", err
+		}
+		add(redisft.NewTagQB(field).Any(strings.Split(tags, ",")...))
+	}
+	for _, v := range q.rng {
+		field, spec, err := splitFlag("range", v)
+		if err != nil {
+			return "", err
+		}
+		lo, hi, err := parseRange(spec)
+		if err != nil {
+			return "", err
+		}
+		add(redisft.NewNumericQuery(field).Between(lo, hi))
+	}
+	for _, v := range q.near {
+		field, spec, err := splitFlag("near", v)
+		if err != nil {
+			return "", err
+		}
+		g, err := parseNear(field, spec)
+		if err != nil {
+			return "", err
+		}
+		add(g)
+	}
+	if raw = strings.TrimSpace(raw); raw != "" {
+		if len(parts) > 0 {
+			// Keep a union in raw from swallowing the clauses before it.
+			raw = "(" + raw + ")"
+		}
+		parts = append(parts, raw)
+	}
+	if len(parts) == 0 {
+		return "*", nil
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// command builds the FT.SEARCH command.
+func (q *searchFlags) command(index, raw string) ([]any, error) {
+	query, err := q.query(raw)
+	if err != nil {
+		return nil, err
+	}
+	cmd := []any{"FT.SEARCH", index, query}
+	if q.ids {
+		cmd = append(cmd, "NOCONTENT")
+	}
+	if q.ret != "" {
+		fields := strings.Split(q.ret, ",")
+		cmd = append(cmd, "RETURN", len(fields))
+		for _, f := range fields {
+			cmd = append(cmd, f)
+		}
+	}
+	if q.sort != "" {
+		field, dir, _ := strings.Cut(q.sort, ":")
+		switch strings.ToLower(dir) {
+		case "", "asc":
+			dir = "ASC"
+		case "desc":
+			dir = "DESC"
+		default:
+			return nil, usageErr("--sort %s: direction must be asc or desc", q.sort)
+		}
+		cmd = append(cmd, "SORTBY", field, dir)
+	}
+	if q.limit != "" {
+		off, n, err := parseLimit(q.limit)
+		if err != nil {
+			return nil, err
+		}
+		cmd = append(cmd, "LIMIT", off, n)
+	}
+	return cmd, nil
+}
+
+func splitFlag(name, v string) (string, string, error) {
+	field, val, ok := strings.Cut(v, "=")
+	if !ok || field == "" || val == "" {
+		return "", "", usageErr("--%s %s: want field=value", name, v)
+	}
+	return field, val, nil
+}
+
+// parseRange parses lo:hi; an empty bound or -inf/+inf leaves it open.
+func parseRange(spec string) (float64, float64, error) {
+	loS, hiS, ok := strings.Cut(spec, ":")
+	if !ok {
+		return 0, 0, usageErr("--range %s: want lo:hi", spec)
+	}
+	bound := func(s string, def float64) (float64, error) {
+		if s == "" {
+			return def, nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, usageErr("--range %s: %q is not a number", spec, s)
+		}
+		return f, nil
+	}
+	lo, err := bound(loS, math.Inf(-1))
+	if err != nil {
+		return 0, 0, err
+	}
+	hi, err := bound(hiS, math.Inf(1))
+	return lo, hi, err
+}
+
+// parseNear parses lon,lat,radius[unit]; the unit defaults to km.
+func parseNear(field, spec string) (*redisft.GeoQuery, error) {
+	parts := strings.Split(spec, ",")
+	if len(parts) != 3 {
+		return nil, usageErr("--near %s: want lon,lat,radius", spec)
+	}
+	lon, err1 := strconv.ParseFloat(parts[0], 64)
+	lat, err2 := strconv.ParseFloat(parts[1], 64)
+	if err1 != nil || err2 != nil {
+		return nil, usageErr("--near %s: bad coordinates", spec)
+	}
+	rs := parts[2]
+	unit := redisft.Kilometers
+	for _, u := range []redisft.GeoUnit{redisft.Kilometers, redisft.Miles, redisft.Feet, redisft.Meters} {
+		if strings.HasSuffix(rs, string(u)) {
+			rs, unit = strings.TrimSuffix(rs, string(u)), u
+			break
+		}
+	}
+	r, err := strconv.ParseFloat(rs, 64)
+	if err != nil || r <= 0 {
+		return nil, usageErr("--near %s: bad radius %q", spec, parts[2])
+	}
+	return redisft.NewGeoQuery(field).Center(lon, lat).Radius(r, unit), nil
+}
+
+func parseLimit(s string) (int, int, error) {
+	offS, nS, ok := strings.Cut(s, ":")
+	if !ok {
+		offS, nS = "0", s
+	}
+	off, err1 := strconv.Atoi(offS)
+	n, err2 := strconv.Atoi(nS)
+	if err1 != nil || err2 != nil || off < 0 || n < 0 {
+		return 0, 0, usageErr("--limit %s: want [off:]n", s)
+	}
+	return off, n, nil
+}
+
+// record is one search hit or aggregate row.
+type record struct {
+	ID     string            `json:"id,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// parseSearch decodes an FT.SEARCH reply.
+func parseSearch(raw []any, noContent bool) (int64, []record) {
+	if len(raw) == 0 {
+		return 0, nil
+	}
+	total, _ := raw[0].(int64)
+	var out []record
+	for i := 1; i < len(raw); i++ {
+		r := record{ID: fmt.Sprint(raw[i])}
+		if !noContent && i+1 < len(raw) {
+			r.Fields = pairs(raw[i+1])
+			i++
+		}
+		out = append(out, r)
+	}
+	return total, out
+}
+
+// pairs turns a flat [k, v, k, v, ...] reply into a map.
+func pairs(raw any) map[string]string {
+	kv, _ := raw.([]any)
+	m := make(map[string]string, len(kv)/2)
+	for j := 0; j+1 < len(kv); j += 2 {
+		m[fmt.Sprint(kv[j])] = fmt.Sprint(kv[j+1])
+	}
+	return m
+}
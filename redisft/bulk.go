@@ -0,0 +1,456 @@
+package redisft
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is one document fed to Load.
+type Record[T any] struct {
+	ID  string
+	Doc *T
+}
+
+// BulkOptions configures Load, LoadJSONL and LoadCSV.
+type BulkOptions struct {
+	// BatchSize is the number of documents per pipeline. Default 500.
+	BatchSize int
+	// Concurrency is the number of pipelines in flight. Default 4.
+	Concurrency int
+	// IDField names the struct field holding the document ID; required by
+	// LoadJSONL and LoadCSV.
+	IDField string
+	// Columns maps CSV header names to struct field names; a column mapped
+	// to "-" is skipped. Unmapped headers must match a field name
+	// (case-insensitively).
+	Columns map[string]string
+	// MaxErrors aborts the import once more records than this have failed.
+	// Zero means no limit.
+	MaxErrors int
+	// Insert is applied to every write (e.g. WithTTL).
+	Insert []InsertOption
+	// OnProgress, if set, is called after each batch. Calls are serialised.
+	OnProgress func(BulkProgress)
+}
+
+// BulkProgress reports how far an import has got.
+type BulkProgress struct {
+	Read    int // records read from the source
+	Written int // records stored
+	Failed  int // records rejected
+	Elapsed time.Duration
+}
+
+// RecordError describes one record that was not stored. Record is its
+// 1-based position in the source (the data row for CSV).
+type RecordError struct {
+	Record int
+	ID     string
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	if e.ID == "" {
+		return fmt.Sprintf("redisft: record %d: %v", e.Record, e.Err)
+	}
+	return fmt.Sprintf("redisft: record %d (%s): %v", e.Record, e.ID, e.Err)
+}
+
+func (e *RecordError) Unwrap() error { return e.Err }
+
+// BulkResult is the outcome of an import.
+type BulkResult struct {
+	BulkProgress
+	Errors []*RecordError
+}
+
+// ErrTooManyErrors is returned when an import exceeds BulkOptions.MaxErrors.
+var ErrTooManyErrors = errors.New("redisft: bulk import aborted: too many failed records")
+
+// bulkItem is a record in flight; err is set when it failed before writing.
+type bulkItem[T any] struct {
+	n   int
+	id  string
+	doc *T
+	err error
+}
+
+// Load stores the records received from src until it is closed. Failed
+// records are collected in the result; the returned error is set when the
+// import stopped early (context cancelled or MaxErrors exceeded), or is
+// ErrCacheBroadcast when every batch was written but a cache invalidation
+// could not be published.
+func (r *Repository[T]) Load(ctx context.Context, src <-chan Record[T], opts BulkOptions) (*BulkResult, error) {
+	n := 0
+	return r.load(ctx, opts, func(emit func(bulkItem[T]) bool) error {
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case rec, ok := <-src:
+				if !ok {
+					return nil
+				}
+				n++
+				it := bulkItem[T]{n: n, id: rec.ID, doc: rec.Doc}
+				if it.id == "" {
+					it.err = errors.New("empty ID")
+				} else if it.doc == nil {
+					it.err = errors.New("nil document")
+				}
+				if !emit(it) {
+					return nil
+				}
+			}
+		}
+	})
+}
+
+// LoadJSONL stores one JSON object per line of rd. Blank lines are skipped.
+func (r *Repository[T]) LoadJSONL(ctx context.Context, rd io.Reader, opts BulkOptions) (*BulkResult, error) {
+	idx, err := bulkIDField(reflect.TypeOf(*new(T)), opts.IDField)
+	if err != nil {
+		return nil, err
+	}
+	return r.load(ctx, opts, jsonlSource(rd, func(line []byte) (string, *T, error) {
+		doc := new(T)
+		if err := json.Unmarshal(line, doc); err != nil {
+			return "", doc, err
+		}
+		id, err := docID(doc, idx)
+		return id, doc, err
+	}))
+}
+
+// LoadCSV stores the rows of rd. The first row is the header; values are
+// decoded like hash fields (times as RFC 3339 or Unix seconds).
+func (r *Repository[T]) LoadCSV(ctx context.Context, rd io.Reader, opts BulkOptions) (*BulkResult, error) {
+	t := reflect.TypeOf(*new(T))
+	idx, err := bulkIDField(t, opts.IDField)
+	if err != nil {
+		return nil, err
+	}
+	cr, header, err := csvHeader(rd)
+	if err != nil {
+		return nil, err
+	}
+	cols, err := csvColumns(t, header, opts.Columns)
+	if err != nil {
+		return nil, err
+	}
+	return r.load(ctx, opts, csvSource(cr, cols, func(_ []string, doc *T) (string, error) {
+		return docID(doc, idx)
+	}))
+}
+
+// jsonlSource emits one record per non-blank line of rd, decoded by decode.
+func jsonlSource[T any](rd io.Reader, decode func(line []byte) (string, *T, error)) func(emit func(bulkItem[T]) bool) error {
+	return func(emit func(bulkItem[T]) bool) error {
+		sc := bufio.NewScanner(rd)
+		sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		n := 0
+		for sc.Scan() {
+			line := bytes.TrimSpace(sc.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			n++
+			it := bulkItem[T]{n: n}
+			it.id, it.doc, it.err = decode(line)
+			if !emit(it) {
+				return nil
+			}
+		}
+		return sc.Err()
+	}
+}
+
+func csvHeader(rd io.Reader) (*csv.Reader, []string, error) {
+	cr := csv.NewReader(rd)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("redisft: reading CSV header: %w", err)
+	}
+	cr.ReuseRecord = true
+	return cr, header, nil
+}
+
+// csvSource emits one record per data row of cr. cols holds the hash field
+// of each column ("" to skip it); id derives the document ID.
+func csvSource[T any](cr *csv.Reader, cols []string, id func(row []string, doc *T) (string, error)) func(emit func(bulkItem[T]) bool) error {
+	return func(emit func(bulkItem[T]) bool) error {
+		for n := 1; ; n++ {
+			row, err := cr.Read()
+			if err == io.EOF {
+				return nil
+			}
+			it := bulkItem[T]{n: n, doc: new(T)}
+			var pe *csv.ParseError
+			switch {
+			case errors.As(err, &pe) && errors.Is(pe.Err, csv.ErrFieldCount):
+				it.err = err
+			case err != nil:
+				return err
+			default:
+				m := make(map[string]any, len(cols))
+				for i, f := range cols {
+					if f != "" && row[i] != "" {
+						m[f] = row[i]
+					}
+				}
+				if it.err = fillStruct(reflect.ValueOf(it.doc).Elem(), m); it.err == nil {
+					it.id, it.err = id(row, it.doc)
+				}
+			}
+			if !emit(it) {
+				return nil
+			}
+		}
+	}
+}
+
+// csvColumns maps each header to a lower-cased struct field name, or "" to
+// skip the column. Headers in skip are always skipped.
+func csvColumns(t reflect.Type, header []string, rename map[string]string, skip ...string) ([]string, error) {
+	fields := map[string]string{}
+	for _, name := range fieldNames(t) {
+		fields[name] = name
+	}
+	skipped := map[string]bool{}
+	for _, h := range skip {
+		skipped[h] = true
+	}
+	cols := make([]string, len(header))
+	for i, h := range header {
+		name := strings.TrimSpace(h)
+		if skipped[name] {
+			continue
+		}
+		if to, ok := rename[name]; ok {
+			if to == "-" {
+				continue
+			}
+			name = to
+		}
+		f, ok := fields[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("redisft: CSV column %q matches no field of %s", h, t.Name())
+		}
+		cols[i] = f
+	}
+	return cols, nil
+}
+
+func bulkIDField(t reflect.Type, name string) (int, error) {
+	if name == "" {
+		return -1, errors.New("redisft: bulk import needs BulkOptions.IDField")
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if strings.EqualFold(t.Field(i).Name, name) {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("redisft: IDField %q is not a field of %s", name, t.Name())
+}
+
+func docID(doc any, idx int) (string, error) {
+	v := reflect.ValueOf(doc).Elem().Field(idx)
+	if v.IsZero() {
+		return "", errors.New("empty ID field")
+	}
+	return fmt.Sprint(v.Interface()), nil
+}
+
+// load runs produce, which emits records in source order, and writes them
+// in batches with bounded concurrency. emit returns false once the import
+// is being aborted.
+func (r *Repository[T]) load(ctx context.Context, opts BulkOptions, produce func(emit func(bulkItem[T]) bool) error) (*BulkResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if _, err := newInsertOptions(opts.Insert); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu    sync.Mutex
+		res   = &BulkResult{}
+		start = time.Now()
+		abort error
+		bcast error // first failed cache invalidation broadcast
+	)
+	// fail records failed items and reports whether the import may go on.
+	// Must hold mu.
+	fail := func(items ...bulkItem[T]) bool {
+		for _, it := range items {
+			res.Failed++
+			res.Errors = append(res.Errors, &RecordError{Record: it.n, ID: it.id, Err: it.err})
+		}
+		if opts.MaxErrors > 0 && res.Failed > opts.MaxErrors && abort == nil {
+			abort = ErrTooManyErrors
+			cancel()
+		}
+		return abort == nil
+	}
+	progress := func() {
+		res.Elapsed = time.Since(start)
+		if opts.OnProgress != nil {
+			opts.OnProgress(res.BulkProgress)
+		}
+	}
+
+	batches := make(chan []bulkItem[T])
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				failed, berr := r.writeBatch(ctx, b, opts.Insert)
+				mu.Lock()
+				if bcast == nil {
+					bcast = berr
+				}
+				res.Written += len(b) - len(failed)
+				fail(failed...)
+				progress()
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var batch []bulkItem[T]
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+		select {
+		case batches <- batch:
+			batch = nil
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	err := produce(func(it bulkItem[T]) bool {
+		mu.Lock()
+		res.Read++
+		ok := true
+		if it.err != nil {
+			ok = fail(it)
+		}
+		mu.Unlock()
+		if !ok {
+			return false
+		}
+		if it.err == nil {
+			batch = append(batch, it)
+			if len(batch) >= opts.BatchSize {
+				return flush()
+			}
+		}
+		return true
+	})
+	if err == nil && ctx.Err() == nil {
+		flush()
+	}
+	close(batches)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	res.Elapsed = time.Since(start)
+	switch {
+	case abort != nil:
+		return res, abort
+	case err != nil:
+		return res, err
+	case ctx.Err() != nil:
+		return res, ctx.Err()
+	}
+	return res, bcast
+}
+
+// writeBatch stores one batch in a pipeline and returns the items that
+// failed, each with its error, and the ErrCacheBroadcast error of the cache
+// invalidation that follows, which does not undo the writes.
+func (r *Repository[T]) writeBatch(ctx context.Context, batch []bulkItem[T], opts []InsertOption) ([]bulkItem[T], error) {
+	o, _ := newInsertOptions(opts)
+	var failed []bulkItem[T]
+	writes := make([]*docWrite, 0, len(batch))
+	items := make([]bulkItem[T], 0, len(batch))
+	for _, it := range batch {
+		w, err := r.newWrite(it.id, it.doc, &o)
+		if err != nil {
+			it.err = err
+			failed = append(failed, it)
+			continue
+		}
+		writes = append(writes, w)
+		items = append(items, it)
+	}
+	if len(writes) == 0 {
+		return failed, nil
+	}
+
+	rc := r.cli.Get()
+	var cmdErrs []error
+	err := r.do(ctx, &CommandInfo{Op: OpInsertMany, versioned: versioned(writes)}, func(ctx context.Context) error {
+		pipe := rc.Pipeline()
+		ends := make([]int, len(writes))
+		for i, w := range writes {
+			w.queue(ctx, pipe)
+			ends[i] = pipe.Len()
+		}
+		cmds, err := pipe.Exec(ctx)
+		if len(cmds) == 0 {
+			return wrapErr(err, "")
+		}
+		// Attribute command errors to the record that queued them; the
+		// batch only fails as a whole when no record got through.
+		cmdErrs = make([]error, len(writes))
+		bad, begin := 0, 0
+		for i, end := range ends {
+			for _, c := range cmds[begin:end] {
+				if c.Err() != nil && cmdErrs[i] == nil {
+					cmdErrs[i] = wrapErr(c.Err(), "")
+					bad++
+				}
+			}
+			begin = end
+		}
+		if bad == len(writes) {
+			return wrapErr(err, "")
+		}
+		return nil
+	})
+	bcast := r.written(ctx, nil)
+	for i, it := range items {
+		switch {
+		case err != nil:
+			it.err = err
+		case cmdErrs[i] != nil:
+			it.err = cmdErrs[i]
+		default:
+			writes[i].done()
+			continue
+		}
+		failed = append(failed, it)
+	}
+	return failed, bcast
+}
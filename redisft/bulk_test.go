@@ -0,0 +1,51 @@
+package redisft
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCSVColumns(t *testing.T) {
+	t.Parallel()
+	typ := reflect.TypeOf(product{})
+	tests := []struct {
+		name    string
+		header  []string
+		rename  map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{"case insensitive", []string{"ID", " name ", "PRICE"}, nil, []string{"id", "name", "price"}, false},
+		{"renamed", []string{"id", "colour"}, map[string]string{"colour": "Color"}, []string{"id", "color"}, false},
+		{"skipped", []string{"id", "notes"}, map[string]string{"notes": "-"}, []string{"id", ""}, false},
+		{"unknown", []string{"id", "notes"}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := csvColumns(typ, tt.header, tt.rename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("csvColumns = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBulkIDField(t *testing.T) {
+	t.Parallel()
+	typ := reflect.TypeOf(product{})
+	if i, err := bulkIDField(typ, "id"); err != nil || i != 0 {
+		t.Errorf("bulkIDField(id) = %d, %v", i, err)
+	}
+	for _, name := range []string{"", "sku"} {
+		if _, err := bulkIDField(typ, name); err == nil {
+			t.Errorf("bulkIDField(%q): want error", name)
+		}
+	}
+	if _, err := docID(&product{}, 0); err == nil {
+		t.Error("docID of empty ID: want error")
+	}
+}
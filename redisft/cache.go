@@ -0,0 +1,227 @@
+package redisft
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// CacheOptions configures a repository's result cache.
+type CacheOptions struct {
+	// TTL bounds how long a result is served from memory.
+	TTL time.Duration
+	// MaxEntries bounds the number of cached results; the least recently
+	// used entry is evicted first. Defaults to 1000.
+	MaxEntries int
+	// Broadcast publishes an invalidation on Redis pub/sub after every
+	// write and listens for invalidations from other processes, so caches
+	// of the same index stay coherent across instances.
+	Broadcast bool
+}
+
+// CacheStats is a snapshot of a ResultCache's counters.
+type CacheStats struct {
+	Hits, Misses, Invalidations uint64
+	Entries                     int
+}
+
+// ResultCache holds search results of one repository, keyed by the full
+// FT.SEARCH argument list. It is safe for concurrent use.
+type ResultCache struct {
+	ttl time.Duration
+	max int
+
+	mu    sync.Mutex
+	lru   *list.List // of *cacheEntry, most recent first
+	items map[string]*list.Element
+	gen   uint64
+
+	hits, misses, invalidations atomic.Uint64
+
+	channel string
+	id      string
+	pubsub  *redis.PubSub
+	done    chan struct{}
+}
+
+type cacheEntry struct {
+	key     string
+	total   int64
+	hits    []hit
+	expires time.Time
+}
+
+type pubSubClient interface {
+	Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// EnableCache attaches a result cache to r. Exec, ExecIDs, ExecInto and Page
+// are served from it; Insert, InsertMany, Update and Delete on r clear it.
+// Call Close on the returned cache when the repository is no longer used.
+func (r *Repository[T]) EnableCache(opts CacheOptions) (*ResultCache, error) {
+	if opts.TTL <= 0 {
+		return nil, fmt.Errorf("redisft: cache TTL must be positive, got %s", opts.TTL)
+	}
+	if opts.MaxEntries < 0 {
+		return nil, fmt.Errorf("redisft: cache MaxEntries must not be negative, got %d", opts.MaxEntries)
+	}
+	if opts.MaxEntries == 0 {
+		opts.MaxEntries = 1000
+	}
+	c := &ResultCache{
+		ttl:   opts.TTL,
+		max:   opts.MaxEntries,
+		lru:   list.New(),
+		items: map[string]*list.Element{},
+	}
+	if opts.Broadcast {
+		if err := c.listen(r.cli.Get(), "redisft:invalidate:"+r.index); err != nil {
+			return nil, err
+		}
+	}
+	r.cache = c
+	return c, nil
+}
+
+func (c *ResultCache) listen(rc RedisClient, channel string) error {
+	ps, ok := rc.(pubSubClient)
+	if !ok {
+		return fmt.Errorf("redisft: cache broadcast: %T does not support pub/sub", rc)
+	}
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return err
+	}
+	c.channel, c.id = channel, hex.EncodeToString(id[:])
+
+	ctx := context.Background()
+	sub := ps.Subscribe(ctx, channel)
+	// Wait for the confirmation so no invalidation published after
+	// EnableCache returns can be missed.
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return wrapErr(err, "")
+	}
+	c.pubsub, c.done = sub, make(chan struct{})
+	go func() {
+		defer close(c.done)
+		for msg := range sub.Channel() {
+			if msg.Payload != c.id {
+				c.Purge()
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops listening for remote invalidations and empties the cache.
+func (c *ResultCache) Close() error {
+	var err error
+	if c.pubsub != nil {
+		err = c.pubsub.Close()
+		<-c.done
+	}
+	c.Purge()
+	return err
+}
+
+// Purge drops every cached result.
+func (c *ResultCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Init()
+	c.items = map[string]*list.Element{}
+	c.gen++
+	c.invalidations.Add(1)
+}
+
+// Stats returns the cache counters.
+func (c *ResultCache) Stats() CacheStats {
+	c.mu.Lock()
+	n := c.lru.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Invalidations: c.invalidations.Load(),
+		Entries:       n,
+	}
+}
+
+// get returns the cached entry for key and the current generation, which
+// must be passed back to put.
+func (c *ResultCache) get(key string) (*cacheEntry, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*cacheEntry)
+		if time.Now().Before(e.expires) {
+			c.lru.MoveToFront(el)
+			c.hits.Add(1)
+			return e, c.gen
+		}
+		c.lru.Remove(el)
+		delete(c.items, key)
+	}
+	c.misses.Add(1)
+	return nil, c.gen
+}
+
+// put stores a result unless the cache was purged since gen was read, which
+// would mean the result may predate a write.
+func (c *ResultCache) put(key string, gen uint64, total int64, hits []hit) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		c.lru.Remove(el)
+	}
+	c.items[key] = c.lru.PushFront(&cacheEntry{key: key, total: total, hits: hits, expires: time.Now().Add(c.ttl)})
+	for c.lru.Len() > c.max {
+		el := c.lru.Back()
+		c.lru.Remove(el)
+		delete(c.items, el.Value.(*cacheEntry).key)
+	}
+}
+
+// invalidate purges the cache and tells other processes to do the same.
+// Publish failures are returned but the local purge always happens.
+func (c *ResultCache) invalidate(ctx context.Context, rc RedisClient) error {
+	c.Purge()
+	if c.channel == "" {
+		return nil
+	}
+	return wrapErr(rc.(pubSubClient).Publish(ctx, c.channel, c.id).Err(), "")
+}
+
+func cacheKey(args []any) string {
+	var sb strings.Builder
+	for _, a := range args {
+		fmt.Fprint(&sb, a)
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// written invalidates the cache after a write attempt. It runs even when the
+// write failed, since a pipeline may have been partly applied.
+func (r *Repository[T]) written(ctx context.Context, err error) error {
+	if r.cache == nil {
+		return err
+	}
+	if perr := r.cache.invalidate(ctx, r.cli.Get()); err == nil && perr != nil {
+		return fmt.Errorf("%w: %w", ErrCacheBroadcast, perr)
+	}
+	return err
+}
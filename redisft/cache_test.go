@@ -0,0 +1,69 @@
+package redisft
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestCache(ttl time.Duration, max int) *ResultCache {
+	r := NewRepo[product](&Client{})
+	c, err := r.EnableCache(CacheOptions{TTL: ttl, MaxEntries: max})
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func TestResultCache_LRU(t *testing.T) {
+	t.Parallel()
+	c := newTestCache(time.Minute, 2)
+	for _, k := range []string{"a", "b"} {
+		_, gen := c.get(k)
+		c.put(k, gen, 1, nil)
+	}
+	c.get("a") // a is now most recent
+	_, gen := c.get("c")
+	c.put("c", gen, 1, nil)
+
+	if e, _ := c.get("b"); e != nil {
+		t.Error("b should have been evicted")
+	}
+	if e, _ := c.get("a"); e == nil {
+		t.Error("a should still be cached")
+	}
+	if st := c.Stats(); st.Entries != 2 || st.Hits != 2 || st.Misses != 4 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestResultCache_TTLAndGeneration(t *testing.T) {
+	t.Parallel()
+	c := newTestCache(10*time.Millisecond, 0)
+	_, gen := c.get("q")
+	c.put("q", gen, 3, nil)
+	if e, _ := c.get("q"); e == nil || e.total != 3 {
+		t.Fatal("entry not cached")
+	}
+	time.Sleep(15 * time.Millisecond)
+	if e, _ := c.get("q"); e != nil {
+		t.Error("entry outlived its TTL")
+	}
+
+	// A result read before a purge must not be stored after it.
+	_, gen = c.get("q")
+	c.Purge()
+	c.put("q", gen, 3, nil)
+	if e, _ := c.get("q"); e != nil {
+		t.Error("stale result stored after purge")
+	}
+}
+
+func TestEnableCache_Invalid(t *testing.T) {
+	t.Parallel()
+	r := NewRepo[product](&Client{})
+	for _, o := range []CacheOptions{{}, {TTL: time.Second, MaxEntries: -1}} {
+		if _, err := r.EnableCache(o); err == nil {
+			t.Errorf("EnableCache(%+v): expected error", o)
+		}
+	}
+}
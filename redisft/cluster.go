@@ -0,0 +1,213 @@
+package redisft
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// UniversalPool is a ConnPool over any go-redis UniversalClient: a single
+// node, a Redis Cluster or a Sentinel-managed failover client.
+type UniversalPool struct{ Client redis.UniversalClient }
+
+func (p *UniversalPool) Get() RedisClient { return p.Client }
+func (p *UniversalPool) Close() error     { return p.Client.Close() }
+
+// WithHashTags makes repositories use "{name}:" key prefixes so all documents
+// of a repository share one cluster slot. Needed for MULTI/EXEC or Lua over
+// several documents in cluster mode; it trades away key distribution.
+func WithHashTags() Option {
+	return func(o *options) error {
+		o.hashTags = true
+		return nil
+	}
+}
+
+// WithSentinelAuth sets the credentials for the Sentinel nodes themselves;
+// only valid with NewFailoverClient.
+func WithSentinelAuth(username, password string) Option {
+	return connOption(func(o *options) error {
+		o.sentinelUser, o.sentinelPass = username, password
+		o.sentinelSet = true
+		return nil
+	})
+}
+
+// NewClusterClient builds a Client for a Redis Cluster. FT.CREATE and
+// FT.DROPINDEX are sent to every master so the schema exists on each shard.
+// With WithHashTags the other FT.* commands go to the shard holding the
+// repository's documents, so searches are complete; without it documents
+// are spread over all shards and searching them needs the RediSearch
+// coordinator (Redis Enterprise, or the cluster-enabled module).
+func NewClusterClient(addrs []string, opts ...Option) (*Client, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("redisft: cluster needs at least one address")
+	}
+	o := defaultOptions()
+	if err := o.apply(opts); err != nil {
+		return nil, err
+	}
+	if err := o.universalOnly("cluster"); err != nil {
+		return nil, err
+	}
+	rc := redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs:        addrs,
+		Username:     o.username,
+		Password:     o.password,
+		TLSConfig:    o.tlsConfig,
+		PoolSize:     o.poolSize,
+		MinIdleConns: o.minIdleConns,
+		DialTimeout:  o.dialTimeout,
+		ReadTimeout:  o.readTimeout,
+		WriteTimeout: o.writeTimeout,
+		IdleTimeout:  o.idleTimeout,
+	})
+	return o.newClient(&UniversalPool{Client: rc}), nil
+}
+
+// NewFailoverClient builds a Client for a Sentinel-managed master.
+func NewFailoverClient(masterName string, sentinelAddrs []string, opts ...Option) (*Client, error) {
+	if masterName == "" || len(sentinelAddrs) == 0 {
+		return nil, errors.New("redisft: failover needs a master name and sentinel addresses")
+	}
+	o := defaultOptions()
+	o.sentinelOK = true
+	if err := o.apply(opts); err != nil {
+		return nil, err
+	}
+	if o.client != nil {
+		return nil, errors.New("redisft: WithRedisClient cannot be used with NewFailoverClient")
+	}
+	rc := redis.NewFailoverClient(&redis.FailoverOptions{
+		MasterName:       masterName,
+		SentinelAddrs:    sentinelAddrs,
+		SentinelUsername: o.sentinelUser,
+		SentinelPassword: o.sentinelPass,
+		Username:         o.username,
+		Password:         o.password,
+		DB:               o.db,
+		TLSConfig:        o.tlsConfig,
+		PoolSize:         o.poolSize,
+		MinIdleConns:     o.minIdleConns,
+		DialTimeout:      o.dialTimeout,
+		ReadTimeout:      o.readTimeout,
+		WriteTimeout:     o.writeTimeout,
+		IdleTimeout:      o.idleTimeout,
+	})
+	return o.newClient(&UniversalPool{Client: rc}), nil
+}
+
+func (o *options) universalOnly(kind string) error {
+	if o.client != nil {
+		return errors.New("redisft: WithRedisClient cannot be used with a " + kind + " client")
+	}
+	if o.db != 0 {
+		return errors.New("redisft: WithDB is not supported by Redis Cluster")
+	}
+	return nil
+}
+
+// ftDo sends an FT.* command. On a cluster client the command goes to the
+// master owning route (see Repository.route), not to the slot of its index
+// name: without the RediSearch coordinator a shard only searches the
+// documents stored on it.
+func ftDo(ctx context.Context, rc RedisClient, route string, args ...any) *redis.Cmd {
+	node, err := ftNode(ctx, rc, route)
+	if err != nil {
+		cmd := redis.NewCmd(ctx, args...)
+		cmd.SetErr(err)
+		return cmd
+	}
+	return node.Do(ctx, args...)
+}
+
+// ftPipeline sends FT.* commands in one pipeline, routed like ftDo, and
+// returns their replies.
+func ftPipeline(ctx context.Context, rc RedisClient, route string, cmds [][]any) ([]any, error) {
+	node, err := ftNode(ctx, rc, route)
+	if err != nil {
+		return nil, err
+	}
+	pipe := node.Pipeline()
+	out := make([]*redis.Cmd, len(cmds))
+	for i, args := range cmds {
+		out[i] = pipe.Do(ctx, args...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	replies := make([]any, len(out))
+	for i, c := range out {
+		replies[i] = c.Val()
+	}
+	return replies, nil
+}
+
+// ftNode returns the client FT.* commands routed by route are sent through:
+// rc itself, or on a cluster client the master owning route's slot.
+func ftNode(ctx context.Context, rc RedisClient, route string) (RedisClient, error) {
+	cc, ok := rc.(*redis.ClusterClient)
+	if !ok {
+		return rc, nil
+	}
+	node, err := cc.MasterForKey(ctx, route)
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// ftBroadcast runs a schema command; on a cluster client it runs on every
+// master. Errors matching ignore (e.g. ErrIndexExists) are dropped.
+func ftBroadcast(ctx context.Context, rc RedisClient, ignore error, args ...any) error {
+	check := func(err error) error {
+		if err = wrapErr(err, ""); err != nil && !errors.Is(err, ignore) {
+			return err
+		}
+		return nil
+	}
+	cc, ok := rc.(*redis.ClusterClient)
+	if !ok {
+		return check(rc.Do(ctx, args...).Err())
+	}
+	var (
+		mu    sync.Mutex
+		first error
+	)
+	err := cc.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		if err := check(node.Do(ctx, args...).Err()); err != nil {
+			mu.Lock()
+			if first == nil {
+				first = err
+			}
+			mu.Unlock()
+		}
+		return nil
+	})
+	if first != nil {
+		return first
+	}
+	return wrapErr(err, "")
+}
+
+// clusterMasters lists the master nodes of cc, ordered by address.
+func clusterMasters(ctx context.Context, cc *redis.ClusterClient) ([]*redis.Client, error) {
+	var (
+		mu    sync.Mutex
+		nodes []*redis.Client
+	)
+	err := cc.ForEachMaster(ctx, func(_ context.Context, node *redis.Client) error {
+		mu.Lock()
+		nodes = append(nodes, node)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, wrapErr(err, "")
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Options().Addr < nodes[j].Options().Addr })
+	return nodes, nil
+}
@@ -0,0 +1,168 @@
+package redisft
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestNewClusterClient(t *testing.T) {
+	t.Parallel()
+	cli, err := NewClusterClient([]string{"n1:7000", "n2:7000"}, WithPoolSize(20), WithHashTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	if _, ok := cli.Get().(*redis.ClusterClient); !ok {
+		t.Fatalf("got %T, want *redis.ClusterClient", cli.Get())
+	}
+	if !cli.hashTags {
+		t.Error("hash tags not enabled")
+	}
+	if got := NewRepo[product](cli).prefix; got != "{product}:" {
+		t.Errorf("prefix = %q", got)
+	}
+}
+
+func TestNewFailoverClient(t *testing.T) {
+	t.Parallel()
+	cli, err := NewFailoverClient("mymaster", []string{"s1:26379"}, WithSentinelAuth("", "s3cret"), WithDB(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	if got := NewRepo[product](cli).prefix; got != "product:" {
+		t.Errorf("prefix = %q", got)
+	}
+}
+
+func TestClusterOptions_Invalid(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		fn   func() (*Client, error)
+	}{
+		{"cluster no addrs", func() (*Client, error) { return NewClusterClient(nil) }},
+		{"cluster db", func() (*Client, error) { return NewClusterClient([]string{"n:1"}, WithDB(2)) }},
+		{"cluster sentinel auth", func() (*Client, error) {
+			return NewClusterClient([]string{"n:1"}, WithSentinelAuth("", "x"))
+		}},
+		{"failover no master", func() (*Client, error) { return NewFailoverClient("", []string{"s:1"}) }},
+		{"failover no sentinels", func() (*Client, error) { return NewFailoverClient("m", nil) }},
+		{"single sentinel auth", func() (*Client, error) {
+			return NewClientWithOptions("h:1", WithSentinelAuth("", "x"))
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := tc.fn(); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// recordingNode is a cluster node that records the name of every command it
+// receives and fails all of them.
+type recordingNode struct {
+	mu   sync.Mutex
+	cmds []string
+}
+
+func (n *recordingNode) serve(nc net.Conn) {
+	defer nc.Close()
+	rd := bufio.NewReader(nc)
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		n.mu.Lock()
+		n.cmds = append(n.cmds, strings.ToUpper(args[0]))
+		n.mu.Unlock()
+		if _, err := nc.Write([]byte("-ERR fake\r\n")); err != nil {
+			return
+		}
+	}
+}
+
+func (n *recordingNode) received() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.cmds...)
+}
+
+// readCommand reads one RESP array of bulk strings.
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if _, err := rd.ReadString('\n'); err != nil { // $len
+			return nil, err
+		}
+		arg, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestCluster_FTCommandsFollowHashTag(t *testing.T) {
+	t.Parallel()
+	// "product" (the hash tag of the documents) hashes to slot 13865 and
+	// "idx:product" to slot 4583, so they live on different shards.
+	nodes := map[string]*recordingNode{"a:1": {}, "b:1": {}}
+	cc := redis.NewClusterClient(&redis.ClusterOptions{
+		ClusterSlots: func(context.Context) ([]redis.ClusterSlot, error) {
+			return []redis.ClusterSlot{
+				{Start: 0, End: 8191, Nodes: []redis.ClusterNode{{Addr: "a:1"}}},
+				{Start: 8192, End: 16383, Nodes: []redis.ClusterNode{{Addr: "b:1"}}},
+			}, nil
+		},
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			n, ok := nodes[addr]
+			if !ok {
+				return nil, fmt.Errorf("unknown node %s", addr)
+			}
+			client, server := net.Pipe()
+			go n.serve(server)
+			return client, nil
+		},
+		MaxRetries: -1,
+	})
+	cli := &Client{pool: &UniversalPool{Client: cc}, hashTags: true}
+	defer cli.Close()
+
+	ctx := context.Background()
+	repo := NewRepo[product](cli)
+	_, _ = repo.Search().Exec(ctx)
+	_, _ = repo.Search().Explain(ctx)
+	_, _ = repo.Search().Facets("color").ExecFacets(ctx)
+	_, _ = repo.TagValues(ctx, "color")
+
+	if got := nodes["a:1"].received(); len(got) != 0 {
+		t.Errorf("index-name shard received %q", got)
+	}
+	want := []string{"FT.SEARCH", "FT.EXPLAINCLI", "FT.SEARCH", "FT.AGGREGATE", "FT.TAGVALS"}
+	if got := nodes["b:1"].received(); !reflect.DeepEqual(got, want) {
+		t.Errorf("document shard received %q, want %q", got, want)
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/go-redis/redis/v8"
 )
@@ -13,6 +13,7 @@ import (
 type RedisClient interface {
 	Do(ctx context.Context, args ...interface{}) *redis.Cmd
 	HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd
+	HGetAll(ctx context.Context, key string) *redis.StringStringMapCmd
 	Del(ctx context.Context, keys ...string) *redis.IntCmd
 	Pipeline() redis.Pipeliner
 	Ping(ctx context.Context) *redis.StatusCmd
@@ -27,22 +28,24 @@ type ConnPool interface {
 type Pool struct{ Client *redis.Client }
 
 func NewPool(host string, maxConns int) *Pool {
-	rdb := redis.NewClient(&redis.Options{
-		Addr:        host,
-		PoolSize:    maxConns,
-		IdleTimeout: 5 * time.Minute,
-		OnConnect: func(ctx context.Context, cn *redis.Conn) error {
-			return cn.Ping(ctx).Err()
-		},
-	})
-	return &Pool{Client: rdb}
+	o := defaultOptions()
+	o.poolSize = maxConns
+	return &Pool{Client: redis.NewClient(o.redisOptions(host))}
 }
 
 func (p *Pool) Get() RedisClient { return p.Client }
 func (p *Pool) Close() error     { return p.Client.Close() }
 
 type Client struct {
-	pool ConnPool
+	pool     ConnPool
+	hashTags bool
+	hooks    []Hook
+
+	retry         *RetryPolicy
+	breakerPolicy *BreakerPolicy
+	mu            sync.Mutex
+	breakers      map[string]*breaker
+	stats         resilienceCounters
 }
 
 func NewClient(addr string, maxConns int) *Client {
@@ -50,14 +53,21 @@ func NewClient(addr string, maxConns int) *Client {
 	return &Client{pool: pool}
 }
 
+// NewClientFromPool wraps an existing ConnPool, e.g. the in-memory fake from
+// the redisfttest package.
+func NewClientFromPool(pool ConnPool) *Client {
+	return &Client{pool: pool}
+}
+
 func (c *Client) Get() RedisClient { return c.pool.Get() }
 func (c *Client) Close() error     { return c.pool.Close() }
 
 
 type Repository[T any] struct {
-	pool   ConnPool
+	cli    *Client
 	index  string
 	prefix string
+	schema schema
 
 	qParts []string
 	qSeen  map[string]struct{}
@@ -67,6 +77,20 @@ type Repository[T any] struct {
 	off    int
 	lim    int
 	limSet bool
+
+	ret       []string
+	noContent bool
+	inKeys    []string
+	inKeysSet bool // InKeys was called, maybe without ids
+	inFields  []string
+	err       error
+
+	facets     []string
+	facetLimit int
+	ranges     []rangeFacet
+
+	pageKey []byte
+	cache   *ResultCache
 }
 
 
@@ -82,84 +106,327 @@ func NewRepo[T any](cli *Client) *Repository[T] {
 		t = t.Elem()
 	}
 	name := strings.ToLower(t.Name())
+	prefix := name + ":"
+	if cli.hashTags {
+		prefix = "{" + name + "}:"
+	}
 	return &Repository[T]{
-		pool:   cli,
+		cli:    cli,
 		index:  "idx:" + name,
-		prefix: name + ":",
+		prefix: prefix,
+		schema: parseSchema(t),
 		qSeen:  map[string]struct{}{},
 	}
 }
 
 func (r *Repository[T]) CreateIndex(ctx context.Context) error {
-	rc := r.pool.Get()
-	args := generateIndexQuery(*new(T))
-	_, err := rc.Do(ctx, append([]any{"FT.CREATE"}, args...)...).Result()
-	if err != nil && !strings.Contains(err.Error(), "exists") {
-		return err
-	}
-	return nil
+	rc := r.cli.Get()
+	args := append([]any{"FT.CREATE"}, indexQuery(r.index, r.prefix, reflect.TypeOf(*new(T)))...)
+	return r.do(ctx, &CommandInfo{Op: OpCreateIndex, Args: args}, func(ctx context.Context) error {
+		return ftBroadcast(ctx, rc, ErrIndexExists, args...)
+	})
 }
 
 func (r *Repository[T]) DropIndex(ctx context.Context, deleteDocs bool) error {
-	rc := r.pool.Get()
+	rc := r.cli.Get()
 	args := []any{"FT.DROPINDEX", r.index}
 	if deleteDocs {
 		args = append(args, "DD")
 	}
-	_, err := rc.Do(ctx, args...).Result()
-	if err != nil && !strings.Contains(err.Error(), "Unknown Index name") {
-		return err
-	}
-	return nil
+	return r.do(ctx, &CommandInfo{Op: OpDropIndex, Args: args}, func(ctx context.Context) error {
+		return ftBroadcast(ctx, rc, ErrIndexNotFound, args...)
+	})
 }
 
 func (r *Repository[T]) key(id string) string { return r.prefix + id }
 
-func (r *Repository[T]) Insert(ctx context.Context, id string, doc *T) error {
-	rc := r.pool.Get()
-	m, err := structToMap(doc)
+// route is the key whose cluster slot FT.* commands of r are sent to. With
+// hash tags it is the slot of every document; otherwise documents are spread
+// over all shards and the index name serves as well as any.
+func (r *Repository[T]) route() string {
+	if r.cli.hashTags {
+		return r.prefix
+	}
+	return r.index
+}
+
+// Insert stores doc under id. Its expiry comes from WithTTL / WithExpireAt
+// or, failing those, from a non-zero `expire` field; it is set in the same
+// pipeline as the write. A `version` field is incremented and written back
+// into doc.
+func (r *Repository[T]) Insert(ctx context.Context, id string, doc *T, opts ...InsertOption) error {
+	o, err := newInsertOptions(opts)
 	if err != nil {
 		return err
 	}
-	return rc.HSet(ctx, r.key(id), m).Err()
+	w, err := r.newWrite(id, doc, &o)
+	if err != nil {
+		return err
+	}
+	return r.writeOne(ctx, OpInsert, w)
 }
 
-func (r *Repository[T]) InsertMany(ctx context.Context, docs map[string]*T) error {
+// Replace stores doc under id like Insert, and also removes the stored
+// fields doc leaves at their zero value, so a later Get returns doc. The
+// version field, if any, keeps counting. The writes are sent in one
+// MULTI/EXEC when the client supports transactions.
+func (r *Repository[T]) Replace(ctx context.Context, id string, doc *T, opts ...InsertOption) error {
+	o, err := newInsertOptions(opts)
+	if err != nil {
+		return err
+	}
+	w, err := r.newWrite(id, doc, &o)
+	if err != nil {
+		return err
+	}
+	var stale []string
+	for _, f := range fieldNames(reflect.TypeOf(doc)) {
+		if _, ok := w.m[f]; !ok && f != w.ver.name {
+			stale = append(stale, f)
+		}
+	}
+	rc := r.cli.Get()
+	err = r.do(ctx, &CommandInfo{Op: OpReplace, versioned: w.ver.ok()}, func(ctx context.Context) error {
+		var pipe redis.Pipeliner
+		if tp, ok := rc.(interface{ TxPipeline() redis.Pipeliner }); ok {
+			pipe = tp.TxPipeline()
+		} else {
+			pipe = rc.Pipeline()
+		}
+		if len(stale) > 0 {
+			pipe.HDel(ctx, w.key, stale...)
+		}
+		w.queue(ctx, pipe)
+		_, err := pipe.Exec(ctx)
+		return wrapErr(err, "")
+	})
+	if err == nil {
+		w.done()
+	}
+	return r.written(ctx, err)
+}
+
+// InsertMany stores docs in one pipeline; expiry and versions work as for
+// Insert.
+func (r *Repository[T]) InsertMany(ctx context.Context, docs map[string]*T, opts ...InsertOption) error {
 	if len(docs) == 0 {
 		return nil
 	}
-	rc := r.pool.Get()
-	pipe := rc.Pipeline()
+	rc := r.cli.Get()
+	o, err := newInsertOptions(opts)
+	if err != nil {
+		return err
+	}
+	writes := make([]*docWrite, 0, len(docs))
 	for id, doc := range docs {
-		m, err := structToMap(doc)
+		w, err := r.newWrite(id, doc, &o)
 		if err != nil {
-			pipe.Discard()
 			return err
 		}
-		pipe.HSet(ctx, r.key(id), m)
+		writes = append(writes, w)
+	}
+	err = r.do(ctx, &CommandInfo{Op: OpInsertMany, versioned: versioned(writes)}, func(ctx context.Context) error {
+		pipe := rc.Pipeline()
+		for _, w := range writes {
+			w.queue(ctx, pipe)
+		}
+		_, err := pipe.Exec(ctx)
+		return wrapErr(err, "")
+	})
+	if err == nil {
+		for _, w := range writes {
+			w.done()
+		}
+	}
+	return r.written(ctx, err)
+}
+
+// docWrite is one document write: its hash fields plus the expiry and
+// version commands queued after the HSET.
+type docWrite struct {
+	key string
+	m   map[string]any
+	exp expiry
+	ver versionSpec
+	doc any // receives the new version; nil for Update
+	cmd *redis.IntCmd
+}
+
+// newWrite prepares the write of doc (a *T or T) under id. o is nil for
+// writes that leave the expiry alone.
+func (r *Repository[T]) newWrite(id string, doc any, o *insertOptions) (*docWrite, error) {
+	m, err := structToMap(doc)
+	if err != nil {
+		return nil, err
+	}
+	w := &docWrite{key: r.key(id), m: m}
+	if o != nil {
+		if w.exp, err = docExpiry(*o, doc); err != nil {
+			return nil, err
+		}
+	}
+	if w.ver, err = versionField(reflect.TypeOf(doc)); err != nil {
+		return nil, err
+	}
+	if w.ver.ok() {
+		delete(m, w.ver.name)
+		if reflect.TypeOf(doc).Kind() == reflect.Ptr {
+			w.doc = doc
+		}
+	}
+	return w, nil
+}
+
+// writeOne sends w, as a plain HSET when it needs nothing else.
+func (r *Repository[T]) writeOne(ctx context.Context, op Op, w *docWrite) error {
+	rc := r.cli.Get()
+	info := &CommandInfo{Op: op, versioned: w.ver.ok()}
+	if w.single() {
+		info.Args = []any{"HSET", w.key, w.m}
+	}
+	err := r.do(ctx, info, func(ctx context.Context) error {
+		if w.single() {
+			return wrapErr(rc.HSet(ctx, w.key, w.m).Err(), "")
+		}
+		pipe := rc.Pipeline()
+		w.queue(ctx, pipe)
+		_, err := pipe.Exec(ctx)
+		return wrapErr(err, "")
+	})
+	if err == nil {
+		w.done()
+	}
+	return r.written(ctx, err)
+}
+
+// single reports whether the write is a lone HSET.
+func (w *docWrite) single() bool { return !w.exp.isSet() && !w.ver.ok() }
+
+func (w *docWrite) queue(ctx context.Context, pipe redis.Pipeliner) {
+	if len(w.m) > 0 {
+		pipe.HSet(ctx, w.key, w.m)
+	}
+	w.exp.queue(ctx, pipe, w.key)
+	if w.ver.ok() {
+		w.cmd = pipe.HIncrBy(ctx, w.key, w.ver.name, 1)
+	}
+}
+
+// versioned reports whether any of ws increments a version field.
+func versioned(ws []*docWrite) bool {
+	for _, w := range ws {
+		if w.ver.ok() {
+			return true
+		}
+	}
+	return false
+}
+
+// done copies the new version into the document after a successful write.
+func (w *docWrite) done() {
+	if w.cmd != nil && w.doc != nil {
+		w.ver.set(w.doc, w.cmd.Val())
+	}
+}
+
+// Get loads a single document by ID. It returns ErrNotFound when the hash
+// does not exist.
+func (r *Repository[T]) Get(ctx context.Context, id string) (*T, error) {
+	rc := r.cli.Get()
+	key := r.key(id)
+	var m map[string]string
+	err := r.do(ctx, &CommandInfo{Op: OpGet, Args: []any{"HGETALL", key}}, func(ctx context.Context) (err error) {
+		m, err = rc.HGetAll(ctx, key).Result()
+		return wrapErr(err, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeHash[T](key, m)
+}
+
+// decodeHash decodes an HGETALL reply; an empty hash means the document
+// does not exist.
+func decodeHash[T any](key string, m map[string]string) (*T, error) {
+	if len(m) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	fields := make(map[string]any, len(m))
+	for k, v := range m {
+		fields[strings.ToLower(k)] = v
 	}
-	_, err := pipe.Exec(ctx)
-	return err
+	doc := new(T)
+	if err := fillStruct(reflect.ValueOf(doc).Elem(), fields); err != nil {
+		return nil, withKey(err, key)
+	}
+	return doc, nil
 }
 
 func (r *Repository[T]) Update(ctx context.Context, id string, patch T) error {
-	rc := r.pool.Get()
-	data, _ := structToMap(patch)
-	return rc.HSet(ctx, r.key(id), data).Err()
+	w, err := r.newWrite(id, patch, nil)
+	if err != nil {
+		return err
+	}
+	return r.writeOne(ctx, OpUpdate, w)
+}
+
+// UpdateIfExists applies patch to document id like Update, but only if the
+// document exists; otherwise it returns ErrNotFound and writes nothing. The
+// check and the write run in one transaction (see RunTx), so a concurrent
+// Delete cannot leave a partial document behind.
+func (r *Repository[T]) UpdateIfExists(ctx context.Context, id string, patch T) error {
+	return r.cli.RunTx(ctx, func(ctx context.Context, tx *Tx) error {
+		if err := r.WatchTx(ctx, tx, id); err != nil {
+			return err
+		}
+		key := r.key(id)
+		n, err := tx.rtx.Exists(ctx, key).Result()
+		if err != nil {
+			return wrapErr(err, "")
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return r.UpdateTx(tx, id, patch)
+	})
 }
 
 func (r *Repository[T]) Delete(ctx context.Context, id string) error {
-	rc := r.pool.Get()
-	return rc.Del(ctx, r.key(id)).Err()
+	rc := r.cli.Get()
+	err := r.do(ctx, &CommandInfo{Op: OpDelete, Args: []any{"DEL", r.key(id)}}, func(ctx context.Context) error {
+		return wrapErr(rc.Del(ctx, r.key(id)).Err(), "")
+	})
+	return r.written(ctx, err)
 }
 
 func (r *Repository[T]) Search(builders ...Builder) *Repository[T] {
 	r.qParts = nil
 	r.qSeen = map[string]struct{}{}
 	r.sSet, r.limSet = false, false
+	r.ret, r.noContent = nil, false
+	r.inKeys, r.inKeysSet, r.inFields, r.err = nil, false, nil, nil
+	r.facets, r.facetLimit, r.ranges = nil, 0, nil
 	return r.Query(builders...)
 }
 
+// Clone returns a repository with its own copy of the query state that
+// shares r's client, cache and page key. The query builder methods are not
+// safe for concurrent use, so clone a shared repository per goroutine.
+func (r *Repository[T]) Clone() *Repository[T] {
+	c := *r
+	c.qParts = append([]string(nil), r.qParts...)
+	c.qSeen = make(map[string]struct{}, len(r.qSeen))
+	for k := range r.qSeen {
+		c.qSeen[k] = struct{}{}
+	}
+	c.ret = append([]string(nil), r.ret...)
+	c.inKeys = append([]string(nil), r.inKeys...)
+	c.inFields = append([]string(nil), r.inFields...)
+	c.facets = append([]string(nil), r.facets...)
+	c.ranges = append([]rangeFacet(nil), r.ranges...)
+	return &c
+}
+
 func (r *Repository[T]) Query(builders ...Builder) *Repository[T] {
 	for _, b := range builders {
 		if _, dup := r.qSeen[b.GetFieldName()]; dup {
@@ -183,12 +450,86 @@ func (r *Repository[T]) Limit(off, cnt int) *Repository[T] {
 	return r
 }
 
-func (r *Repository[T]) args() []any {
-	q := "*"
-	if len(r.qParts) > 0 {
-		q = strings.Join(r.qParts, " ")
+// Select restricts the returned hash fields (RETURN).
+func (r *Repository[T]) Select(fields ...string) *Repository[T] {
+	r.ret = append(r.ret[:0:0], fields...)
+	return r
+}
+
+// IDsOnly asks for document IDs only (NOCONTENT).
+func (r *Repository[T]) IDsOnly() *Repository[T] {
+	r.noContent = true
+	return r
+}
+
+// InKeys limits the search to the given document IDs (INKEYS). IDs are
+// translated to hash keys through the repository prefix. Without IDs the
+// query matches nothing: it returns no hits and is not sent to Redis.
+func (r *Repository[T]) InKeys(ids ...string) *Repository[T] {
+	r.inKeysSet = true
+	for _, id := range ids {
+		r.inKeys = append(r.inKeys, r.key(id))
+	}
+	return r
+}
+
+// InFields limits free-text matching to the given TEXT fields (INFIELDS).
+// Unknown or non-TEXT fields make the query fail on execution.
+func (r *Repository[T]) InFields(fields ...string) *Repository[T] {
+	for _, f := range fields {
+		fs, ok := r.schema.field(f)
+		if !ok {
+			r.setErr(fmt.Errorf("redisft: INFIELDS: unknown field %q", f))
+			continue
+		}
+		if fs.typ != FieldText {
+			r.setErr(fmt.Errorf("redisft: INFIELDS: field %q is %s, not TEXT", f, fs.typ))
+			continue
+		}
+		r.inFields = append(r.inFields, fs.name)
+	}
+	return r
+}
+
+// setErr records the first error raised while building the query.
+func (r *Repository[T]) setErr(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *Repository[T]) query() string {
+	if len(r.qParts) == 0 {
+		return "*"
+	}
+	return strings.Join(r.qParts, " ")
+}
+
+func (r *Repository[T]) args() []any { return r.argsWith(r.ret) }
+
+func (r *Repository[T]) argsWith(ret []string) []any {
+	args := []any{r.index, r.query()}
+	if r.noContent {
+		args = append(args, "NOCONTENT")
+	}
+	if len(r.inKeys) > 0 {
+		args = append(args, "INKEYS", len(r.inKeys))
+		for _, k := range r.inKeys {
+			args = append(args, k)
+		}
+	}
+	if len(r.inFields) > 0 {
+		args = append(args, "INFIELDS", len(r.inFields))
+		for _, f := range r.inFields {
+			args = append(args, f)
+		}
+	}
+	if !r.noContent && len(ret) > 0 {
+		args = append(args, "RETURN", len(ret))
+		for _, f := range ret {
+			args = append(args, f)
+		}
 	}
-	args := []any{r.index, q}
 	if r.sSet {
 		order := "ASC"
 		if !r.sAsc {
@@ -202,37 +543,130 @@ func (r *Repository[T]) args() []any {
 	return args
 }
 
+// hit is a single FT.SEARCH result: the full hash key and, unless NOCONTENT
+// was requested, its (lower-cased) fields.
+type hit struct {
+	key    string
+	fields map[string]any
+}
 
-func (r *Repository[T]) Exec(ctx context.Context) ([]T, error) {
-	rc := r.pool.Get()
-	raw, err := rc.Do(ctx, append([]any{"FT.SEARCH"}, r.args()...)...).Result()
+func (r *Repository[T]) search(ctx context.Context, args []any, noContent bool) (int64, []hit, error) {
+	if r.err != nil {
+		return 0, nil, r.err
+	}
+	if r.inKeysSet && len(r.inKeys) == 0 {
+		return 0, nil, nil
+	}
+	var (
+		key string
+		gen uint64
+	)
+	if r.cache != nil {
+		var e *cacheEntry
+		key = cacheKey(args)
+		if e, gen = r.cache.get(key); e != nil {
+			return e.total, e.hits, nil
+		}
+	}
+	rc := r.cli.Get()
+	cmd := append([]any{"FT.SEARCH"}, args...)
+	info := &CommandInfo{Op: OpSearch, Query: replyString(args[1]), Args: cmd}
+	var rows []interface{}
+	err := r.do(ctx, info, func(ctx context.Context) error {
+		raw, err := ftDo(ctx, rc, r.route(), cmd...).Result()
+		if err != nil {
+			return wrapErr(err, info.Query)
+		}
+		var ok bool
+		if rows, ok = raw.([]interface{}); !ok || len(rows) == 0 {
+			return unexpectedReply("FT.SEARCH", raw)
+		}
+		info.Hits = replyInt(rows[0])
+		return nil
+	})
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
+	total, hits := parseHits(rows, noContent)
+	if r.cache != nil {
+		r.cache.put(key, gen, total, hits)
+	}
+	return total, hits, nil
+}
 
-	rows, ok := raw.([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid response format")
+// parseHits decodes a non-empty FT.SEARCH reply.
+func parseHits(rows []interface{}, noContent bool) (int64, []hit) {
+	step := 2
+	if noContent {
+		step = 1
 	}
-	if len(rows) < 2 {
-		return []T{}, nil
+	hits := make([]hit, 0, (len(rows)-1)/step)
+	for i := 1; i < len(rows); i += step {
+		h := hit{key: replyString(rows[i])}
+		if !noContent && i+1 < len(rows) {
+			fa, _ := rows[i+1].([]interface{})
+			h.fields = make(map[string]any, len(fa)/2)
+			for j := 0; j+1 < len(fa); j += 2 {
+				h.fields[strings.ToLower(replyString(fa[j]))] = fa[j+1]
+			}
+		}
+		hits = append(hits, h)
 	}
+	return replyInt(rows[0]), hits
+}
 
-	var out []T
-	elemT := reflect.TypeOf(*new(T))
-
-	for i := 1; i < len(rows); i += 2 {
-		fa, _ := rows[i+1].([]interface{})
-		m := map[string]any{}
-		for j := 0; j < len(fa); j += 2 {
-			key, _ := fa[j].(string)
-			m[strings.ToLower(key)] = fa[j+1]
-		}
+func decodeHits[V any](hits []hit) ([]V, error) {
+	out := make([]V, 0, len(hits))
+	elemT := reflect.TypeOf(*new(V))
+	for _, h := range hits {
 		elem := reflect.New(elemT).Elem()
-		if err := fillStruct(elem, m); err != nil {
-			return nil, err
+		if err := fillStruct(elem, h.fields); err != nil {
+			return nil, withKey(err, h.key)
 		}
-		out = append(out, elem.Interface().(T))
+		out = append(out, elem.Interface().(V))
 	}
 	return out, nil
 }
+
+// Exec runs the query and decodes every hit into T. In IDsOnly mode the
+// documents carry no fields; use ExecIDs instead.
+func (r *Repository[T]) Exec(ctx context.Context) ([]T, error) {
+	_, hits, err := r.search(ctx, r.args(), r.noContent)
+	if err != nil {
+		return nil, err
+	}
+	return decodeHits[T](hits)
+}
+
+// ExecIDs runs the query with NOCONTENT and returns the matching document
+// IDs (without the repository key prefix).
+func (r *Repository[T]) ExecIDs(ctx context.Context) ([]string, error) {
+	noContent := r.noContent
+	r.noContent = true
+	args := r.args()
+	r.noContent = noContent
+
+	_, hits, err := r.search(ctx, args, true)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(hits))
+	for i, h := range hits {
+		ids[i] = strings.TrimPrefix(h.key, r.prefix)
+	}
+	return ids, nil
+}
+
+// ExecInto runs the query of r and decodes the hits into the lighter type V.
+// Unless Select was called, only the fields V declares are requested.
+func ExecInto[V any, T any](ctx context.Context, r *Repository[T]) ([]V, error) {
+	ret := r.ret
+	if len(ret) == 0 {
+		ret = fieldNames(reflect.TypeOf(*new(V)))
+	}
+	_, hits, err := r.search(ctx, r.argsWith(ret), r.noContent)
+	if err != nil {
+		return nil, err
+	}
+	return decodeHits[V](hits)
+}
@@ -0,0 +1,81 @@
+package redisft
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+type product struct {
+	ID    string  `redis:"text sortable"`
+	Name  string  `redis:"text"`
+	Price float64 `redis:"numeric sortable"`
+	Color string  `redis:"tag"`
+}
+
+func TestRepository_args(t *testing.T) {
+	t.Parallel()
+	repo := func() *Repository[product] { return NewRepo[product](&Client{}) }
+	tests := []struct {
+		name string
+		r    *Repository[product]
+		want string
+	}{
+		{"match all", repo().Search(), "[idx:product *]"},
+		{"sort and limit", repo().Search(NewNumericQuery("price").Gt(1)).SortBy("price", false).Limit(10, 5),
+			"[idx:product @price:(1 +inf] SORTBY price DESC LIMIT 10 5]"},
+		{"select", repo().Search().Select("id", "name"), "[idx:product * RETURN 2 id name]"},
+		{"ids only", repo().Search().Select("id").IDsOnly(), "[idx:product * NOCONTENT]"},
+		{"reset by search", repo().Search().IDsOnly().Search(), "[idx:product *]"},
+		{"in keys", repo().Search().InKeys("1", "2"), "[idx:product * INKEYS 2 product:1 product:2]"},
+		{"in fields", repo().Search().InFields("Name", "id"), "[idx:product * INFIELDS 2 name id]"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := fmt.Sprint(tc.r.args()); got != tc.want {
+				t.Errorf("args() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRepository_InFieldsValidation(t *testing.T) {
+	t.Parallel()
+	for _, f := range []string{"price", "missing"} {
+		r := NewRepo[product](&Client{}).Search().InFields(f)
+		if r.err == nil {
+			t.Errorf("InFields(%q): expected error", f)
+		}
+		if _, err := r.Exec(context.Background()); err != r.err {
+			t.Errorf("Exec() error = %v, want %v", err, r.err)
+		}
+	}
+}
+
+func TestRepository_InKeysEmpty(t *testing.T) {
+	t.Parallel()
+	var wishlist []string
+	// The Client has no pool: any command sent to Redis would panic.
+	r := NewRepo[product](&Client{}).Search().InKeys(wishlist...)
+	got, err := r.Exec(context.Background())
+	if err != nil || len(got) != 0 {
+		t.Fatalf("Exec() = %v, %v; want no hits", got, err)
+	}
+	ids, err := r.ExecIDs(context.Background())
+	if err != nil || len(ids) != 0 {
+		t.Errorf("ExecIDs() = %v, %v; want no ids", ids, err)
+	}
+}
+
+func TestRepository_Clone(t *testing.T) {
+	t.Parallel()
+	r := NewRepo[product](&Client{}).Search(NewTagQB("color").Any("red")).Select("name")
+	c := r.Clone().Query(NewNumericQuery("price").Gt(1)).Select("id")
+	if got, want := fmt.Sprint(r.args()), "[idx:product @color:{red} RETURN 1 name]"; got != want {
+		t.Errorf("original args() = %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(c.args()), "[idx:product @color:{red} @price:(1 +inf] RETURN 1 id]"; got != want {
+		t.Errorf("clone args() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+package redisft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrIndexExists     = errors.New("redisft: index already exists")
+	ErrIndexNotFound   = errors.New("redisft: index not found")
+	ErrNotFound        = errors.New("redisft: document not found")
+	ErrTimeout         = errors.New("redisft: timeout")
+	ErrUnexpectedReply = errors.New("redisft: unexpected reply format")
+
+	// ErrInvalidPageToken is returned by Page when the token is malformed,
+	// was signed with another key or belongs to a different query.
+	ErrInvalidPageToken = errors.New("redisft: invalid page token")
+
+	// ErrVersionConflict is returned by CompareAndUpdate when the stored
+	// version differs from the expected one.
+	ErrVersionConflict = errors.New("redisft: version conflict")
+
+	// ErrCacheBroadcast is returned by a write that succeeded but whose
+	// cache invalidation could not be published to other processes.
+	ErrCacheBroadcast = errors.New("redisft: write applied but cache invalidation not broadcast")
+)
+
+// QuerySyntaxError is returned when RediSearch rejects the rendered query.
+// Offset is the byte position RediSearch reported, or -1 if unknown.
+type QuerySyntaxError struct {
+	Query  string
+	Offset int
+	Near   string
+	Err    error
+}
+
+func (e *QuerySyntaxError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("redisft: query syntax error in %q: %v", e.Query, e.Err)
+	}
+	return fmt.Sprintf("redisft: query syntax error at offset %d near %q in %q", e.Offset, e.Near, e.Query)
+}
+
+func (e *QuerySyntaxError) Unwrap() error { return e.Err }
+
+// DecodeError is returned when a stored hash field cannot be decoded into
+// the struct field it maps to.
+type DecodeError struct {
+	Key   string
+	Field string
+	Value any
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("redisft: decode field %q (value %v): %v", e.Field, e.Value, e.Err)
+	}
+	return fmt.Sprintf("redisft: decode %s field %q (value %v): %v", e.Key, e.Field, e.Value, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+var syntaxErrRe = regexp.MustCompile(`(?i)syntax error at offset (\d+) near (\S+)`)
+
+// wrapErr maps a go-redis / RediSearch error onto the package's error
+// taxonomy. The original error stays reachable through errors.Unwrap.
+func wrapErr(err error, query string) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "index already exists"):
+		return fmt.Errorf("%w: %w", ErrIndexExists, err)
+	case strings.Contains(lower, "unknown index name"), strings.Contains(lower, "no such index"):
+		return fmt.Errorf("%w: %w", ErrIndexNotFound, err)
+	case strings.Contains(lower, "syntax error"):
+		se := &QuerySyntaxError{Query: query, Offset: -1, Err: err}
+		if m := syntaxErrRe.FindStringSubmatch(msg); m != nil {
+			se.Offset, _ = strconv.Atoi(m[1])
+			se.Near = strings.TrimSuffix(m[2], ":")
+		}
+		return se
+	case strings.Contains(lower, "timeout limit was reached"),
+		errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
+
+func unexpectedReply(cmd string, raw any) error {
+	return fmt.Errorf("%w: %s returned %T", ErrUnexpectedReply, cmd, raw)
+}
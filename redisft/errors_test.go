@@ -0,0 +1,67 @@
+package redisft
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWrapErr(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		msg  string
+		want error
+	}{
+		{"Index already exists", ErrIndexExists},
+		{"Unknown Index name", ErrIndexNotFound},
+		{"idx:product: no such index", ErrIndexNotFound},
+		{"Timeout limit was reached", ErrTimeout},
+	}
+	for _, tc := range tests {
+		t.Run(tc.msg, func(t *testing.T) {
+			t.Parallel()
+			orig := errors.New(tc.msg)
+			got := wrapErr(orig, "")
+			if !errors.Is(got, tc.want) {
+				t.Errorf("wrapErr(%q) = %v, want %v", tc.msg, got, tc.want)
+			}
+			if !errors.Is(got, orig) {
+				t.Errorf("wrapErr(%q) lost the original error", tc.msg)
+			}
+		})
+	}
+
+	if err := wrapErr(context.DeadlineExceeded, ""); !errors.Is(err, ErrTimeout) {
+		t.Errorf("deadline: %v", err)
+	}
+	other := errors.New("WRONGTYPE")
+	if err := wrapErr(other, ""); err != other {
+		t.Errorf("unrelated error changed: %v", err)
+	}
+}
+
+func TestWrapErr_Syntax(t *testing.T) {
+	t.Parallel()
+	orig := errors.New("Syntax error at offset 7 near craft")
+	var se *QuerySyntaxError
+	if err := wrapErr(orig, "@name:(craft"); !errors.As(err, &se) {
+		t.Fatalf("err = %v, want *QuerySyntaxError", err)
+	}
+	if se.Offset != 7 || se.Near != "craft" || se.Query != "@name:(craft" || !errors.Is(se, orig) {
+		t.Errorf("syntax error = %+v", se)
+	}
+}
+
+func TestFillStruct_DecodeError(t *testing.T) {
+	t.Parallel()
+	var p product
+	err := withKey(fillStruct(reflect.ValueOf(&p).Elem(), map[string]any{"price": "cheap"}), "product:1")
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("err = %v, want *DecodeError", err)
+	}
+	if de.Key != "product:1" || de.Field != "price" || de.Value != "cheap" {
+		t.Errorf("decode error = %+v", de)
+	}
+}
@@ -0,0 +1,164 @@
+package redisft
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// InsertOption customises Insert and InsertMany.
+type InsertOption func(*insertOptions)
+
+type insertOptions struct {
+	ttl      time.Duration
+	expireAt time.Time
+}
+
+// WithTTL makes the inserted documents expire after d (PEXPIRE). It takes
+// precedence over an `expire` struct field.
+func WithTTL(d time.Duration) InsertOption {
+	return func(o *insertOptions) { o.ttl, o.expireAt = d, time.Time{} }
+}
+
+// WithExpireAt makes the inserted documents expire at t (PEXPIREAT). It
+// takes precedence over an `expire` struct field.
+func WithExpireAt(t time.Time) InsertOption {
+	return func(o *insertOptions) { o.expireAt, o.ttl = t, 0 }
+}
+
+func newInsertOptions(opts []InsertOption) (insertOptions, error) {
+	var o insertOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.ttl < 0 {
+		return o, fmt.Errorf("redisft: negative TTL %s", o.ttl)
+	}
+	return o, nil
+}
+
+// expireToken marks a time.Time field as the document's expiry instant:
+//
+//	ExpiresAt time.Time `redis:"numeric expire"`
+//
+// It is not part of the index schema; a field tagged only `expire` is stored
+// but not indexed.
+const expireToken = "EXPIRE"
+
+// taggedField returns the first field of t whose `redis` tag contains token,
+// and its index (-1 if none).
+func taggedField(t reflect.Type, token string) (reflect.StructField, int) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		for _, tok := range strings.Fields(sf.Tag.Get("redis")) {
+			if strings.EqualFold(tok, token) {
+				return sf, i
+			}
+		}
+	}
+	return reflect.StructField{}, -1
+}
+
+// expireField returns the index of the field tagged `expire`, or -1.
+func expireField(t reflect.Type) (int, error) {
+	sf, i := taggedField(t, expireToken)
+	if i >= 0 && sf.Type != reflect.TypeOf(time.Time{}) {
+		return -1, fmt.Errorf("redisft: expire tag on %s: field must be time.Time, is %s", sf.Name, sf.Type)
+	}
+	return i, nil
+}
+
+// expiry is what a single document write should do about its TTL.
+type expiry struct {
+	ttl time.Duration
+	at  time.Time
+}
+
+func (e expiry) isSet() bool { return e.ttl > 0 || !e.at.IsZero() }
+
+// queue adds the expiry command for key to pipe, if any.
+func (e expiry) queue(ctx context.Context, pipe redis.Pipeliner, key string) {
+	switch {
+	case e.ttl > 0:
+		pipe.PExpire(ctx, key, e.ttl)
+	case !e.at.IsZero():
+		pipe.PExpireAt(ctx, key, e.at)
+	}
+}
+
+// docExpiry resolves the expiry of doc from the insert options, falling back
+// to its `expire` field when that is non-zero.
+func docExpiry(o insertOptions, doc any) (expiry, error) {
+	if o.ttl > 0 || !o.expireAt.IsZero() {
+		return expiry{ttl: o.ttl, at: o.expireAt}, nil
+	}
+	v := reflect.ValueOf(doc)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	i, err := expireField(v.Type())
+	if err != nil || i < 0 {
+		return expiry{}, err
+	}
+	return expiry{at: v.Field(i).Interface().(time.Time)}, nil
+}
+
+// Touch sets the document's time to live to ttl. It returns ErrNotFound when
+// the document does not exist.
+func (r *Repository[T]) Touch(ctx context.Context, id string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("redisft: Touch: TTL must be positive, got %s", ttl)
+	}
+	return r.expireCmd(ctx, OpTouch, "PEXPIRE", id, ttl.Milliseconds())
+}
+
+// Persist removes the document's expiry. It returns ErrNotFound when the
+// document does not exist or has no expiry.
+func (r *Repository[T]) Persist(ctx context.Context, id string) error {
+	return r.expireCmd(ctx, OpPersist, "PERSIST", id)
+}
+
+func (r *Repository[T]) expireCmd(ctx context.Context, op Op, name, id string, extra ...any) error {
+	rc := r.cli.Get()
+	args := append([]any{name, r.key(id)}, extra...)
+	var n int64
+	err := r.do(ctx, &CommandInfo{Op: op, Args: args}, func(ctx context.Context) (err error) {
+		n, err = rc.Do(ctx, args...).Int64()
+		return wrapErr(err, "")
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, r.key(id))
+	}
+	return nil
+}
+
+// TTL returns the remaining time to live of the document, or 0 when it does
+// not expire. It returns ErrNotFound when the document does not exist.
+func (r *Repository[T]) TTL(ctx context.Context, id string) (time.Duration, error) {
+	rc := r.cli.Get()
+	args := []any{"PTTL", r.key(id)}
+	var ms int64
+	err := r.do(ctx, &CommandInfo{Op: OpTTL, Args: args}, func(ctx context.Context) (err error) {
+		ms, err = rc.Do(ctx, args...).Int64()
+		return wrapErr(err, "")
+	})
+	switch {
+	case err != nil:
+		return 0, err
+	case ms == -2:
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, r.key(id))
+	case ms < 0:
+		return 0, nil
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
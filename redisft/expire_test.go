@@ -0,0 +1,64 @@
+package redisft
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type session struct {
+	User      string    `redis:"tag"`
+	ExpiresAt time.Time `redis:"numeric sortable expire"`
+	Seen      time.Time `redis:"expire"`
+}
+
+func TestExpireTag_NotInSchema(t *testing.T) {
+	t.Parallel()
+	got := fmt.Sprint(indexQuery("idx:session", "session:", reflect.TypeOf(session{})))
+	want := "[idx:session ON HASH PREFIX 1 session: SCHEMA user TAG expiresat NUMERIC SORTABLE]"
+	if got != want {
+		t.Errorf("indexQuery = %s, want %s", got, want)
+	}
+	if s := parseSchema(reflect.TypeOf(session{})); len(s) != 2 {
+		t.Errorf("schema = %+v", s)
+	}
+}
+
+func TestDocExpiry(t *testing.T) {
+	t.Parallel()
+	at := time.Unix(1700000000, 0)
+	doc := &session{ExpiresAt: at}
+	tests := []struct {
+		name string
+		opts []InsertOption
+		doc  any
+		want expiry
+	}{
+		{"from field", nil, doc, expiry{at: at}},
+		{"ttl wins", []InsertOption{WithTTL(time.Minute)}, doc, expiry{ttl: time.Minute}},
+		{"expire at wins", []InsertOption{WithExpireAt(at.Add(time.Hour))}, doc, expiry{at: at.Add(time.Hour)}},
+		{"last option wins", []InsertOption{WithExpireAt(at), WithTTL(time.Second)}, doc, expiry{ttl: time.Second}},
+		{"no expire field", nil, &product{}, expiry{}},
+	}
+	for _, tc := range tests {
+		o, err := newInsertOptions(tc.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := docExpiry(o, tc.doc)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: docExpiry = %+v, %v; want %+v", tc.name, got, err, tc.want)
+		}
+	}
+
+	type bad struct {
+		At int64 `redis:"numeric expire"`
+	}
+	if _, err := docExpiry(insertOptions{}, &bad{}); err == nil {
+		t.Error("expire on non-time field: expected error")
+	}
+	if _, err := newInsertOptions([]InsertOption{WithTTL(-time.Second)}); err == nil {
+		t.Error("negative TTL: expected error")
+	}
+}
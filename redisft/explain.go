@@ -0,0 +1,292 @@
+package redisft
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ExplainNode is one node of the execution plan returned by FT.EXPLAINCLI,
+// e.g. INTERSECT, UNION, TAG:@color or a single term.
+type ExplainNode struct {
+	Op       string
+	Children []*ExplainNode
+}
+
+// String renders the tree the same way redis-cli prints FT.EXPLAIN.
+func (n *ExplainNode) String() string {
+	var sb strings.Builder
+	n.write(&sb, 0)
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func (n *ExplainNode) write(sb *strings.Builder, depth int) {
+	pad := strings.Repeat("  ", depth)
+	if len(n.Children) == 0 {
+		sb.WriteString(pad + n.Op + "\n")
+		return
+	}
+	sb.WriteString(pad + n.Op + " {\n")
+	for _, c := range n.Children {
+		c.write(sb, depth+1)
+	}
+	sb.WriteString(pad + "}\n")
+}
+
+// Explain asks RediSearch how it parsed the current query.
+func (r *Repository[T]) Explain(ctx context.Context) (*ExplainNode, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	rc := r.cli.Get()
+	cmd := []any{"FT.EXPLAINCLI", r.index, r.query()}
+	var raw interface{}
+	err := r.do(ctx, &CommandInfo{Op: OpExplain, Query: r.query(), Args: cmd}, func(ctx context.Context) (err error) {
+		raw, err = ftDo(ctx, rc, r.route(), cmd...).Result()
+		return wrapErr(err, r.query())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	switch t := raw.(type) {
+	case []interface{}:
+		for _, l := range t {
+			lines = append(lines, replyString(l))
+		}
+	case string:
+		lines = strings.Split(t, "\n")
+	default:
+		return nil, unexpectedReply("FT.EXPLAINCLI", raw)
+	}
+	return parseExplain(lines), nil
+}
+
+// parseExplain turns the indented "OP {" / "}" lines into a tree. When the
+// plan has more than one top-level line they are wrapped in an unnamed root.
+func parseExplain(lines []string) *ExplainNode {
+	root := &ExplainNode{}
+	stack := []*ExplainNode{root}
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		top := stack[len(stack)-1]
+		switch {
+		case l == "}":
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case strings.HasSuffix(l, "{"):
+			n := &ExplainNode{Op: strings.TrimSpace(strings.TrimSuffix(l, "{"))}
+			top.Children = append(top.Children, n)
+			stack = append(stack, n)
+		default:
+			top.Children = append(top.Children, &ExplainNode{Op: l})
+		}
+	}
+	if len(root.Children) == 1 {
+		return root.Children[0]
+	}
+	return root
+}
+
+// ProfileIterator is one entry of the "Iterators profile" section.
+// Times are in milliseconds, as reported by RediSearch.
+type ProfileIterator struct {
+	Type     string
+	Term     string
+	Time     float64
+	Counter  int64
+	Size     int64
+	Children []ProfileIterator
+}
+
+// ProfileProcessor is one entry of the "Result processors profile" section.
+type ProfileProcessor struct {
+	Type    string
+	Time    float64
+	Counter int64
+}
+
+// Profile is the typed form of an FT.PROFILE reply.
+type Profile struct {
+	Total        int64
+	TotalTime    float64
+	ParsingTime  float64
+	PipelineTime float64
+	Iterators    []ProfileIterator
+	Processors   []ProfileProcessor
+}
+
+// String renders the profile as an indented text report.
+func (p *Profile) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "hits=%d total=%.3fms parsing=%.3fms pipeline=%.3fms\n",
+		p.Total, p.TotalTime, p.ParsingTime, p.PipelineTime)
+	sb.WriteString("iterators:\n")
+	for _, it := range p.Iterators {
+		writeIterator(&sb, it, 1)
+	}
+	sb.WriteString("processors:\n")
+	for _, pr := range p.Processors {
+		fmt.Fprintf(&sb, "  %s time=%.3fms counter=%d\n", pr.Type, pr.Time, pr.Counter)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func writeIterator(sb *strings.Builder, it ProfileIterator, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(it.Type)
+	if it.Term != "" {
+		sb.WriteString(" " + it.Term)
+	}
+	fmt.Fprintf(sb, " time=%.3fms counter=%d", it.Time, it.Counter)
+	if it.Size > 0 {
+		fmt.Fprintf(sb, " size=%d", it.Size)
+	}
+	sb.WriteByte('\n')
+	for _, c := range it.Children {
+		writeIterator(sb, c, depth+1)
+	}
+}
+
+// Profile runs the current query through FT.PROFILE … SEARCH.
+func (r *Repository[T]) Profile(ctx context.Context) (*Profile, error) {
+	args := r.args()
+	cmd := append([]any{"FT.PROFILE", r.index, "SEARCH", "QUERY"}, args[1:]...)
+	return r.profile(ctx, cmd)
+}
+
+// ProfileAggregate runs the current query through FT.PROFILE … AGGREGATE.
+func (r *Repository[T]) ProfileAggregate(ctx context.Context) (*Profile, error) {
+	cmd := []any{"FT.PROFILE", r.index, "AGGREGATE", "QUERY", r.query()}
+	if r.sSet {
+		order := "ASC"
+		if !r.sAsc {
+			order = "DESC"
+		}
+		cmd = append(cmd, "SORTBY", 2, "@"+r.sField, order)
+	}
+	if r.limSet {
+		cmd = append(cmd, "LIMIT", r.off, r.lim)
+	}
+	return r.profile(ctx, cmd)
+}
+
+func (r *Repository[T]) profile(ctx context.Context, cmd []any) (*Profile, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	rc := r.cli.Get()
+	var raw interface{}
+	err := r.do(ctx, &CommandInfo{Op: OpProfile, Query: r.query(), Args: cmd}, func(ctx context.Context) (err error) {
+		raw, err = ftDo(ctx, rc, r.route(), cmd...).Result()
+		return wrapErr(err, r.query())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseProfile(raw)
+}
+
+func parseProfile(raw interface{}) (*Profile, error) {
+	top, ok := raw.([]interface{})
+	if !ok || len(top) < 2 {
+		return nil, unexpectedReply("FT.PROFILE", raw)
+	}
+	p := &Profile{}
+	if res, ok := top[0].([]interface{}); ok && len(res) > 0 {
+		p.Total = replyInt(res[0])
+	}
+
+	sections, _ := top[1].([]interface{})
+	for _, s := range sections {
+		entry, ok := s.([]interface{})
+		if !ok || len(entry) < 2 {
+			continue
+		}
+		switch replyString(entry[0]) {
+		case "Total profile time":
+			p.TotalTime = replyFloat(entry[1])
+		case "Parsing time":
+			p.ParsingTime = replyFloat(entry[1])
+		case "Pipeline creation time":
+			p.PipelineTime = replyFloat(entry[1])
+		case "Iterators profile":
+			for _, it := range entry[1:] {
+				if fa, ok := it.([]interface{}); ok && len(fa) > 0 {
+					p.Iterators = append(p.Iterators, parseIterator(fa))
+				}
+			}
+		case "Result processors profile":
+			for _, pr := range entry[1:] {
+				fa, ok := pr.([]interface{})
+				if !ok {
+					continue
+				}
+				var proc ProfileProcessor
+				for i := 0; i+1 < len(fa); i += 2 {
+					switch replyString(fa[i]) {
+					case "Type":
+						proc.Type = replyString(fa[i+1])
+					case "Time":
+						proc.Time = replyFloat(fa[i+1])
+					case "Counter":
+						proc.Counter = replyInt(fa[i+1])
+					}
+				}
+				p.Processors = append(p.Processors, proc)
+			}
+		}
+	}
+	return p, nil
+}
+
+// parseIterator decodes a flat key/value iterator entry. Child iterators are
+// either listed after the "Child iterators" key or wrapped in one array,
+// depending on the RediSearch version.
+func parseIterator(fa []interface{}) ProfileIterator {
+	var it ProfileIterator
+	for i := 0; i < len(fa); i++ {
+		key := replyString(fa[i])
+		if key == "Child iterators" {
+			for _, c := range fa[i+1:] {
+				cf, ok := c.([]interface{})
+				if !ok || len(cf) == 0 {
+					continue
+				}
+				if _, nested := cf[0].([]interface{}); nested {
+					for _, cc := range cf {
+						if ccf, ok := cc.([]interface{}); ok {
+							it.Children = append(it.Children, parseIterator(ccf))
+						}
+					}
+					continue
+				}
+				it.Children = append(it.Children, parseIterator(cf))
+			}
+			break
+		}
+		if i+1 >= len(fa) {
+			break
+		}
+		val := fa[i+1]
+		i++
+		switch key {
+		case "Type":
+			it.Type = replyString(val)
+		case "Term", "Query":
+			it.Term = replyString(val)
+		case "Time":
+			it.Time = replyFloat(val)
+		case "Counter":
+			it.Counter = replyInt(val)
+		case "Size":
+			it.Size = replyInt(val)
+		}
+	}
+	return it
+}
@@ -0,0 +1,77 @@
+package redisft
+
+import (
+	"testing"
+)
+
+func TestParseExplain(t *testing.T) {
+	t.Parallel()
+	lines := []string{
+		"INTERSECT {",
+		"  UNION {",
+		"    war*",
+		"    *craft",
+		"  }",
+		"  @price:NUMERIC {50.000000 <= @price <= 120.000000}",
+		"}",
+		"",
+	}
+	root := parseExplain(lines)
+	if root.Op != "INTERSECT" || len(root.Children) != 2 {
+		t.Fatalf("root = %+v", root)
+	}
+	if u := root.Children[0]; u.Op != "UNION" || len(u.Children) != 2 || u.Children[1].Op != "*craft" {
+		t.Errorf("union = %+v", u)
+	}
+	if n := root.Children[1]; n.Op != "@price:NUMERIC {50.000000 <= @price <= 120.000000}" || len(n.Children) != 0 {
+		t.Errorf("numeric leaf = %+v", n)
+	}
+
+	want := "INTERSECT {\n  UNION {\n    war*\n    *craft\n  }\n  @price:NUMERIC {50.000000 <= @price <= 120.000000}\n}"
+	if got := root.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestParseProfile(t *testing.T) {
+	t.Parallel()
+	raw := []interface{}{
+		[]interface{}{int64(2), "product:1", []interface{}{}, "product:2", []interface{}{}},
+		[]interface{}{
+			[]interface{}{"Total profile time", "0.5"},
+			[]interface{}{"Parsing time", "0.1"},
+			[]interface{}{"Pipeline creation time", "0.05"},
+			[]interface{}{"Iterators profile",
+				[]interface{}{"Type", "INTERSECT", "Time", "0.2", "Counter", int64(2), "Child iterators",
+					[]interface{}{"Type", "TEXT", "Term", "book", "Time", "0.1", "Counter", int64(3), "Size", int64(3)},
+					[]interface{}{"Type", "NUMERIC", "Term", "10 - 20", "Time", "0.05", "Counter", int64(2)},
+				},
+			},
+			[]interface{}{"Result processors profile",
+				[]interface{}{"Type", "Index", "Time", "0.01", "Counter", int64(2)},
+				[]interface{}{"Type", "Sorter", "Time", "0.02", "Counter", int64(2)},
+			},
+		},
+	}
+
+	p, err := parseProfile(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Total != 2 || p.TotalTime != 0.5 || p.ParsingTime != 0.1 || p.PipelineTime != 0.05 {
+		t.Errorf("header = %+v", p)
+	}
+	if len(p.Iterators) != 1 || len(p.Iterators[0].Children) != 2 {
+		t.Fatalf("iterators = %+v", p.Iterators)
+	}
+	if c := p.Iterators[0].Children[0]; c.Type != "TEXT" || c.Term != "book" || c.Size != 3 {
+		t.Errorf("child = %+v", c)
+	}
+	if len(p.Processors) != 2 || p.Processors[1].Type != "Sorter" {
+		t.Errorf("processors = %+v", p.Processors)
+	}
+
+	if _, err := parseProfile("OK"); err == nil {
+		t.Error("expected error for malformed reply")
+	}
+}
@@ -0,0 +1,315 @@
+package redisft
+
+import (
+	"context"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Format is the file format of Export and Import.
+type Format int
+
+const (
+	// JSONL writes one {"id": ..., "doc": {...}} object per line, the
+	// document encoded with encoding/json.
+	JSONL Format = iota
+	// CSV writes a header of "_id" followed by the hash field names, and
+	// one row per document with values encoded as they are stored in Redis.
+	CSV
+)
+
+func (f Format) String() string {
+	switch f {
+	case JSONL:
+		return "jsonl"
+	case CSV:
+		return "csv"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// csvIDColumn holds the document ID in exported CSV.
+const csvIDColumn = "_id"
+
+// scanCount is the COUNT hint of each SCAN call.
+const scanCount = 500
+
+type exportLine[T any] struct {
+	ID  string `json:"id"`
+	Doc *T     `json:"doc"`
+}
+
+type scanner interface {
+	ScanType(ctx context.Context, cursor uint64, match string, count int64, keyType string) *redis.ScanCmd
+}
+
+// scanKeys calls fn with each batch of hash keys under the repository
+// prefix, starting at cursor, and the cursor that resumes after the batch
+// (0 after the last one). On a cluster every master is scanned in turn and
+// only cursor 0 is accepted. Keys created or deleted meanwhile may be missed
+// or seen twice, as with SCAN. count is the SCAN COUNT hint.
+func (r *Repository[T]) scanKeys(ctx context.Context, cursor uint64, count int64, fn func(keys []string, next uint64) error) error {
+	return r.cli.scanKeys(ctx, r.index, r.prefix, cursor, count, fn)
+}
+
+func (c *Client) scanKeys(ctx context.Context, index, prefix string, cursor uint64, count int64, fn func(keys []string, next uint64) error) error {
+	rc := c.Get()
+	nodes := []scanner{}
+	if cc, ok := rc.(*redis.ClusterClient); ok {
+		if cursor != 0 {
+			return errors.New("redisft: resuming a scan is not supported on a cluster")
+		}
+		clients, err := clusterMasters(ctx, cc)
+		if err != nil {
+			return err
+		}
+		for _, node := range clients {
+			nodes = append(nodes, node)
+		}
+	} else if sc, ok := rc.(scanner); ok {
+		nodes = append(nodes, sc)
+	} else {
+		return fmt.Errorf("redisft: %T does not support SCAN", rc)
+	}
+
+	match := globEscape(prefix) + "*"
+	for _, node := range nodes {
+		for {
+			var keys []string
+			next := cursor
+			info := &CommandInfo{Op: OpScan, Index: index, Args: []any{"SCAN", cursor, "MATCH", match}}
+			err := c.do(ctx, info, func(ctx context.Context) (err error) {
+				keys, next, err = node.ScanType(ctx, cursor, match, count, "hash").Result()
+				return wrapErr(err, "")
+			})
+			if err != nil {
+				return err
+			}
+			if len(keys) > 0 || next == 0 {
+				if err := fn(keys, next); err != nil {
+					return err
+				}
+			}
+			if cursor = next; cursor == 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// globEscape quotes the glob metacharacters of s for MATCH.
+func globEscape(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[]\`, c) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// fetch loads the hashes at keys in one pipeline. Keys that vanished since
+// they were scanned are left out.
+func (r *Repository[T]) fetch(ctx context.Context, keys []string) ([]map[string]string, error) {
+	return r.cli.fetch(ctx, r.index, keys)
+}
+
+func (c *Client) fetch(ctx context.Context, index string, keys []string) ([]map[string]string, error) {
+	rc := c.Get()
+	out := make([]map[string]string, 0, len(keys))
+	err := c.do(ctx, &CommandInfo{Op: OpScan, Index: index}, func(ctx context.Context) error {
+		out = out[:0]
+		pipe := rc.Pipeline()
+		cmds := make([]*redis.StringStringMapCmd, len(keys))
+		for i, k := range keys {
+			cmds[i] = pipe.HGetAll(ctx, k)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return wrapErr(err, "")
+		}
+		for _, cmd := range cmds {
+			out = append(out, cmd.Val())
+		}
+		return nil
+	})
+	return out, err
+}
+
+// ScanHashes calls fn with each batch of hashes whose key starts with
+// prefix, as raw field values, without going through a repository or an
+// index. It walks the keyspace with SCAN like Export, visiting every master
+// of a cluster; hashes deleted meanwhile are left out of the batch.
+func (c *Client) ScanHashes(ctx context.Context, prefix string, fn func(keys []string, hashes []map[string]string) error) error {
+	return c.scanKeys(ctx, "", prefix, 0, scanCount, func(keys []string, _ uint64) error {
+		hashes, err := c.fetch(ctx, "", keys)
+		if err != nil {
+			return err
+		}
+		n := 0
+		for i, m := range hashes {
+			if len(m) > 0 {
+				keys[n], hashes[n] = keys[i], m
+				n++
+			}
+		}
+		if n == 0 {
+			return nil
+		}
+		return fn(keys[:n], hashes[:n])
+	})
+}
+
+// Export writes every document under the repository's key prefix to w and
+// returns how many it wrote. It walks the keyspace with SCAN, so it is not
+// bound by search limits, and decodes each hash into T; a hash that does not
+// decode aborts the export. Expiry is not exported beyond an `expire` field.
+func (r *Repository[T]) Export(ctx context.Context, w io.Writer, format Format) (int, error) {
+	var enc func(id string, doc *T) error
+	var flush func() error
+	switch format {
+	case JSONL:
+		je := json.NewEncoder(w)
+		enc = func(id string, doc *T) error { return je.Encode(exportLine[T]{ID: id, Doc: doc}) }
+		flush = func() error { return nil }
+	case CSV:
+		cw := csv.NewWriter(w)
+		fields := fieldNames(reflect.TypeOf(*new(T)))
+		if err := cw.Write(append([]string{csvIDColumn}, fields...)); err != nil {
+			return 0, err
+		}
+		row := make([]string, len(fields)+1)
+		enc = func(id string, doc *T) error {
+			m, err := structToMap(doc)
+			if err != nil {
+				return err
+			}
+			row[0] = id
+			for i, f := range fields {
+				row[i+1] = ""
+				if v, ok := m[f]; ok {
+					row[i+1] = hashValue(v)
+				}
+			}
+			return cw.Write(row)
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		return 0, fmt.Errorf("redisft: unknown export format %s", format)
+	}
+
+	n := 0
+	err := r.scanKeys(ctx, 0, scanCount, func(keys []string, _ uint64) error {
+		hashes, err := r.fetch(ctx, keys)
+		if err != nil {
+			return err
+		}
+		for i, m := range hashes {
+			if len(m) == 0 {
+				continue
+			}
+			doc, err := decodeHash[T](keys[i], m)
+			if err != nil {
+				return err
+			}
+			if err := enc(strings.TrimPrefix(keys[i], r.prefix), doc); err != nil {
+				return err
+			}
+			n++
+		}
+		return flush()
+	})
+	return n, err
+}
+
+// Import restores documents written by Export under their original IDs,
+// using the bulk loader: opts controls batching, concurrency and progress,
+// and records that fail are collected in the result. opts.IDField is not
+// needed. The `version` field in the file is ignored: as with Insert, each
+// write increments the version stored under the ID, so documents imported
+// into an empty database start again at version 1.
+func (r *Repository[T]) Import(ctx context.Context, rd io.Reader, format Format, opts BulkOptions) (*BulkResult, error) {
+	switch format {
+	case JSONL:
+		return r.load(ctx, opts, jsonlSource(rd, func(line []byte) (string, *T, error) {
+			var l exportLine[T]
+			if err := json.Unmarshal(line, &l); err != nil {
+				return "", nil, err
+			}
+			if l.ID == "" {
+				return "", l.Doc, errors.New("empty ID")
+			}
+			if l.Doc == nil {
+				return l.ID, nil, errors.New("missing doc")
+			}
+			return l.ID, l.Doc, nil
+		}))
+	case CSV:
+		cr, header, err := csvHeader(rd)
+		if err != nil {
+			return nil, err
+		}
+		idCol := -1
+		for i, h := range header {
+			if h == csvIDColumn {
+				idCol = i
+			}
+		}
+		if idCol < 0 {
+			return nil, fmt.Errorf("redisft: Import: CSV has no %q column", csvIDColumn)
+		}
+		cols, err := csvColumns(reflect.TypeOf(*new(T)), header, opts.Columns, csvIDColumn)
+		if err != nil {
+			return nil, err
+		}
+		return r.load(ctx, opts, csvSource(cr, cols, func(row []string, _ *T) (string, error) {
+			if row[idCol] == "" {
+				return "", errors.New("empty ID")
+			}
+			return row[idCol], nil
+		}))
+	}
+	return nil, fmt.Errorf("redisft: unknown import format %s", format)
+}
+
+// hashValue renders v the way go-redis sends it as a command argument, so
+// it can be compared with a stored hash field.
+func hashValue(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	case bool:
+		if t {
+			return "1"
+		}
+		return "0"
+	case float32:
+		return strconv.FormatFloat(float64(t), 'f', -1, 64)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case encoding.BinaryMarshaler:
+		b, err := t.MarshalBinary()
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,39 @@
+package redisft
+
+import "testing"
+
+func TestGlobEscape(t *testing.T) {
+	t.Parallel()
+	tests := []struct{ in, want string }{
+		{"product:", "product:"},
+		{"{product}:", "{product}:"},
+		{`a*b?[c]\`, `a\*b\?\[c\]\\`},
+	}
+	for _, tt := range tests {
+		if got := globEscape(tt.in); got != tt.want {
+			t.Errorf("globEscape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHashValue(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in   any
+		want string
+	}{
+		{"red", "red"},
+		{int64(42), "42"},
+		{uint8(7), "7"},
+		{19.9, "19.9"},
+		{float32(0.5), "0.5"},
+		{true, "1"},
+		{false, "0"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := hashValue(tt.in); got != tt.want {
+			t.Errorf("hashValue(%#v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
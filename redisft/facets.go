@@ -0,0 +1,504 @@
+package redisft
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Facet is the number of hits per value of one TAG field, most frequent
+// first.
+type Facet struct {
+	Field  string
+	Values []FacetValue
+}
+
+// FacetValue is one tag value and the number of hits carrying it.
+type FacetValue struct {
+	Value string
+	Count int64
+}
+
+// RangeFacet is the number of hits per numeric range of one field, in
+// ascending order.
+type RangeFacet struct {
+	Field   string
+	Buckets []Bucket
+}
+
+// Bucket is a numeric range and the number of hits in it. Histogram buckets
+// include Lo and exclude Hi; RangeFacets buckets have the bounds of their
+// interval, with an open end as -Inf or +Inf. JSON has no infinities, so
+// open ends are encoded as null.
+type Bucket struct {
+	Lo, Hi float64
+	Count  int64
+}
+
+// jsonBucket is Bucket with open ends as nil.
+type jsonBucket struct {
+	Lo, Hi *float64
+	Count  int64
+}
+
+func (b Bucket) MarshalJSON() ([]byte, error) {
+	jb := jsonBucket{Count: b.Count}
+	if !math.IsInf(b.Lo, 0) {
+		jb.Lo = &b.Lo
+	}
+	if !math.IsInf(b.Hi, 0) {
+		jb.Hi = &b.Hi
+	}
+	return json.Marshal(jb)
+}
+
+func (b *Bucket) UnmarshalJSON(data []byte) error {
+	var jb jsonBucket
+	if err := json.Unmarshal(data, &jb); err != nil {
+		return err
+	}
+	*b = Bucket{Lo: math.Inf(-1), Hi: math.Inf(1), Count: jb.Count}
+	if jb.Lo != nil {
+		b.Lo = *jb.Lo
+	}
+	if jb.Hi != nil {
+		b.Hi = *jb.Hi
+	}
+	return nil
+}
+
+// FacetResult is a page of hits plus the facets of the whole result set.
+type FacetResult[T any] struct {
+	Total  int64
+	Items  []T
+	Facets []Facet
+	Ranges []RangeFacet
+}
+
+// defaultFacetLimit is the number of values kept per facet by default, and
+// maxFacetValues the bound sent for FacetLimit(0): without MAX, SORTBY keeps
+// only 10 rows.
+const (
+	defaultFacetLimit = 10
+	maxFacetValues    = 10000
+)
+
+// facetAlias names the split tag values, and the histogram bucket number,
+// inside the aggregation.
+const facetAlias = "__facet"
+
+// Histograms return at most maxHistogramBuckets buckets; autoHistogramBuckets
+// is the number an automatic width aims for.
+const (
+	maxHistogramBuckets  = 1000
+	autoHistogramBuckets = 10
+)
+
+// rangeFacet is a requested RangeFacets or Histogram.
+type rangeFacet struct {
+	field     string
+	intervals []interval // RangeFacets
+	width     float64    // Histogram; <= 0 picks one
+}
+
+// Facets asks for value counts of the given TAG fields over all hits of the
+// query, returned by ExecFacets and by the first Page; RangeFacets and
+// Histogram do the same for NUMERIC fields. A document with
+// several tags counts once for each. Fields that are not TAG fields make
+// the query fail on execution.
+func (r *Repository[T]) Facets(fields ...string) *Repository[T] {
+	for _, f := range fields {
+		fs, ok := r.schema.field(f)
+		if !ok {
+			r.setErr(fmt.Errorf("redisft: Facets: unknown field %q", f))
+			continue
+		}
+		if fs.typ != FieldTag {
+			r.setErr(fmt.Errorf("redisft: Facets: field %q is %s, not TAG", f, fs.typ))
+			continue
+		}
+		r.facets = append(r.facets, fs.name)
+	}
+	return r
+}
+
+// FacetLimit keeps the n most frequent values of each facet; n <= 0 keeps
+// all of them, up to 10000. Default 10.
+func (r *Repository[T]) FacetLimit(n int) *Repository[T] {
+	r.facetLimit = n
+	if n <= 0 {
+		r.facetLimit = -1
+	}
+	return r
+}
+
+// RangeFacets asks for the number of hits in each interval of q, e.g.
+//
+//	NewNumericQuery("price").Lt(50).OrRange(50, 100, true, false).Ge(100)
+//
+// Each interval is counted on its own, in the order given, so overlapping
+// intervals are not merged. Unlike Histogram, which groups with FT.AGGREGATE
+// APPLY, the counts come from one FT.SEARCH ... LIMIT 0 0 per interval, sent
+// in the same pipeline as the search. The field must be NUMERIC.
+func (r *Repository[T]) RangeFacets(q *NumericQuery) *Repository[T] {
+	fs, err := r.numericFacetField("RangeFacets", q.field)
+	if err != nil {
+		r.setErr(err)
+		return r
+	}
+	if len(q.intervals) == 0 {
+		r.setErr(fmt.Errorf("redisft: RangeFacets: no intervals for %q", q.field))
+		return r
+	}
+	r.ranges = append(r.ranges, rangeFacet{field: fs.name, intervals: append([]interval(nil), q.intervals...)})
+	return r
+}
+
+// Histogram asks for the number of hits per bucket of width along a NUMERIC
+// field, bucket k holding values in [k*width, (k+1)*width). Empty buckets
+// are left out. A width <= 0 picks a round width giving about ten buckets
+// between the smallest and largest value, at the cost of one more round
+// trip. At most 1000 buckets are returned.
+func (r *Repository[T]) Histogram(field string, width float64) *Repository[T] {
+	fs, err := r.numericFacetField("Histogram", field)
+	if err != nil {
+		r.setErr(err)
+		return r
+	}
+	if math.IsNaN(width) || math.IsInf(width, 0) {
+		r.setErr(fmt.Errorf("redisft: Histogram: invalid width %v", width))
+		return r
+	}
+	r.ranges = append(r.ranges, rangeFacet{field: fs.name, width: width})
+	return r
+}
+
+func (r *Repository[T]) numericFacetField(method, field string) (fieldSpec, error) {
+	fs, ok := r.schema.field(field)
+	if !ok {
+		return fs, fmt.Errorf("redisft: %s: unknown field %q", method, field)
+	}
+	if fs.typ != FieldNumeric {
+		return fs, fmt.Errorf("redisft: %s: field %q is %s, not NUMERIC", method, field, fs.typ)
+	}
+	return fs, nil
+}
+
+// hasFacets reports whether the query asks for any facet.
+func (r *Repository[T]) hasFacets() bool { return len(r.facets) > 0 || len(r.ranges) > 0 }
+
+// facetArgs builds the FT.AGGREGATE command counting the values of field.
+func (r *Repository[T]) facetArgs(field string) []any {
+	args := []any{"FT.AGGREGATE", r.index, r.query(),
+		"LOAD", 1, "@" + field,
+		"APPLY", fmt.Sprintf("split(@%s, \",\")", field), "AS", facetAlias,
+		"GROUPBY", 1, "@" + facetAlias,
+		"REDUCE", "COUNT", 0, "AS", "count",
+		"SORTBY", 4, "@count", "DESC", "@" + facetAlias, "ASC",
+	}
+	n := r.facetLimit
+	switch {
+	case n == 0:
+		n = defaultFacetLimit
+	case n < 0:
+		n = maxFacetValues
+	}
+	return append(args, "MAX", n)
+}
+
+// facetCheck reports why the query cannot compute facets.
+func (r *Repository[T]) facetCheck() error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.inKeysSet || len(r.inFields) > 0 {
+		return errors.New("redisft: Facets cannot be combined with InKeys or InFields")
+	}
+	return nil
+}
+
+// histogramArgs builds the FT.AGGREGATE command counting the values of
+// field per bucket of width.
+func (r *Repository[T]) histogramArgs(field string, width float64) []any {
+	return []any{"FT.AGGREGATE", r.index, r.query(),
+		"LOAD", 1, "@" + field,
+		"FILTER", fmt.Sprintf("exists(@%s)", field),
+		"APPLY", fmt.Sprintf("floor(@%s/%s)", field, strconv.FormatFloat(width, 'g', -1, 64)), "AS", facetAlias,
+		"GROUPBY", 1, "@" + facetAlias,
+		"REDUCE", "COUNT", 0, "AS", "count",
+		"SORTBY", 2, "@" + facetAlias, "ASC", "MAX", maxHistogramBuckets,
+	}
+}
+
+// rangeArgs builds the FT.SEARCH command counting the hits in iv. Counting
+// each interval with FT.AGGREGATE would need an APPLY expression per
+// interval and still one GROUPBY each, since intervals may overlap.
+func (r *Repository[T]) rangeArgs(field string, iv interval) []any {
+	q := iv.toString(field)
+	if len(r.qParts) > 0 {
+		q = r.query() + " " + q
+	}
+	return []any{"FT.SEARCH", r.index, q, "LIMIT", 0, 0}
+}
+
+// facetPlan holds the commands computing the facets of a query and decodes
+// their replies.
+type facetPlan struct {
+	cmds   [][]any
+	decode func(replies []any) ([]Facet, []RangeFacet, error)
+}
+
+// planFacets prepares the facet commands. Histograms without a width are
+// sized first, in one pipeline.
+func (r *Repository[T]) planFacets(ctx context.Context) (*facetPlan, error) {
+	if err := r.facetCheck(); err != nil {
+		return nil, err
+	}
+	widths, err := r.histogramWidths(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p := &facetPlan{}
+	for _, f := range r.facets {
+		p.cmds = append(p.cmds, r.facetArgs(f))
+	}
+	for i, rf := range r.ranges {
+		switch {
+		case rf.intervals != nil:
+			for _, iv := range rf.intervals {
+				p.cmds = append(p.cmds, r.rangeArgs(rf.field, iv))
+			}
+		case widths[i] > 0:
+			p.cmds = append(p.cmds, r.histogramArgs(rf.field, widths[i]))
+		}
+	}
+	p.decode = func(replies []any) ([]Facet, []RangeFacet, error) {
+		var facets []Facet
+		for _, f := range r.facets {
+			facet, err := parseFacet(f, replies[0])
+			if err != nil {
+				return nil, nil, err
+			}
+			facets = append(facets, facet)
+			replies = replies[1:]
+		}
+		var ranges []RangeFacet
+		for i, rf := range r.ranges {
+			out := RangeFacet{Field: rf.field, Buckets: []Bucket{}}
+			switch {
+			case rf.intervals != nil:
+				for _, iv := range rf.intervals {
+					rows, ok := replies[0].([]interface{})
+					if !ok || len(rows) == 0 {
+						return nil, nil, unexpectedReply("FT.SEARCH", replies[0])
+					}
+					out.Buckets = append(out.Buckets, Bucket{Lo: iv.lo.val, Hi: iv.hi.val, Count: replyInt(rows[0])})
+					replies = replies[1:]
+				}
+			case widths[i] > 0:
+				buckets, err := parseHistogram(widths[i], replies[0])
+				if err != nil {
+					return nil, nil, err
+				}
+				out.Buckets = buckets
+				replies = replies[1:]
+			}
+			ranges = append(ranges, out)
+		}
+		return facets, ranges, nil
+	}
+	return p, nil
+}
+
+// histogramWidths returns the bucket width of every entry of r.ranges that
+// is a histogram, picking one where none was given; 0 means there are no
+// values to count.
+func (r *Repository[T]) histogramWidths(ctx context.Context) ([]float64, error) {
+	widths := make([]float64, len(r.ranges))
+	var cmds [][]any
+	var auto []int
+	for i, rf := range r.ranges {
+		switch {
+		case rf.intervals != nil:
+		case rf.width > 0:
+			widths[i] = rf.width
+		default:
+			auto = append(auto, i)
+			cmds = append(cmds, []any{"FT.AGGREGATE", r.index, r.query(),
+				"LOAD", 1, "@" + rf.field,
+				"FILTER", fmt.Sprintf("exists(@%s)", rf.field),
+				"GROUPBY", 0,
+				"REDUCE", "MIN", 1, "@" + rf.field, "AS", "min",
+				"REDUCE", "MAX", 1, "@" + rf.field, "AS", "max",
+			})
+		}
+	}
+	if len(cmds) == 0 {
+		return widths, nil
+	}
+	rc := r.cli.Get()
+	var replies []any
+	err := r.do(ctx, &CommandInfo{Op: OpAggregate, Query: r.query()}, func(ctx context.Context) error {
+		var err error
+		replies, err = ftPipeline(ctx, rc, r.route(), cmds)
+		return wrapErr(err, r.query())
+	})
+	if err != nil {
+		return nil, err
+	}
+	for k, i := range auto {
+		rows, ok := replies[k].([]interface{})
+		if !ok || len(rows) == 0 {
+			return nil, unexpectedReply("FT.AGGREGATE", replies[k])
+		}
+		if len(rows) < 2 {
+			continue // no values
+		}
+		kv, _ := rows[1].([]interface{})
+		var lo, hi float64
+		for j := 0; j+1 < len(kv); j += 2 {
+			switch replyString(kv[j]) {
+			case "min":
+				lo = replyFloat(kv[j+1])
+			case "max":
+				hi = replyFloat(kv[j+1])
+			}
+		}
+		widths[i] = niceWidth(lo, hi, autoHistogramBuckets)
+	}
+	return widths, nil
+}
+
+// niceWidth returns a width of 1, 2 or 5 times a power of ten that splits
+// [lo, hi] into about n buckets.
+func niceWidth(lo, hi float64, n int) float64 {
+	span := hi - lo
+	if span <= 0 {
+		return 1
+	}
+	raw := span / float64(n)
+	pow := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5} {
+		if m*pow >= raw {
+			return m * pow
+		}
+	}
+	return 10 * pow
+}
+
+// parseHistogram decodes the FT.AGGREGATE reply of histogramArgs.
+func parseHistogram(width float64, raw any) ([]Bucket, error) {
+	rows, ok := raw.([]interface{})
+	if !ok || len(rows) == 0 {
+		return nil, unexpectedReply("FT.AGGREGATE", raw)
+	}
+	buckets := []Bucket{}
+	for _, row := range rows[1:] {
+		kv, _ := row.([]interface{})
+		var b Bucket
+		found := false
+		for j := 0; j+1 < len(kv); j += 2 {
+			switch replyString(kv[j]) {
+			case facetAlias:
+				k := replyFloat(kv[j+1])
+				b.Lo, b.Hi, found = k*width, (k+1)*width, true
+			case "count":
+				b.Count = replyInt(kv[j+1])
+			}
+		}
+		if found {
+			buckets = append(buckets, b)
+		}
+	}
+	return buckets, nil
+}
+
+// ExecFacets runs the query and its facets in one pipeline and returns the
+// decoded hits (as limited by Limit) with the facets of all hits. The
+// result cache is not used.
+func (r *Repository[T]) ExecFacets(ctx context.Context) (*FacetResult[T], error) {
+	plan, err := r.planFacets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rc := r.cli.Get()
+	cmds := append([][]any{append([]any{"FT.SEARCH"}, r.args()...)}, plan.cmds...)
+	info := &CommandInfo{Op: OpSearch, Query: r.query()}
+	var replies []any
+	err = r.do(ctx, info, func(ctx context.Context) error {
+		var err error
+		replies, err = ftPipeline(ctx, rc, r.route(), cmds)
+		if err != nil {
+			return wrapErr(err, info.Query)
+		}
+		rows, ok := replies[0].([]interface{})
+		if !ok || len(rows) == 0 {
+			return unexpectedReply("FT.SEARCH", replies[0])
+		}
+		info.Hits = replyInt(rows[0])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	total, hits := parseHits(replies[0].([]interface{}), r.noContent)
+	items, err := decodeHits[T](hits)
+	if err != nil {
+		return nil, err
+	}
+	res := &FacetResult[T]{Total: total, Items: items}
+	if res.Facets, res.Ranges, err = plan.decode(replies[1:]); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// facetCounts runs only the facet commands of the query.
+func (r *Repository[T]) facetCounts(ctx context.Context) ([]Facet, []RangeFacet, error) {
+	plan, err := r.planFacets(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	rc := r.cli.Get()
+	var replies []any
+	if len(plan.cmds) > 0 {
+		err = r.do(ctx, &CommandInfo{Op: OpAggregate, Query: r.query()}, func(ctx context.Context) error {
+			var err error
+			replies, err = ftPipeline(ctx, rc, r.route(), plan.cmds)
+			return wrapErr(err, r.query())
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return plan.decode(replies)
+}
+
+// parseFacet decodes the FT.AGGREGATE reply of facetArgs.
+func parseFacet(field string, raw any) (Facet, error) {
+	rows, ok := raw.([]interface{})
+	if !ok || len(rows) == 0 {
+		return Facet{}, unexpectedReply("FT.AGGREGATE", raw)
+	}
+	f := Facet{Field: field, Values: []FacetValue{}}
+	for _, row := range rows[1:] {
+		kv, _ := row.([]interface{})
+		var v FacetValue
+		for j := 0; j+1 < len(kv); j += 2 {
+			switch replyString(kv[j]) {
+			case facetAlias:
+				v.Value = replyString(kv[j+1])
+			case "count":
+				v.Count = replyInt(kv[j+1])
+			}
+		}
+		if strings.TrimSpace(v.Value) != "" {
+			f.Values = append(f.Values, v)
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,115 @@
+package redisft
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestRepository_facetArgs(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		r    *Repository[product]
+		want string
+	}{
+		{"default limit", NewRepo[product](&Client{}).Search(NewNumericQuery("price").Gt(1)),
+			`[FT.AGGREGATE idx:product @price:(1 +inf] LOAD 1 @color APPLY split(@color, ",") AS __facet GROUPBY 1 @__facet REDUCE COUNT 0 AS count SORTBY 4 @count DESC @__facet ASC MAX 10]`},
+		{"top 3", NewRepo[product](&Client{}).Search().FacetLimit(3),
+			`[FT.AGGREGATE idx:product * LOAD 1 @color APPLY split(@color, ",") AS __facet GROUPBY 1 @__facet REDUCE COUNT 0 AS count SORTBY 4 @count DESC @__facet ASC MAX 3]`},
+		{"all values", NewRepo[product](&Client{}).Search().FacetLimit(0),
+			`[FT.AGGREGATE idx:product * LOAD 1 @color APPLY split(@color, ",") AS __facet GROUPBY 1 @__facet REDUCE COUNT 0 AS count SORTBY 4 @count DESC @__facet ASC MAX 10000]`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := fmt.Sprint(tc.r.facetArgs("color")); got != tc.want {
+				t.Errorf("facetArgs() = %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRepository_FacetsValidation(t *testing.T) {
+	t.Parallel()
+	for _, r := range []*Repository[product]{
+		NewRepo[product](&Client{}).Search().Facets("price"),
+		NewRepo[product](&Client{}).Search().Facets("missing"),
+		NewRepo[product](&Client{}).Search().Facets("color").InKeys("1"),
+	} {
+		if _, err := r.ExecFacets(context.Background()); err == nil {
+			t.Errorf("ExecFacets(): expected error")
+		}
+	}
+}
+
+func TestRepository_rangeFacetArgs(t *testing.T) {
+	t.Parallel()
+	r := NewRepo[product](&Client{}).Search(NewTagQB("color").Any("red"))
+	if got, want := fmt.Sprint(r.histogramArgs("price", 12.5)),
+		`[FT.AGGREGATE idx:product @color:{red} LOAD 1 @price FILTER exists(@price) APPLY floor(@price/12.5) AS __facet GROUPBY 1 @__facet REDUCE COUNT 0 AS count SORTBY 2 @__facet ASC MAX 1000]`; got != want {
+		t.Errorf("histogramArgs() = %s\nwant %s", got, want)
+	}
+	iv := interval{lo: bound{50, false}, hi: bound{100, true}}
+	if got, want := fmt.Sprint(r.rangeArgs("price", iv)), `[FT.SEARCH idx:product @color:{red} @price:[50 100) LIMIT 0 0]`; got != want {
+		t.Errorf("rangeArgs() = %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(NewRepo[product](&Client{}).Search().rangeArgs("price", iv)), `[FT.SEARCH idx:product @price:[50 100) LIMIT 0 0]`; got != want {
+		t.Errorf("rangeArgs() without query = %s, want %s", got, want)
+	}
+}
+
+func TestNiceWidth(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		lo, hi, want float64
+	}{
+		{0, 100, 10},
+		{19.9, 120, 20},
+		{0, 3, 0.5},
+		{1000, 1700, 100},
+		{5, 5, 1},
+		{0, 0.07, 0.01},
+	}
+	for _, tc := range tests {
+		if got := niceWidth(tc.lo, tc.hi, 10); math.Abs(got-tc.want) > 1e-12 {
+			t.Errorf("niceWidth(%v, %v) = %v, want %v", tc.lo, tc.hi, got, tc.want)
+		}
+	}
+}
+
+func TestRepository_RangeFacetsValidation(t *testing.T) {
+	t.Parallel()
+	for _, r := range []*Repository[product]{
+		NewRepo[product](&Client{}).Search().Histogram("color", 10),
+		NewRepo[product](&Client{}).Search().Histogram("missing", 10),
+		NewRepo[product](&Client{}).Search().Histogram("price", math.Inf(1)),
+		NewRepo[product](&Client{}).Search().RangeFacets(NewNumericQuery("price")),
+		NewRepo[product](&Client{}).Search().RangeFacets(NewNumericQuery("name").Gt(1)),
+	} {
+		if _, err := r.ExecFacets(context.Background()); err == nil {
+			t.Errorf("ExecFacets(): expected error")
+		}
+	}
+}
+
+func TestBucket_JSON(t *testing.T) {
+	t.Parallel()
+	buckets := []Bucket{{Lo: math.Inf(-1), Hi: 50, Count: 2}, {Lo: 50, Hi: 100, Count: 1}, {Lo: 100, Hi: math.Inf(1), Count: 3}}
+	b, err := json.Marshal(buckets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"Lo":null,"Hi":50,"Count":2},{"Lo":50,"Hi":100,"Count":1},{"Lo":100,"Hi":null,"Count":3}]`; string(b) != want {
+		t.Errorf("Marshal = %s, want %s", b, want)
+	}
+	var got []Bucket
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(buckets) {
+		t.Errorf("Unmarshal = %v, want %v", got, buckets)
+	}
+}
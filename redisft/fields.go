@@ -0,0 +1,74 @@
+package redisft
+
+// Typed field handles, usually generated by cmd/redisft-gen from the
+// `redis` struct tags. Each handle only offers the builders valid for its
+// index type, and the Sortable variants add SortAsc / SortDesc:
+//
+//	repo.Search(ProductFields.Price.Between(1, 10), ProductFields.Color.Any("red")).
+//		OrderBy(ProductFields.Price.SortAsc())
+
+// Sort is a sort order for Repository.OrderBy.
+type Sort struct {
+	Field string
+	Asc   bool
+}
+
+// OrderBy is SortBy with a Sort, as returned by a sortable field handle.
+func (r *Repository[T]) OrderBy(s Sort) *Repository[T] { return r.SortBy(s.Field, s.Asc) }
+
+// TextField is a TEXT field.
+type TextField struct{ Name string }
+
+func (f TextField) Query() *QB                          { return NewTextQuery(f.Name) }
+func (f TextField) Term(term string) *QB                { return f.Query().Term(term) }
+func (f TextField) Prefix(p string) *QB                 { return f.Query().Prefix(p) }
+func (f TextField) Exact(phrase string) *QB             { return f.Query().Exact(phrase) }
+func (f TextField) Fuzzy(term string, distance int) *QB { return f.Query().Fuzzy(term, distance) }
+func (f TextField) Any(terms ...string) *QB             { return f.Query().Any(terms...) }
+func (f TextField) All(terms ...string) *QB             { return f.Query().All(terms...) }
+
+// NumericField is a NUMERIC field.
+type NumericField struct{ Name string }
+
+func (f NumericField) Query() *NumericQuery                 { return NewNumericQuery(f.Name) }
+func (f NumericField) Eq(v float64) *NumericQuery           { return f.Query().Between(v, v) }
+func (f NumericField) Gt(v float64) *NumericQuery           { return f.Query().Gt(v) }
+func (f NumericField) Ge(v float64) *NumericQuery           { return f.Query().Ge(v) }
+func (f NumericField) Lt(v float64) *NumericQuery           { return f.Query().Lt(v) }
+func (f NumericField) Le(v float64) *NumericQuery           { return f.Query().Le(v) }
+func (f NumericField) Between(lo, hi float64) *NumericQuery { return f.Query().Between(lo, hi) }
+
+// TagField is a TAG field.
+type TagField struct{ Name string }
+
+func (f TagField) Query() *TagQB               { return NewTagQB(f.Name) }
+func (f TagField) Any(tags ...string) *TagQB   { return f.Query().Any(tags...) }
+func (f TagField) All(tags ...string) *TagQB   { return f.Query().All(tags...) }
+func (f TagField) NotIn(tags ...string) *TagQB { return f.Query().NotIn(tags...) }
+
+// GeoField is a GEO field. GEO fields cannot be sortable.
+type GeoField struct{ Name string }
+
+func (f GeoField) Query() *GeoQuery { return NewGeoQuery(f.Name) }
+
+// Near starts a radius query around lon, lat; finish it with Km, M, Mi or
+// Ft.
+func (f GeoField) Near(lon, lat float64) *GeoQuery { return f.Query().Center(lon, lat) }
+
+// SortableTextField is a TEXT SORTABLE field.
+type SortableTextField struct{ TextField }
+
+func (f SortableTextField) SortAsc() Sort  { return Sort{f.Name, true} }
+func (f SortableTextField) SortDesc() Sort { return Sort{f.Name, false} }
+
+// SortableNumericField is a NUMERIC SORTABLE field.
+type SortableNumericField struct{ NumericField }
+
+func (f SortableNumericField) SortAsc() Sort  { return Sort{f.Name, true} }
+func (f SortableNumericField) SortDesc() Sort { return Sort{f.Name, false} }
+
+// SortableTagField is a TAG SORTABLE field.
+type SortableTagField struct{ TagField }
+
+func (f SortableTagField) SortAsc() Sort  { return Sort{f.Name, true} }
+func (f SortableTagField) SortDesc() Sort { return Sort{f.Name, false} }
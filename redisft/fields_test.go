@@ -0,0 +1,32 @@
+package redisft
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFieldHandles(t *testing.T) {
+	t.Parallel()
+	price := SortableNumericField{NumericField{Name: "price"}}
+	tests := []struct {
+		b    Builder
+		want string
+	}{
+		{price.Between(1, 10), "@price:[1 10]"},
+		{price.Eq(5), "@price:[5 5]"},
+		{price.Gt(3), "@price:(3 +inf]"},
+		{TagField{Name: "color"}.Any("red", "blue"), "@color:{red|blue}"},
+		{TextField{Name: "name"}.Prefix("war"), "@name:(war*)"},
+		{GeoField{Name: "location"}.Near(29, 41).Km(5), "@location:[29.000000 41.000000 5.0000 km]"},
+	}
+	for _, tt := range tests {
+		if got := tt.b.Build(); got != tt.want {
+			t.Errorf("Build = %q, want %q", got, tt.want)
+		}
+	}
+
+	r := NewRepo[product](NewClientFromPool(nil)).OrderBy(price.SortDesc())
+	if got := fmt.Sprint(r.args()); got != "[idx:product * SORTBY price DESC]" {
+		t.Errorf("args = %s", got)
+	}
+}
@@ -0,0 +1,438 @@
+package redisft
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FilterOptions restricts ParseFilter.
+type FilterOptions struct {
+	// Fields lists the fields that may be filtered on. Default: every
+	// indexed field.
+	Fields []string
+	// Sortable lists the fields that may be sorted by. Default: every
+	// SORTABLE field.
+	Sortable []string
+	// DefaultLimit is the limit when none is given. Default 10.
+	DefaultLimit int
+	// MaxLimit is the largest limit accepted. Default 100.
+	MaxLimit int
+	// Ignore lists parameters ParseFilter skips, e.g. ones the caller
+	// handles itself.
+	Ignore []string
+}
+
+// Filter is a search parsed by ParseFilter. Apply it with
+// Repository.Filter.
+type Filter struct {
+	Builders []Builder
+	Sort     *Sort
+	Offset   int
+	Limit    int
+}
+
+// Filter starts a new search from f: its builders, sort and limit.
+func (r *Repository[T]) Filter(f *Filter) *Repository[T] {
+	r.Search(f.Builders...)
+	if f.Sort != nil {
+		r.OrderBy(*f.Sort)
+	}
+	return r.Limit(f.Offset, f.Limit)
+}
+
+// FieldError reports one invalid query parameter.
+type FieldError struct {
+	Param string
+	Value string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%s: %v", e.Param, e.Err)
+	}
+	return fmt.Sprintf("%s=%s: %v", e.Param, e.Value, e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// FieldErrors is every FieldError found by ParseFilter, ordered by
+// parameter.
+type FieldErrors []*FieldError
+
+func (e FieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return "redisft: invalid filter: " + strings.Join(msgs, "; ")
+}
+
+// Reserved ParseFilter parameters.
+const (
+	filterSort   = "sort"
+	filterLimit  = "limit"
+	filterOffset = "offset"
+	filterNear   = "near"
+)
+
+// ParseFilter reads a search from URL query parameters, such as
+//
+//	?price=gte:10&price=lt:50&color=in:red,blue&near=29,41,10km&sort=-price&limit=20
+//
+// Every parameter names a field and holds a condition, optionally led by an
+// operator; without one the type's default applies:
+//
+//	TEXT     match:w1 w2 (default)  any:w1 w2  prefix:p  exact:phrase  fuzzy:w
+//	TAG      in:a,b (default)  all:a,b  nin:a,b
+//	NUMERIC  eq:v (default)  gt:v  gte:v  lt:v  lte:v  between:lo,hi  lo:hi
+//	GEO      near:lon,lat,radius (default; units m, km, mi, ft, default km)
+//
+// In lo:hi either end may be empty. A repeated parameter adds conditions on
+// the same field that must all hold; near and the GEO field's own parameter
+// cannot be combined. The reserved parameters are:
+//
+//	sort=field, sort=-field, sort=field:asc|desc
+//	limit=n, offset=n
+//	near=lon,lat,radius   on the only filterable GEO field
+//
+// Fields are checked against the schema and FilterOptions. All problems are
+// reported together as FieldErrors.
+func (r *Repository[T]) ParseFilter(params url.Values, opts FilterOptions) (*Filter, error) {
+	if opts.MaxLimit == 0 {
+		opts.MaxLimit = 100
+	}
+	if opts.DefaultLimit == 0 {
+		opts.DefaultLimit = min(10, opts.MaxLimit)
+	}
+	allowed := map[string]bool{}
+	for _, f := range opts.Fields {
+		allowed[strings.ToLower(f)] = true
+	}
+	sortable := map[string]bool{}
+	for _, f := range opts.Sortable {
+		sortable[strings.ToLower(f)] = true
+	}
+	ignore := map[string]bool{}
+	for _, p := range opts.Ignore {
+		ignore[p] = true
+	}
+	filterable := func(fs fieldSpec) bool { return len(allowed) == 0 || allowed[fs.name] }
+
+	names := make([]string, 0, len(params))
+	for k := range params {
+		if !ignore[k] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	f := &Filter{Limit: opts.DefaultLimit}
+	byField := map[string]string{} // field -> parameter filtering it
+	var errs FieldErrors
+	fail := func(param, value string, err error) {
+		errs = append(errs, &FieldError{Param: param, Value: value, Err: err})
+	}
+	for _, name := range names {
+		values := params[name]
+		fs, isField := r.schema.field(name)
+		switch {
+		case isField:
+		case name == filterSort:
+			s, err := parseSort(values[len(values)-1], func(field string) error {
+				fs, ok := r.schema.field(field)
+				switch {
+				case !ok:
+					return fmt.Errorf("unknown field %q", field)
+				case len(sortable) > 0 && !sortable[fs.name], !fs.sortable:
+					return fmt.Errorf("cannot sort by %q", field)
+				}
+				return nil
+			})
+			if err != nil {
+				fail(name, values[len(values)-1], err)
+			}
+			f.Sort = s
+			continue
+		case name == filterLimit, name == filterOffset:
+			n, err := strconv.Atoi(values[len(values)-1])
+			switch {
+			case err != nil || n < 0:
+				fail(name, values[len(values)-1], errors.New("not a non-negative integer"))
+			case name == filterOffset:
+				f.Offset = n
+			case n < 1 || n > opts.MaxLimit:
+				fail(name, values[len(values)-1], fmt.Errorf("must be between 1 and %d", opts.MaxLimit))
+			default:
+				f.Limit = n
+			}
+			continue
+		case name == filterNear:
+			var geo []fieldSpec
+			for _, s := range r.schema {
+				if s.typ == FieldGeo && filterable(s) {
+					geo = append(geo, s)
+				}
+			}
+			if len(geo) != 1 {
+				fail(name, "", fmt.Errorf("needs exactly one filterable GEO field, have %d", len(geo)))
+				continue
+			}
+			fs = geo[0]
+			values = prefixAll("near:", values)
+		default:
+			fail(name, "", errors.New("unknown field"))
+			continue
+		}
+		if !filterable(fs) {
+			fail(name, "", errors.New("field is not filterable"))
+			continue
+		}
+		if prev, dup := byField[fs.name]; dup {
+			fail(name, "", fmt.Errorf("filters the same field as %s", prev))
+			continue
+		}
+		byField[fs.name] = name
+		b, err := parseConditions(fs, values)
+		if err != nil {
+			fail(name, err.value, err.err)
+			continue
+		}
+		f.Builders = append(f.Builders, b)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return f, nil
+}
+
+func prefixAll(p string, values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = p + v
+	}
+	return out
+}
+
+// parseSort reads field, -field or field:asc|desc.
+func parseSort(s string, check func(field string) error) (*Sort, error) {
+	field, dir, _ := strings.Cut(s, ":")
+	asc := true
+	if strings.HasPrefix(field, "-") && dir == "" {
+		field, asc = field[1:], false
+	}
+	switch strings.ToLower(dir) {
+	case "", "asc":
+	case "desc":
+		asc = false
+	default:
+		return nil, fmt.Errorf("direction must be asc or desc, got %q", dir)
+	}
+	if err := check(field); err != nil {
+		return nil, err
+	}
+	return &Sort{Field: strings.ToLower(field), Asc: asc}, nil
+}
+
+// conditionError is a bad condition and the value holding it.
+type conditionError struct {
+	value string
+	err   error
+}
+
+// operators are the explicit operators valid for each field type.
+var operators = map[FieldType]map[string]bool{
+	FieldText:    {"match": true, "any": true, "prefix": true, "exact": true, "fuzzy": true},
+	FieldTag:     {"in": true, "all": true, "nin": true},
+	FieldNumeric: {"eq": true, "gt": true, "gte": true, "lt": true, "lte": true, "between": true},
+	FieldGeo:     {"near": true},
+}
+
+// splitOperator separates a leading operator from v. A prefix that is not
+// an operator of typ belongs to the value, as in the range 10:50.
+func splitOperator(typ FieldType, v string) (string, string) {
+	if op, arg, ok := strings.Cut(v, ":"); ok && operators[typ][strings.ToLower(op)] {
+		return strings.ToLower(op), arg
+	}
+	return "", v
+}
+
+// parseConditions builds one builder holding every condition on a field.
+func parseConditions(fs fieldSpec, values []string) (Builder, *conditionError) {
+	switch fs.typ {
+	case FieldText:
+		q := NewTextQuery(fs.name)
+		for _, v := range values {
+			op, arg := splitOperator(fs.typ, v)
+			terms := strings.Fields(arg)
+			if len(terms) == 0 {
+				return nil, &conditionError{v, errors.New("empty condition")}
+			}
+			switch op {
+			case "", "match":
+				q.All(terms...)
+			case "any":
+				q.Group(func(q *QB) { q.Any(terms...) })
+			case "prefix":
+				q.Prefix(arg)
+			case "exact":
+				q.Exact(arg)
+			case "fuzzy":
+				q.Fuzzy(arg, 1)
+			}
+		}
+		return q, nil
+	case FieldTag:
+		q := NewTagQB(fs.name)
+		for _, v := range values {
+			op, arg := splitOperator(fs.typ, v)
+			var tags []string
+			for _, t := range strings.Split(arg, ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					tags = append(tags, t)
+				}
+			}
+			if len(tags) == 0 {
+				return nil, &conditionError{v, errors.New("no tags")}
+			}
+			switch op {
+			case "", "in":
+				q.And().Any(tags...)
+			case "all":
+				for _, t := range tags {
+					q.And().Any(t)
+				}
+			case "nin":
+				q.And().NotIn(tags...)
+			}
+		}
+		return q, nil
+	case FieldNumeric:
+		iv := numRange{lo: math.Inf(-1), hi: math.Inf(1), loIncl: true, hiIncl: true}
+		for _, v := range values {
+			if err := iv.apply(splitOperator(fs.typ, v)); err != nil {
+				return nil, &conditionError{v, err}
+			}
+		}
+		if iv.lo > iv.hi || (iv.lo == iv.hi && !(iv.loIncl && iv.hiIncl)) {
+			return nil, &conditionError{strings.Join(values, "&"), errors.New("conditions exclude every value")}
+		}
+		return NewNumericQuery(fs.name).Range(iv.lo, iv.hi, iv.loIncl, iv.hiIncl), nil
+	case FieldGeo:
+		if len(values) > 1 {
+			return nil, &conditionError{strings.Join(values, "&"), errors.New("only one radius allowed")}
+		}
+		_, arg := splitOperator(fs.typ, values[0])
+		g, err := parseRadius(fs.name, arg)
+		if err != nil {
+			return nil, &conditionError{values[0], err}
+		}
+		return g, nil
+	}
+	return nil, &conditionError{"", fmt.Errorf("cannot filter on %s fields", fs.typ)}
+}
+
+// numRange is the intersection of the numeric conditions on a field.
+type numRange struct {
+	lo, hi         float64
+	loIncl, hiIncl bool
+}
+
+func (r *numRange) above(v float64, incl bool) {
+	if v > r.lo || (v == r.lo && !incl) {
+		r.lo, r.loIncl = v, incl
+	}
+}
+
+func (r *numRange) below(v float64, incl bool) {
+	if v < r.hi || (v == r.hi && !incl) {
+		r.hi, r.hiIncl = v, incl
+	}
+}
+
+func (r *numRange) apply(op, arg string) error {
+	num := func(s string) (float64, error) {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || math.IsNaN(v) {
+			return 0, fmt.Errorf("%q is not a number", s)
+		}
+		return v, nil
+	}
+	bounds := func(lo, hi string) error {
+		if lo != "" {
+			v, err := num(lo)
+			if err != nil {
+				return err
+			}
+			r.above(v, true)
+		}
+		if hi != "" {
+			v, err := num(hi)
+			if err != nil {
+				return err
+			}
+			r.below(v, true)
+		}
+		return nil
+	}
+	switch op {
+	case "between":
+		lo, hi, ok := strings.Cut(arg, ",")
+		if !ok || lo == "" || hi == "" {
+			return errors.New("want between:lo,hi")
+		}
+		return bounds(lo, hi)
+	case "":
+		if lo, hi, ok := strings.Cut(arg, ":"); ok {
+			return bounds(lo, hi)
+		}
+	}
+	v, err := num(arg)
+	if err != nil {
+		return err
+	}
+	switch op {
+	case "", "eq":
+		r.above(v, true)
+		r.below(v, true)
+	case "gt":
+		r.above(v, false)
+	case "gte":
+		r.above(v, true)
+	case "lt":
+		r.below(v, false)
+	case "lte":
+		r.below(v, true)
+	}
+	return nil
+}
+
+// parseRadius reads lon,lat,radius[unit]; the unit defaults to km.
+func parseRadius(field, s string) (*GeoQuery, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return nil, errors.New("want lon,lat,radius")
+	}
+	lon, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lat, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil || lon < -180 || lon > 180 || lat < -90 || lat > 90 {
+		return nil, errors.New("invalid coordinates")
+	}
+	rs, unit := strings.TrimSpace(parts[2]), Kilometers
+	for _, u := range []GeoUnit{Kilometers, Miles, Feet, Meters} {
+		if strings.HasSuffix(rs, string(u)) {
+			rs, unit = strings.TrimSuffix(rs, string(u)), u
+			break
+		}
+	}
+	rad, err := strconv.ParseFloat(rs, 64)
+	if err != nil || rad <= 0 {
+		return nil, fmt.Errorf("invalid radius %q", parts[2])
+	}
+	return NewGeoQuery(field).Center(lon, lat).Radius(rad, unit), nil
+}
@@ -0,0 +1,105 @@
+package redisft
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type place struct {
+	Name     string  `redis:"text"`
+	Price    float64 `redis:"numeric sortable"`
+	Color    string  `redis:"tag"`
+	Location string  `redis:"geo"`
+}
+
+func TestParseFilter(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "[idx:place * LIMIT 0 10]"},
+		{"name=redis action", "[idx:place @name:(redis action) LIMIT 0 10]"},
+		{"name=any:redis go&name=prefix:act", "[idx:place @name:((redis|go) act*) LIMIT 0 10]"},
+		{"name=exact:in action", `[idx:place @name:("in action") LIMIT 0 10]`},
+		{"color=red,blue", "[idx:place @color:{red|blue} LIMIT 0 10]"},
+		{"color=all:red,blue&color=nin:green", "[idx:place @color:{red} @color:{blue} -@color:{green} LIMIT 0 10]"},
+		{"color=a:b", `[idx:place @color:{a:b} LIMIT 0 10]`},
+		{"price=10", "[idx:place @price:[10 10] LIMIT 0 10]"},
+		{"price=10:50", "[idx:place @price:[10 50] LIMIT 0 10]"},
+		{"price=:50", "[idx:place @price:[-inf 50] LIMIT 0 10]"},
+		{"price=gte:10&price=lt:50&price=gt:5", "[idx:place @price:[10 50) LIMIT 0 10]"},
+		{"price=between:1,2", "[idx:place @price:[1 2] LIMIT 0 10]"},
+		{"near=29,41,10km", "[idx:place @location:[29.000000 41.000000 10.0000 km] LIMIT 0 10]"},
+		{"location=near:29,41,500m", "[idx:place @location:[29.000000 41.000000 500.0000 m] LIMIT 0 10]"},
+		{"sort=-price&limit=5&offset=20", "[idx:place * SORTBY price DESC LIMIT 20 5]"},
+		{"sort=price:asc", "[idx:place * SORTBY price ASC LIMIT 0 10]"},
+		{"page=3", "[idx:place * LIMIT 0 10]"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			t.Parallel()
+			params, err := url.ParseQuery(tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := NewRepo[place](&Client{})
+			f, err := r.ParseFilter(params, FilterOptions{Ignore: []string{"page"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(r.Filter(f).args()); got != tc.want {
+				t.Errorf("args() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseFilter_Errors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		query string
+		opts  FilterOptions
+		want  []string
+	}{
+		{"colour=red", FilterOptions{}, []string{"colour: unknown field"}},
+		{"color=red", FilterOptions{Fields: []string{"price"}}, []string{"color: field is not filterable"}},
+		{"near=1,2,3", FilterOptions{Fields: []string{"price"}}, []string{"near: needs exactly one filterable GEO field, have 0"}},
+		{"price=cheap&color=,", FilterOptions{}, []string{`color=,: no tags`, `price=cheap: "cheap" is not a number`}},
+		{"price=gt:5&price=lt:3", FilterOptions{}, []string{"price=gt:5&lt:3: conditions exclude every value"}},
+		{"price=between:5", FilterOptions{}, []string{"price=between:5: want between:lo,hi"}},
+		{"price=NaN&price=eq:nan", FilterOptions{}, []string{`price=NaN: "NaN" is not a number`}},
+		{"price=between:1,NaN", FilterOptions{}, []string{`price=between:1,NaN: "NaN" is not a number`}},
+		{"near=29,41,10km&location=29,41,5km", FilterOptions{}, []string{"near: filters the same field as location"}},
+		{"location=1,2", FilterOptions{}, []string{"location=1,2: want lon,lat,radius"}},
+		{"location=200,2,1km", FilterOptions{}, []string{"location=200,2,1km: invalid coordinates"}},
+		{"sort=name", FilterOptions{}, []string{`sort=name: cannot sort by "name"`}},
+		{"sort=price", FilterOptions{Sortable: []string{"id"}}, []string{`sort=price: cannot sort by "price"`}},
+		{"sort=price:up", FilterOptions{}, []string{`sort=price:up: direction must be asc or desc, got "up"`}},
+		{"limit=500&offset=-1", FilterOptions{}, []string{"limit=500: must be between 1 and 100", "offset=-1: not a non-negative integer"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			t.Parallel()
+			params, err := url.ParseQuery(tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = NewRepo[place](&Client{}).ParseFilter(params, tc.opts)
+			var fe FieldErrors
+			if !errors.As(err, &fe) {
+				t.Fatalf("err = %v, want FieldErrors", err)
+			}
+			var got []string
+			for _, e := range fe {
+				got = append(got, e.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("errors = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
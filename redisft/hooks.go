@@ -0,0 +1,211 @@
+package redisft
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Op names the repository operation behind a command.
+type Op string
+
+const (
+	OpCreateIndex      Op = "create_index"
+	OpDropIndex        Op = "drop_index"
+	OpInsert           Op = "insert"
+	OpInsertMany       Op = "insert_many"
+	OpGet              Op = "get"
+	OpUpdate           Op = "update"
+	OpReplace          Op = "replace"
+	OpCompareAndUpdate Op = "compare_and_update"
+	OpDelete           Op = "delete"
+	OpTouch            Op = "touch"
+	OpPersist          Op = "persist"
+	OpTTL              Op = "ttl"
+	OpTx               Op = "tx" // a whole RunTx commit; Index is empty
+	OpSearch           Op = "search"
+	OpExplain          Op = "explain"
+	OpProfile          Op = "profile"
+	OpScan             Op = "scan" // SCAN and HGETALL batches of Export and Rewrite
+	OpRewrite          Op = "rewrite"
+	OpAggregate        Op = "aggregate" // FT.AGGREGATE for facets
+	OpTagVals          Op = "tag_vals"
+)
+
+// CommandInfo describes one command issued by a repository. Args is the
+// full command for single commands and nil for pipelines. Hits is the total
+// reported by searches and -1 for everything else. Duration, Attempts and
+// Err are only set when AfterCommand runs; Duration includes retries.
+type CommandInfo struct {
+	Index    string
+	Op       Op
+	Query    string
+	Args     []any
+	Duration time.Duration
+	Attempts int
+	Hits     int64
+	Err      error
+
+	versioned bool // increments a version field, so never retried
+}
+
+// Hook observes repository commands. BeforeCommand may return a derived
+// context (e.g. carrying a span) that is passed to the command and to
+// AfterCommand. Hooks run in registration order before and in reverse order
+// after a command, and must be safe for concurrent use.
+type Hook interface {
+	BeforeCommand(ctx context.Context, info *CommandInfo) context.Context
+	AfterCommand(ctx context.Context, info *CommandInfo)
+}
+
+// WithHook registers h on the Client.
+func WithHook(h Hook) Option {
+	return func(o *options) error {
+		if h == nil {
+			return errors.New("redisft: WithHook: nil hook")
+		}
+		o.hooks = append(o.hooks, h)
+		return nil
+	}
+}
+
+// AddHook registers h on c. It must be called before c is used.
+func (c *Client) AddHook(h Hook) {
+	if h != nil {
+		c.hooks = append(c.hooks, h)
+	}
+}
+
+// do runs fn as one instrumented command of r's index.
+func (r *Repository[T]) do(ctx context.Context, info *CommandInfo, fn func(ctx context.Context) error) error {
+	info.Index = r.index
+	return r.cli.do(ctx, info, fn)
+}
+
+// do runs fn as one instrumented command, under the client's retry and
+// circuit breaker policy.
+func (c *Client) do(ctx context.Context, info *CommandInfo, fn func(ctx context.Context) error) error {
+	info.Hits = -1
+	hooks := c.hooks
+	if len(hooks) == 0 {
+		return c.run(ctx, info, fn)
+	}
+	for _, h := range hooks {
+		ctx = h.BeforeCommand(ctx, info)
+	}
+	start := time.Now()
+	err := c.run(ctx, info, fn)
+	info.Duration, info.Err = time.Since(start), err
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i].AfterCommand(ctx, info)
+	}
+	return err
+}
+
+type slowQueryHook struct {
+	logger    *slog.Logger
+	threshold time.Duration
+}
+
+// SlowQueryHook logs commands that take at least threshold at Warn level and
+// failed commands at Error level.
+func SlowQueryHook(logger *slog.Logger, threshold time.Duration) Hook {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return slowQueryHook{logger: logger, threshold: threshold}
+}
+
+func (h slowQueryHook) BeforeCommand(ctx context.Context, _ *CommandInfo) context.Context {
+	return ctx
+}
+
+func (h slowQueryHook) AfterCommand(ctx context.Context, info *CommandInfo) {
+	attrs := []any{
+		slog.String("index", info.Index),
+		slog.String("op", string(info.Op)),
+		slog.Duration("duration", info.Duration),
+	}
+	if info.Query != "" {
+		attrs = append(attrs, slog.String("query", info.Query))
+	}
+	if info.Hits >= 0 {
+		attrs = append(attrs, slog.Int64("hits", info.Hits))
+	}
+	switch {
+	case info.Err != nil:
+		h.logger.ErrorContext(ctx, "redisft: command failed", append(attrs, slog.Any("error", info.Err))...)
+	case info.Duration >= h.threshold:
+		h.logger.WarnContext(ctx, "redisft: slow command", attrs...)
+	}
+}
+
+// Span is the subset of a tracing span used by TracingHook; an OpenTelemetry
+// trace.Span is adapted to it in a few lines.
+type Span interface {
+	SetAttribute(key string, value any)
+	RecordError(err error)
+	End()
+}
+
+// StartSpanFunc starts a span named name as a child of ctx.
+type StartSpanFunc func(ctx context.Context, name string) (context.Context, Span)
+
+type spanKey struct{}
+
+type tracingHook struct{ start StartSpanFunc }
+
+// TracingHook wraps every command in a span named "redisft.<op>", using the
+// OpenTelemetry database semantic attribute names.
+func TracingHook(start StartSpanFunc) Hook { return tracingHook{start: start} }
+
+func (h tracingHook) BeforeCommand(ctx context.Context, info *CommandInfo) context.Context {
+	ctx, span := h.start(ctx, "redisft."+string(info.Op))
+	span.SetAttribute("db.system", "redis")
+	span.SetAttribute("db.operation", string(info.Op))
+	span.SetAttribute("redisft.index", info.Index)
+	if info.Query != "" {
+		span.SetAttribute("db.statement", info.Query)
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func (h tracingHook) AfterCommand(ctx context.Context, info *CommandInfo) {
+	span, ok := ctx.Value(spanKey{}).(Span)
+	if !ok {
+		return
+	}
+	if info.Hits >= 0 {
+		span.SetAttribute("redisft.hits", info.Hits)
+	}
+	if info.Err != nil {
+		span.RecordError(info.Err)
+	}
+	span.End()
+}
+
+// ObserveFunc receives one latency sample in seconds; status is "ok" or
+// "error". It maps directly onto a Prometheus HistogramVec:
+//
+//	func(index string, op redisft.Op, status string, s float64) {
+//		hist.WithLabelValues(index, string(op), status).Observe(s)
+//	}
+type ObserveFunc func(index string, op Op, status string, seconds float64)
+
+type metricsHook struct{ observe ObserveFunc }
+
+// MetricsHook reports the latency of every command to observe.
+func MetricsHook(observe ObserveFunc) Hook { return metricsHook{observe: observe} }
+
+func (h metricsHook) BeforeCommand(ctx context.Context, _ *CommandInfo) context.Context {
+	return ctx
+}
+
+func (h metricsHook) AfterCommand(_ context.Context, info *CommandInfo) {
+	status := "ok"
+	if info.Err != nil {
+		status = "error"
+	}
+	h.observe(info.Index, info.Op, status, info.Duration.Seconds())
+}
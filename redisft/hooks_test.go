@@ -0,0 +1,77 @@
+package redisft
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowQueryHook(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	h := SlowQueryHook(slog.New(slog.NewTextHandler(&buf, nil)), 100*time.Millisecond)
+	ctx := context.Background()
+
+	h.AfterCommand(ctx, &CommandInfo{Index: "idx:p", Op: OpSearch, Query: "*", Duration: time.Millisecond, Hits: 3})
+	if buf.Len() != 0 {
+		t.Fatalf("fast command logged: %s", buf.String())
+	}
+	h.AfterCommand(ctx, &CommandInfo{Index: "idx:p", Op: OpSearch, Query: "@name:go", Duration: time.Second, Hits: 3})
+	if out := buf.String(); !strings.Contains(out, "level=WARN") || !strings.Contains(out, `query=@name:go`) || !strings.Contains(out, "hits=3") {
+		t.Errorf("slow log = %s", out)
+	}
+	buf.Reset()
+	h.AfterCommand(ctx, &CommandInfo{Index: "idx:p", Op: OpInsert, Hits: -1, Err: errors.New("boom")})
+	if out := buf.String(); !strings.Contains(out, "level=ERROR") || !strings.Contains(out, "error=boom") || strings.Contains(out, "hits=") {
+		t.Errorf("error log = %s", out)
+	}
+}
+
+type testSpan struct {
+	attrs map[string]any
+	err   error
+	ended bool
+}
+
+func (s *testSpan) SetAttribute(k string, v any) { s.attrs[k] = v }
+func (s *testSpan) RecordError(err error)        { s.err = err }
+func (s *testSpan) End()                         { s.ended = true }
+
+func TestTracingHook(t *testing.T) {
+	t.Parallel()
+	var name string
+	span := &testSpan{attrs: map[string]any{}}
+	h := TracingHook(func(ctx context.Context, n string) (context.Context, Span) {
+		name = n
+		return ctx, span
+	})
+	info := &CommandInfo{Index: "idx:p", Op: OpSearch, Query: "*", Hits: -1}
+	ctx := h.BeforeCommand(context.Background(), info)
+	info.Hits, info.Err = 7, errors.New("boom")
+	h.AfterCommand(ctx, info)
+
+	if name != "redisft.search" || !span.ended || span.err == nil {
+		t.Errorf("name=%q ended=%v err=%v", name, span.ended, span.err)
+	}
+	if span.attrs["db.statement"] != "*" || span.attrs["redisft.index"] != "idx:p" || span.attrs["redisft.hits"] != int64(7) {
+		t.Errorf("attrs = %v", span.attrs)
+	}
+}
+
+func TestMetricsHook(t *testing.T) {
+	t.Parallel()
+	var got []string
+	h := MetricsHook(func(index string, op Op, status string, s float64) {
+		got = append(got, index+"/"+string(op)+"/"+status)
+	})
+	ctx := context.Background()
+	h.AfterCommand(ctx, &CommandInfo{Index: "idx:p", Op: OpGet})
+	h.AfterCommand(ctx, &CommandInfo{Index: "idx:p", Op: OpDelete, Err: errors.New("x")})
+	if strings.Join(got, ",") != "idx:p/get/ok,idx:p/delete/error" {
+		t.Errorf("observed %v", got)
+	}
+}
@@ -0,0 +1,217 @@
+package redisft
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Option configures a Client created by NewClientWithOptions.
+type Option func(*options) error
+
+type options struct {
+	username, password string
+	tlsConfig          *tls.Config
+	db                 int
+	poolSize           int
+	minIdleConns       int
+	dialTimeout        time.Duration
+	readTimeout        time.Duration
+	writeTimeout       time.Duration
+	idleTimeout        time.Duration
+
+	sentinelUser, sentinelPass string
+	sentinelSet, sentinelOK    bool
+
+	hashTags bool
+	hooks    []Hook
+	retry    *RetryPolicy
+	breaker  *BreakerPolicy
+
+	// connSet records that a connection-level option was given, which is
+	// meaningless together with WithRedisClient.
+	connSet bool
+	client  *redis.Client
+}
+
+func defaultOptions() *options {
+	return &options{idleTimeout: 5 * time.Minute}
+}
+
+func (o *options) apply(opts []Option) error {
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if err := opt(o); err != nil {
+			return err
+		}
+	}
+	if o.client != nil && o.connSet {
+		return errors.New("redisft: WithRedisClient cannot be combined with connection options")
+	}
+	if o.sentinelSet && !o.sentinelOK {
+		return errors.New("redisft: WithSentinelAuth is only valid with NewFailoverClient")
+	}
+	if o.minIdleConns > 0 && o.poolSize > 0 && o.minIdleConns > o.poolSize {
+		return fmt.Errorf("redisft: min idle conns (%d) exceeds pool size (%d)", o.minIdleConns, o.poolSize)
+	}
+	return nil
+}
+
+func (o *options) redisOptions(addr string) *redis.Options {
+	return &redis.Options{
+		Addr:         addr,
+		Username:     o.username,
+		Password:     o.password,
+		DB:           o.db,
+		TLSConfig:    o.tlsConfig,
+		PoolSize:     o.poolSize,
+		MinIdleConns: o.minIdleConns,
+		DialTimeout:  o.dialTimeout,
+		ReadTimeout:  o.readTimeout,
+		WriteTimeout: o.writeTimeout,
+		IdleTimeout:  o.idleTimeout,
+		OnConnect: func(ctx context.Context, cn *redis.Conn) error {
+			return cn.Ping(ctx).Err()
+		},
+	}
+}
+
+func (o *options) newClient(pool ConnPool) *Client {
+	return &Client{
+		pool:          pool,
+		hashTags:      o.hashTags,
+		hooks:         o.hooks,
+		retry:         o.retry,
+		breakerPolicy: o.breaker,
+	}
+}
+
+func connOption(fn func(*options) error) Option {
+	return func(o *options) error {
+		o.connSet = true
+		return fn(o)
+	}
+}
+
+// WithAuth sets the ACL username (may be empty) and password.
+func WithAuth(username, password string) Option {
+	return connOption(func(o *options) error {
+		if password == "" && username != "" {
+			return errors.New("redisft: WithAuth: username given without password")
+		}
+		o.username, o.password = username, password
+		return nil
+	})
+}
+
+// WithTLS enables TLS with the given configuration.
+func WithTLS(cfg *tls.Config) Option {
+	return connOption(func(o *options) error {
+		if cfg == nil {
+			return errors.New("redisft: WithTLS: nil config")
+		}
+		o.tlsConfig = cfg
+		return nil
+	})
+}
+
+// WithDB selects the logical database. RediSearch only indexes DB 0 on most
+// deployments, so anything else is mainly useful for plain hash storage.
+func WithDB(db int) Option {
+	return connOption(func(o *options) error {
+		if db < 0 {
+			return fmt.Errorf("redisft: WithDB: negative database %d", db)
+		}
+		o.db = db
+		return nil
+	})
+}
+
+// WithPoolSize sets the maximum number of pooled connections.
+func WithPoolSize(n int) Option {
+	return connOption(func(o *options) error {
+		if n <= 0 {
+			return fmt.Errorf("redisft: WithPoolSize: must be positive, got %d", n)
+		}
+		o.poolSize = n
+		return nil
+	})
+}
+
+// WithMinIdleConns keeps at least n idle connections open.
+func WithMinIdleConns(n int) Option {
+	return connOption(func(o *options) error {
+		if n < 0 {
+			return fmt.Errorf("redisft: WithMinIdleConns: negative value %d", n)
+		}
+		o.minIdleConns = n
+		return nil
+	})
+}
+
+// WithDialTimeout bounds establishing new connections.
+func WithDialTimeout(d time.Duration) Option {
+	return connOption(func(o *options) error {
+		if d < 0 {
+			return fmt.Errorf("redisft: WithDialTimeout: negative duration %s", d)
+		}
+		o.dialTimeout = d
+		return nil
+	})
+}
+
+// WithReadTimeout bounds socket reads; long FT.AGGREGATE calls may need more.
+func WithReadTimeout(d time.Duration) Option {
+	return connOption(func(o *options) error {
+		if d < 0 {
+			return fmt.Errorf("redisft: WithReadTimeout: negative duration %s", d)
+		}
+		o.readTimeout = d
+		return nil
+	})
+}
+
+// WithWriteTimeout bounds socket writes.
+func WithWriteTimeout(d time.Duration) Option {
+	return connOption(func(o *options) error {
+		if d < 0 {
+			return fmt.Errorf("redisft: WithWriteTimeout: negative duration %s", d)
+		}
+		o.writeTimeout = d
+		return nil
+	})
+}
+
+// WithRedisClient wraps an already configured go-redis client instead of
+// dialing a new one. The Client takes ownership and closes it on Close.
+func WithRedisClient(c *redis.Client) Option {
+	return func(o *options) error {
+		if c == nil {
+			return errors.New("redisft: WithRedisClient: nil client")
+		}
+		o.client = c
+		return nil
+	}
+}
+
+// NewClientWithOptions builds a Client for addr configured by opts. addr is
+// ignored (and may be empty) when WithRedisClient is used.
+func NewClientWithOptions(addr string, opts ...Option) (*Client, error) {
+	o := defaultOptions()
+	if err := o.apply(opts); err != nil {
+		return nil, err
+	}
+	if o.client != nil {
+		return o.newClient(&Pool{Client: o.client}), nil
+	}
+	if addr == "" {
+		return nil, errors.New("redisft: empty address")
+	}
+	return o.newClient(&Pool{Client: redis.NewClient(o.redisOptions(addr))}), nil
+}
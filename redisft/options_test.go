@@ -0,0 +1,77 @@
+package redisft
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestNewClientWithOptions(t *testing.T) {
+	t.Parallel()
+	cli, err := NewClientWithOptions("localhost:6379",
+		WithAuth("app", "secret"),
+		WithTLS(&tls.Config{ServerName: "redis"}),
+		WithDB(2),
+		WithPoolSize(50),
+		WithMinIdleConns(5),
+		WithDialTimeout(time.Second),
+		WithReadTimeout(2*time.Second),
+		WithWriteTimeout(3*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	o := cli.pool.(*Pool).Client.Options()
+	if o.Username != "app" || o.Password != "secret" || o.DB != 2 || o.PoolSize != 50 ||
+		o.MinIdleConns != 5 || o.DialTimeout != time.Second || o.ReadTimeout != 2*time.Second ||
+		o.WriteTimeout != 3*time.Second || o.TLSConfig == nil {
+		t.Errorf("options not applied: %+v", o)
+	}
+}
+
+func TestNewClientWithOptions_Invalid(t *testing.T) {
+	t.Parallel()
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	defer rdb.Close()
+
+	tests := []struct {
+		name string
+		addr string
+		opts []Option
+	}{
+		{"empty addr", "", nil},
+		{"negative db", "h:1", []Option{WithDB(-1)}},
+		{"zero pool", "h:1", []Option{WithPoolSize(0)}},
+		{"idle above pool", "h:1", []Option{WithPoolSize(2), WithMinIdleConns(3)}},
+		{"negative timeout", "h:1", []Option{WithReadTimeout(-time.Second)}},
+		{"nil tls", "h:1", []Option{WithTLS(nil)}},
+		{"user without password", "h:1", []Option{WithAuth("u", "")}},
+		{"nil client", "", []Option{WithRedisClient(nil)}},
+		{"client plus conn option", "", []Option{WithRedisClient(rdb), WithDB(1)}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := NewClientWithOptions(tc.addr, tc.opts...); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestNewClientWithOptions_RedisClient(t *testing.T) {
+	t.Parallel()
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	cli, err := NewClientWithOptions("", WithRedisClient(rdb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	if cli.Get() != RedisClient(rdb) {
+		t.Error("injected client not used")
+	}
+}
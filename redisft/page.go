@@ -0,0 +1,218 @@
+package redisft
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PageResult is one page of hits plus the token for the next page. NextToken
+// is empty on the last page. Total is the hit count RediSearch reported for
+// the request that produced this page. Facets and Ranges are only set on the
+// first page of a query with facets.
+type PageResult[T any] struct {
+	Items     []T
+	NextToken string
+	Total     int64
+	Facets    []Facet
+	Ranges    []RangeFacet
+}
+
+// pageCursor is the signed payload of a page token.
+//
+// For queries sorted by a NUMERIC field the cursor keeps the last sort value
+// (After) and how many hits with exactly that value were already returned
+// (Skip), so deep pages are fetched with a range filter instead of a growing
+// LIMIT offset. All other queries page by Offset.
+type pageCursor struct {
+	FP     uint64   `json:"f"`
+	Offset int      `json:"o,omitempty"`
+	After  *float64 `json:"a,omitempty"`
+	Skip   int      `json:"s,omitempty"`
+}
+
+var (
+	defaultPageKeyOnce sync.Once
+	defaultPageKey     []byte
+)
+
+// PageKey sets the HMAC key used to sign page tokens. Without it a random
+// per-process key is used, so tokens do not survive restarts and are not
+// accepted by other instances.
+func (r *Repository[T]) PageKey(key []byte) *Repository[T] {
+	r.pageKey = append([]byte(nil), key...)
+	return r
+}
+
+func (r *Repository[T]) signingKey() []byte {
+	if len(r.pageKey) > 0 {
+		return r.pageKey
+	}
+	defaultPageKeyOnce.Do(func() {
+		defaultPageKey = make([]byte, 32)
+		_, _ = rand.Read(defaultPageKey)
+	})
+	return defaultPageKey
+}
+
+// Page returns size hits of the current query starting at pageToken ("" for
+// the first page). Any Limit set on the query is ignored.
+func (r *Repository[T]) Page(ctx context.Context, pageToken string, size int) (*PageResult[T], error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("redisft: page size must be positive, got %d", size)
+	}
+	fp := r.fingerprint()
+	cur := pageCursor{FP: fp}
+	if pageToken != "" {
+		c, err := r.decodeToken(pageToken)
+		if err != nil {
+			return nil, err
+		}
+		if c.FP != fp {
+			return nil, ErrInvalidPageToken
+		}
+		cur = c
+	}
+
+	afterMode := r.sSet
+	if afterMode {
+		fs, ok := r.schema.field(r.sField)
+		afterMode = ok && fs.typ == FieldNumeric
+	}
+
+	// Build the request on a snapshot so the repository's query is untouched.
+	q := *r
+	q.limSet = true
+	if afterMode && cur.After != nil {
+		rng := NewNumericQuery(r.sField)
+		if r.sAsc {
+			rng.Ge(*cur.After)
+		} else {
+			rng.Le(*cur.After)
+		}
+		q.qParts = append(append([]string(nil), r.qParts...), rng.Build())
+		q.off, q.lim = cur.Skip, size
+	} else {
+		q.off, q.lim = cur.Offset, size
+	}
+	ret := q.ret
+	if afterMode && len(ret) > 0 && !containsFold(ret, r.sField) {
+		ret = append(append([]string(nil), ret...), r.sField)
+	}
+
+	total, hits, err := q.search(ctx, q.argsWith(ret), q.noContent)
+	if err != nil {
+		return nil, err
+	}
+	items, err := decodeHits[T](hits)
+	if err != nil {
+		return nil, err
+	}
+	res := &PageResult[T]{Items: items, Total: total}
+	if pageToken == "" && r.hasFacets() {
+		if res.Facets, res.Ranges, err = r.facetCounts(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if len(hits) < size || int64(q.off+len(hits)) >= total {
+		return res, nil
+	}
+
+	next := pageCursor{FP: fp}
+	if afterMode && !q.noContent {
+		last, ok := sortValue(hits[len(hits)-1], r.sField)
+		if !ok {
+			return nil, fmt.Errorf("redisft: page: hit %q has no numeric %q", hits[len(hits)-1].key, r.sField)
+		}
+		ties := 0
+		for i := len(hits) - 1; i >= 0; i-- {
+			if v, ok := sortValue(hits[i], r.sField); !ok || v != last {
+				break
+			}
+			ties++
+		}
+		if ties == len(hits) && cur.After != nil && *cur.After == last {
+			ties += cur.Skip
+		}
+		next.After, next.Skip = &last, ties
+	} else {
+		next.Offset = q.off + len(hits)
+	}
+	if res.NextToken, err = r.encodeToken(next); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// fingerprint identifies the query and sort order a token was issued for.
+func (r *Repository[T]) fingerprint() uint64 {
+	h := sha256.New()
+	h.Write([]byte(r.index))
+	h.Write([]byte{0})
+	h.Write([]byte(r.query()))
+	if r.sSet {
+		fmt.Fprintf(h, "\x00%s\x00%t", r.sField, r.sAsc)
+	}
+	for _, k := range r.inKeys {
+		h.Write([]byte("\x00" + k))
+	}
+	for _, f := range r.inFields {
+		h.Write([]byte("\x01" + f))
+	}
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
+
+func (r *Repository[T]) encodeToken(c pageCursor) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, r.signingKey())
+	mac.Write(payload)
+	buf := append(payload, mac.Sum(nil)[:16]...)
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func (r *Repository[T]) decodeToken(tok string) (pageCursor, error) {
+	var c pageCursor
+	buf, err := base64.RawURLEncoding.DecodeString(tok)
+	if err != nil || len(buf) <= 16 {
+		return c, ErrInvalidPageToken
+	}
+	payload, sig := buf[:len(buf)-16], buf[len(buf)-16:]
+	mac := hmac.New(sha256.New, r.signingKey())
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)[:16]) {
+		return c, ErrInvalidPageToken
+	}
+	if err := json.Unmarshal(payload, &c); err != nil || c.Offset < 0 || c.Skip < 0 {
+		return c, ErrInvalidPageToken
+	}
+	return c, nil
+}
+
+func sortValue(h hit, field string) (float64, bool) {
+	v, ok := h.fields[strings.ToLower(field)]
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(replyString(v), 64)
+	return f, err == nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
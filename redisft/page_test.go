@@ -0,0 +1,48 @@
+package redisft
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPageToken_RoundTrip(t *testing.T) {
+	t.Parallel()
+	r := NewRepo[product](&Client{}).PageKey([]byte("secret")).Search(NewTagQB("color").Any("red"))
+	after := 19.5
+	tok, err := r.encodeToken(pageCursor{FP: r.fingerprint(), After: &after, Skip: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := r.decodeToken(tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.FP != r.fingerprint() || c.After == nil || *c.After != after || c.Skip != 2 {
+		t.Errorf("decoded cursor = %+v", c)
+	}
+
+	other := NewRepo[product](&Client{}).PageKey([]byte("other"))
+	if _, err := other.decodeToken(tok); !errors.Is(err, ErrInvalidPageToken) {
+		t.Errorf("foreign key: err = %v, want ErrInvalidPageToken", err)
+	}
+
+	tampered := []byte(tok)
+	tampered[3] ^= 1
+	if _, err := r.decodeToken(string(tampered)); !errors.Is(err, ErrInvalidPageToken) {
+		t.Errorf("tampered token: err = %v, want ErrInvalidPageToken", err)
+	}
+}
+
+func TestPage_TokenForOtherQuery(t *testing.T) {
+	t.Parallel()
+	r := NewRepo[product](&Client{}).PageKey([]byte("secret"))
+	tok, err := r.Search().SortBy("price", true).encodeToken(pageCursor{FP: r.fingerprint(), Offset: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Search().SortBy("price", false).Page(context.Background(), tok, 10)
+	if !errors.Is(err, ErrInvalidPageToken) {
+		t.Errorf("err = %v, want ErrInvalidPageToken", err)
+	}
+}
@@ -89,6 +89,20 @@ func structToMap(data interface{}) (map[string]interface{}, error) {
 	return fields, nil
 }
 
+// fieldNames lists the hash field names structToMap would use for t.
+func fieldNames(t reflect.Type) []string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		if sf := t.Field(i); sf.IsExported() {
+			names = append(names, strings.ToLower(sf.Name))
+		}
+	}
+	return names
+}
+
 func getStructName(data interface{}) string {
 	t := reflect.TypeOf(data)
 
@@ -106,18 +120,24 @@ func generateIndexQuery(input any) []any {
 	if v.Kind() != reflect.Struct {
 		panic("input not struct")
 	}
-	t := v.Type()
-	name := strings.ToLower(t.Name())
+	name := strings.ToLower(v.Type().Name())
+	return indexQuery("idx:"+name, name+":", v.Type())
+}
 
-	args := []any{"idx:" + name, "ON", "HASH", "PREFIX", 1, name + ":", "SCHEMA"}
+// indexQuery builds the FT.CREATE arguments for struct type t.
+func indexQuery(index, prefix string, t reflect.Type) []any {
+	args := []any{index, "ON", "HASH", "PREFIX", 1, prefix, "SCHEMA"}
 	for i := 0; i < t.NumField(); i++ {
 		sf := t.Field(i)
-		if tag := sf.Tag.Get("redis"); tag != "" {
-			args = append(args, strings.ToLower(sf.Name))
-			for _, tok := range strings.Fields(tag) {
-				args = append(args, strings.ToUpper(tok))
+		var toks []any
+		for _, tok := range strings.Fields(sf.Tag.Get("redis")) {
+			if tok = strings.ToUpper(tok); !metaTokens[tok] {
+				toks = append(toks, tok)
 			}
 		}
+		if len(toks) > 0 {
+			args = append(append(args, strings.ToLower(sf.Name)), toks...)
+		}
 	}
 
 	return args
@@ -147,7 +167,7 @@ func fillStruct(v reflect.Value, m map[string]interface{}) error {
 				} else if sec, err2 := strconv.ParseInt(t, 10, 64); err2 == nil {
 					field.Set(reflect.ValueOf(time.Unix(sec, 0)))
 				} else {
-					return fmt.Errorf("time parse %q: %v / %v", key, err, err2)
+					return &DecodeError{Field: key, Value: val, Err: err}
 				}
 			case float64:
 				field.Set(reflect.ValueOf(time.Unix(int64(t), 0)))
@@ -156,7 +176,7 @@ func fillStruct(v reflect.Value, m map[string]interface{}) error {
 			case time.Time:
 				field.Set(reflect.ValueOf(t))
 			default:
-				return fmt.Errorf("unsupported time type %T for %q", val, key)
+				return &DecodeError{Field: key, Value: val, Err: fmt.Errorf("unsupported time type %T", val)}
 			}
 			continue
 		}
@@ -170,7 +190,10 @@ func fillStruct(v reflect.Value, m map[string]interface{}) error {
 			var b bool
 			switch t := val.(type) {
 			case string:
-				b, _ = strconv.ParseBool(t)
+				var err error
+				if b, err = strconv.ParseBool(t); err != nil {
+					return &DecodeError{Field: key, Value: val, Err: err}
+				}
 			case bool:
 				b = t
 			}
@@ -179,7 +202,14 @@ func fillStruct(v reflect.Value, m map[string]interface{}) error {
 			var n int64
 			switch t := val.(type) {
 			case string:
-				n, _ = strconv.ParseInt(t, 10, 64)
+				var err error
+				if n, err = strconv.ParseInt(t, 10, 64); err != nil {
+					f, ferr := strconv.ParseFloat(t, 64)
+					if ferr != nil {
+						return &DecodeError{Field: key, Value: val, Err: err}
+					}
+					n = int64(f)
+				}
 			case float64:
 				n = int64(t)
 			case int64:
@@ -190,7 +220,14 @@ func fillStruct(v reflect.Value, m map[string]interface{}) error {
 			var n uint64
 			switch t := val.(type) {
 			case string:
-				n, _ = strconv.ParseUint(t, 10, 64)
+				var err error
+				if n, err = strconv.ParseUint(t, 10, 64); err != nil {
+					f, ferr := strconv.ParseFloat(t, 64)
+					if ferr != nil || f < 0 {
+						return &DecodeError{Field: key, Value: val, Err: err}
+					}
+					n = uint64(f)
+				}
 			case float64:
 				n = uint64(t)
 			case int64:
@@ -201,7 +238,10 @@ func fillStruct(v reflect.Value, m map[string]interface{}) error {
 			var f float64
 			switch t := val.(type) {
 			case string:
-				f, _ = strconv.ParseFloat(t, 64)
+				var err error
+				if f, err = strconv.ParseFloat(t, 64); err != nil {
+					return &DecodeError{Field: key, Value: val, Err: err}
+				}
 			case float64:
 				f = t
 			}
@@ -216,6 +256,15 @@ func fillStruct(v reflect.Value, m map[string]interface{}) error {
 	return nil
 }
 
+// withKey attaches the hash key to a DecodeError produced by fillStruct.
+func withKey(err error, key string) error {
+	var de *DecodeError
+	if errors.As(err, &de) && de.Key == "" {
+		de.Key = key
+	}
+	return err
+}
+
 func fillStructFromSlice(A interface{}, arr []interface{}) error {
 	v := reflect.ValueOf(A)
 	if v.Kind() != reflect.Ptr {
@@ -258,3 +307,46 @@ func fillStructFromSlice(A interface{}, arr []interface{}) error {
 		return errors.New("A must be a pointer to a struct or slice")
 	}
 }
+
+func replyString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+func replyInt(v interface{}) int64 {
+	switch t := v.(type) {
+	case int64:
+		return t
+	case float64:
+		return int64(t)
+	case string:
+		n, err := strconv.ParseInt(t, 10, 64)
+		if err != nil {
+			f, _ := strconv.ParseFloat(t, 64)
+			n = int64(f)
+		}
+		return n
+	}
+	return 0
+}
+
+func replyFloat(v interface{}) float64 {
+	switch t := v.(type) {
+	case float64:
+		return t
+	case int64:
+		return float64(t)
+	case string:
+		f, _ := strconv.ParseFloat(t, 64)
+		return f
+	}
+	return 0
+}
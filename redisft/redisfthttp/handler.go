@@ -0,0 +1,508 @@
+// Package redisfthttp exposes a redisft Repository as a JSON API.
+//
+//	GET    /search?color=red,blue&price=10:50&sort=price:desc&page_size=20&facets=color
+//	POST   /search   {"filters": {"color": "red"}, "sort": "price", "page_size": 20, "facets": ["color"]}
+//	GET    /docs/{id}
+//	PUT    /docs/{id}   (with Options.AllowWrites)
+//	PATCH  /docs/{id}   (with Options.AllowWrites)
+//	DELETE /docs/{id}   (with Options.AllowWrites)
+//
+// Every query parameter other than page_size and page_token is read by
+// Repository.ParseFilter, which documents the grammar:
+//
+//	name=redis action   color=in:red,blue   price=between:10,50
+//	near=29,41,10km     sort=-price
+//
+// Unlike in ParseFilter, repeating a parameter, or giving an array in a JSON
+// body, adds alternatives: price=:10&price=100: matches either range.
+// Filters on different fields are combined with AND.
+//
+// facets=color,brand adds the value counts of those TAG fields over all hits
+// to the first page, at most facet_limit (default 10) values each:
+//
+//	"facets": [{"field": "color", "values": [{"value": "red", "count": 120}, ...]}]
+//
+// Invalid parameters are answered with 400 and one entry per parameter:
+//
+//	{"error": "...", "fields": [{"param": "price", "value": "cheap", "error": "..."}]}
+//
+// Search responses carry the documents, the hit count and a page token for
+// the next page; as with Repository.Page, total is exact on the first page:
+//
+//	{"total": 42, "items": [...], "next_page_token": "..."}
+//
+// Mount the handler under a path with http.StripPrefix.
+package redisfthttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bariscan97/redis-ftsearch/redisft"
+)
+
+// Options customises NewHandler. The zero value serves read-only search and
+// get over every indexed field.
+type Options struct {
+	// Filterable lists the fields that may be filtered on. Empty means every
+	// indexed field.
+	Filterable []string
+	// Sortable lists the fields that may be sorted by. Empty means every
+	// SORTABLE field.
+	Sortable []string
+	// Facetable lists the TAG fields whose value counts may be requested
+	// with facets. Empty means every filterable TAG field.
+	Facetable []string
+	// MaxFacetLimit is the largest facet_limit accepted. Default 100.
+	MaxFacetLimit int
+	// DefaultPageSize is used when a request sets no page_size. Default 20,
+	// or MaxPageSize if smaller.
+	DefaultPageSize int
+	// MaxPageSize is the largest page_size accepted. Default 100.
+	MaxPageSize int
+	// AllowWrites enables PUT, PATCH and DELETE on /docs/{id}.
+	AllowWrites bool
+	// MaxBodyBytes limits request bodies. Default 1 MiB.
+	MaxBodyBytes int64
+	// OnError is called with every error answered with a 5xx status, whose
+	// response only says "internal error".
+	OnError func(r *http.Request, err error)
+}
+
+type handler[T any] struct {
+	repo      *redisft.Repository[T]
+	opts      Options
+	facetable map[string]bool
+	mux       *http.ServeMux
+}
+
+// NewHandler returns an http.Handler serving repo. repo is only read: each
+// request runs on a clone, so the handler is safe for concurrent use. It
+// fails if an allow-list names a field that is not indexed, or a Sortable
+// field that is not SORTABLE.
+func NewHandler[T any](repo *redisft.Repository[T], opts Options) (http.Handler, error) {
+	if opts.MaxPageSize == 0 {
+		opts.MaxPageSize = 100
+	}
+	if opts.DefaultPageSize == 0 {
+		opts.DefaultPageSize = min(20, opts.MaxPageSize)
+	}
+	if opts.MaxBodyBytes == 0 {
+		opts.MaxBodyBytes = 1 << 20
+	}
+	if opts.MaxFacetLimit == 0 {
+		opts.MaxFacetLimit = 100
+	}
+	if opts.DefaultPageSize < 1 || opts.MaxPageSize < opts.DefaultPageSize {
+		return nil, fmt.Errorf("redisfthttp: invalid page sizes: default %d, max %d", opts.DefaultPageSize, opts.MaxPageSize)
+	}
+
+	schema := map[string]redisft.SchemaField{}
+	for _, f := range repo.Schema() {
+		schema[f.Name] = f
+	}
+	filterable := map[string]bool{}
+	for _, name := range opts.Filterable {
+		if _, ok := schema[strings.ToLower(name)]; !ok {
+			return nil, fmt.Errorf("redisfthttp: Filterable: %q is not an indexed field", name)
+		}
+		filterable[strings.ToLower(name)] = true
+	}
+	for _, name := range opts.Sortable {
+		if f, ok := schema[strings.ToLower(name)]; !ok || !f.Sortable {
+			return nil, fmt.Errorf("redisfthttp: Sortable: %q is not a SORTABLE field", name)
+		}
+	}
+
+	h := &handler[T]{repo: repo, opts: opts, facetable: map[string]bool{}}
+	for _, name := range opts.Facetable {
+		if f, ok := schema[strings.ToLower(name)]; !ok || f.Type != redisft.FieldTag {
+			return nil, fmt.Errorf("redisfthttp: Facetable: %q is not a TAG field", name)
+		}
+		h.facetable[strings.ToLower(name)] = true
+	}
+	if len(opts.Facetable) == 0 {
+		for _, f := range schema {
+			if f.Type == redisft.FieldTag && (len(opts.Filterable) == 0 || filterable[f.Name]) {
+				h.facetable[f.Name] = true
+			}
+		}
+	}
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc("GET /search", h.search)
+	h.mux.HandleFunc("POST /search", h.search)
+	h.mux.HandleFunc("GET /docs/{id}", h.get)
+	if opts.AllowWrites {
+		h.mux.HandleFunc("PUT /docs/{id}", h.put)
+		h.mux.HandleFunc("PATCH /docs/{id}", h.patch)
+		h.mux.HandleFunc("DELETE /docs/{id}", h.delete)
+	}
+	return h, nil
+}
+
+func (h *handler[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) { h.mux.ServeHTTP(w, r) }
+
+// searchResponse is the body of a search response.
+type searchResponse[T any] struct {
+	Total         int64   `json:"total"`
+	Items         []T     `json:"items"`
+	NextPageToken string  `json:"next_page_token,omitempty"`
+	Facets        []facet `json:"facets,omitempty"`
+}
+
+type facet struct {
+	Field  string       `json:"field"`
+	Values []facetValue `json:"values"`
+}
+
+type facetValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// searchBody is the JSON form of a search; filter values may be a string or
+// an array of strings.
+type searchBody struct {
+	Filters    map[string]stringList `json:"filters"`
+	Sort       string                `json:"sort"`
+	PageSize   int                   `json:"page_size"`
+	PageToken  string                `json:"page_token"`
+	Facets     []string              `json:"facets"`
+	FacetLimit int                   `json:"facet_limit"`
+}
+
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*l = stringList{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(l))
+}
+
+// Reserved search parameters; all others are filters.
+const (
+	paramSort       = "sort"
+	paramPageSize   = "page_size"
+	paramPageToken  = "page_token"
+	paramFacets     = "facets"
+	paramFacetLimit = "facet_limit"
+)
+
+func (h *handler[T]) search(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if r.Method == http.MethodPost {
+		var body searchBody
+		if !h.decode(w, r, &body) {
+			return
+		}
+		params = url.Values{}
+		for k, v := range body.Filters {
+			params[k] = v
+		}
+		if body.Sort != "" {
+			params.Set(paramSort, body.Sort)
+		}
+		if body.PageSize != 0 {
+			params.Set(paramPageSize, strconv.Itoa(body.PageSize))
+		}
+		if body.PageToken != "" {
+			params.Set(paramPageToken, body.PageToken)
+		}
+		if len(body.Facets) > 0 {
+			params.Set(paramFacets, strings.Join(body.Facets, ","))
+		}
+		if body.FacetLimit != 0 {
+			params.Set(paramFacetLimit, strconv.Itoa(body.FacetLimit))
+		}
+	}
+
+	size := h.opts.DefaultPageSize
+	if s := params.Get(paramPageSize); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > h.opts.MaxPageSize {
+			h.fail(w, r, http.StatusBadRequest, fmt.Errorf("page_size must be between 1 and %d", h.opts.MaxPageSize))
+			return
+		}
+		size = n
+	}
+	for _, p := range []string{"limit", "offset"} {
+		if params.Has(p) {
+			h.fail(w, r, http.StatusBadRequest, fmt.Errorf("%s is not supported; use page_size and page_token", p))
+			return
+		}
+	}
+	builders, sort, err := h.filters(params)
+	if err != nil {
+		h.fail(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	q := h.repo.Clone().Search(builders...)
+	if sort != nil {
+		q.OrderBy(*sort)
+	}
+	if err := h.facets(q, params); err != nil {
+		h.fail(w, r, http.StatusBadRequest, err)
+		return
+	}
+	page, err := q.Page(r.Context(), params.Get(paramPageToken), size)
+	if err != nil {
+		h.fail(w, r, status(err), err)
+		return
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	resp := searchResponse[T]{Total: page.Total, Items: page.Items, NextPageToken: page.NextToken}
+	for _, f := range page.Facets {
+		out := facet{Field: f.Field, Values: make([]facetValue, len(f.Values))}
+		for i, v := range f.Values {
+			out.Values[i] = facetValue{Value: v.Value, Count: v.Count}
+		}
+		resp.Facets = append(resp.Facets, out)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// filters reads the filter and sort parameters. ParseFilter requires every
+// value of a repeated parameter to hold; here they are alternatives, as the
+// package documents, so each value is parsed on its own and the results are
+// ORed.
+func (h *handler[T]) filters(params url.Values) ([]redisft.Builder, *redisft.Sort, error) {
+	names := make([]string, 0, len(params))
+	for k := range params {
+		switch k {
+		case paramPageSize, paramPageToken, paramFacets, paramFacetLimit:
+		default:
+			names = append(names, k)
+		}
+	}
+	slices.Sort(names)
+
+	opts := redisft.FilterOptions{Fields: h.opts.Filterable, Sortable: h.opts.Sortable}
+	var (
+		errs     redisft.FieldErrors
+		builders []redisft.Builder
+		sort     *redisft.Sort
+		byField  = map[string]string{} // field -> parameter filtering it
+	)
+	parse := func(name string, values ...string) *redisft.Filter {
+		f, err := h.repo.ParseFilter(url.Values{name: values}, opts)
+		var fe redisft.FieldErrors
+		if errors.As(err, &fe) {
+			errs = append(errs, fe...)
+			return nil
+		}
+		return f
+	}
+	for _, name := range names {
+		if name == paramSort {
+			if f := parse(name, params[name]...); f != nil {
+				sort = f.Sort
+			}
+			continue
+		}
+		var alts anyOf
+		for _, v := range params[name] {
+			if f := parse(name, v); f != nil {
+				alts = append(alts, f.Builders...)
+			}
+		}
+		if len(alts) == 0 {
+			continue
+		}
+		field := alts[0].GetFieldName()
+		if prev, dup := byField[field]; dup {
+			errs = append(errs, &redisft.FieldError{Param: name, Err: fmt.Errorf("filters the same field as %s", prev)})
+			continue
+		}
+		byField[field] = name
+		if len(alts) == 1 {
+			builders = append(builders, alts[0])
+		} else {
+			builders = append(builders, alts)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, errs
+	}
+	return builders, sort, nil
+}
+
+// anyOf matches the documents matching any of its builders, which all
+// filter the same field.
+type anyOf []redisft.Builder
+
+func (a anyOf) GetFieldName() string { return a[0].GetFieldName() }
+
+func (a anyOf) Build() string {
+	parts := make([]string, len(a))
+	for i, b := range a {
+		parts[i] = b.Build()
+	}
+	return "(" + strings.Join(parts, " | ") + ")"
+}
+
+// facets adds the facets and facet_limit parameters to q.
+func (h *handler[T]) facets(q *redisft.Repository[T], params url.Values) error {
+	var errs redisft.FieldErrors
+	for _, v := range params[paramFacets] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			if !h.facetable[strings.ToLower(name)] {
+				errs = append(errs, &redisft.FieldError{Param: paramFacets, Value: name, Err: errors.New("field is not facetable")})
+				continue
+			}
+			q.Facets(name)
+		}
+	}
+	if s := params.Get(paramFacetLimit); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > h.opts.MaxFacetLimit {
+			errs = append(errs, &redisft.FieldError{Param: paramFacetLimit, Value: s, Err: fmt.Errorf("must be between 1 and %d", h.opts.MaxFacetLimit)})
+		} else {
+			q.FacetLimit(n)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (h *handler[T]) get(w http.ResponseWriter, r *http.Request) {
+	doc, err := h.repo.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.fail(w, r, status(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// put replaces the document with the body: fields absent from the body are
+// cleared, as with Repository.Replace. It answers with the stored document.
+func (h *handler[T]) put(w http.ResponseWriter, r *http.Request) {
+	doc := new(T)
+	if !h.decode(w, r, doc) {
+		return
+	}
+	ctx, id := r.Context(), r.PathValue("id")
+	if err := h.repo.Replace(ctx, id, doc); err != nil {
+		h.fail(w, r, status(err), err)
+		return
+	}
+	stored, err := h.repo.Get(ctx, id)
+	if err != nil {
+		h.fail(w, r, status(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stored)
+}
+
+// patch writes the non-zero fields of the body to an existing document,
+// checking that it exists in the same transaction as the write.
+func (h *handler[T]) patch(w http.ResponseWriter, r *http.Request) {
+	var patch T
+	if !h.decode(w, r, &patch) {
+		return
+	}
+	ctx, id := r.Context(), r.PathValue("id")
+	if err := h.repo.UpdateIfExists(ctx, id, patch); err != nil {
+		h.fail(w, r, status(err), err)
+		return
+	}
+	doc, err := h.repo.Get(ctx, id)
+	if err != nil {
+		h.fail(w, r, status(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+func (h *handler[T]) delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.repo.Delete(r.Context(), r.PathValue("id")); err != nil {
+		h.fail(w, r, status(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decode reads a JSON request body into v, answering 400 on failure.
+func (h *handler[T]) decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.opts.MaxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		h.fail(w, r, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %w", err))
+		return false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		h.fail(w, r, http.StatusBadRequest, errors.New("invalid JSON body: trailing data"))
+		return false
+	}
+	return true
+}
+
+// status maps a repository error to an HTTP status.
+func status(err error) int {
+	var qse *redisft.QuerySyntaxError
+	switch {
+	case errors.Is(err, redisft.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, redisft.ErrInvalidPageToken), errors.As(err, &qse):
+		return http.StatusBadRequest
+	case errors.Is(err, redisft.ErrVersionConflict):
+		return http.StatusConflict
+	case errors.Is(err, redisft.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// errorResponse is the body of every error response. Fields lists the
+// invalid search parameters.
+type errorResponse struct {
+	Error  string       `json:"error"`
+	Fields []fieldError `json:"fields,omitempty"`
+}
+
+type fieldError struct {
+	Param string `json:"param"`
+	Value string `json:"value,omitempty"`
+	Error string `json:"error"`
+}
+
+func (h *handler[T]) fail(w http.ResponseWriter, r *http.Request, code int, err error) {
+	resp := errorResponse{Error: err.Error()}
+	if code >= 500 {
+		if h.opts.OnError != nil {
+			h.opts.OnError(r, err)
+		}
+		resp.Error = "internal error"
+	}
+	var fe redisft.FieldErrors
+	if errors.As(err, &fe) {
+		for _, e := range fe {
+			resp.Fields = append(resp.Fields, fieldError{Param: e.Param, Value: e.Value, Error: e.Err.Error()})
+		}
+	}
+	writeJSON(w, code, resp)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
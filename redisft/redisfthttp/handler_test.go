@@ -0,0 +1,295 @@
+package redisfthttp_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bariscan97/redis-ftsearch/redisft"
+	"github.com/bariscan97/redis-ftsearch/redisft/redisfthttp"
+	"github.com/bariscan97/redis-ftsearch/redisft/redisfttest"
+)
+
+type Product struct {
+	ID       string  `redis:"text sortable"`
+	Name     string  `redis:"text"`
+	Price    float64 `redis:"numeric sortable"`
+	Location string  `redis:"geo"`
+	Color    string  `redis:"tag"`
+	Secret   string  `redis:"tag"`
+}
+
+func newServer(t *testing.T, opts redisfthttp.Options) *httptest.Server {
+	t.Helper()
+	cli := redisfttest.NewServer().Client()
+	t.Cleanup(func() { cli.Close() })
+	ctx := context.Background()
+	repo := redisft.NewRepo[Product](cli)
+	if err := repo.CreateIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	err := repo.InsertMany(ctx, map[string]*Product{
+		"1": {ID: "1", Name: "Warcraft strategy guide", Price: 19.9, Location: "29.0,41.0", Color: "red"},
+		"2": {ID: "2", Name: "Go programming", Price: 45, Location: "29.1,41.0", Color: "blue"},
+		"3": {ID: "3", Name: "Redis in action", Price: 60, Location: "-122.4,37.7", Color: "red,green"},
+		"4": {ID: "4", Name: "Starcraft tutorial", Price: 120, Location: "28.9,41.1", Color: "green"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := redisfthttp.NewHandler(repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type searchResult struct {
+	Total         int64     `json:"total"`
+	Items         []Product `json:"items"`
+	NextPageToken string    `json:"next_page_token"`
+	Error         string    `json:"error"`
+}
+
+func call(t *testing.T, method, url, body string, out any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func productIDs(ps []Product) []string {
+	out := []string{}
+	for _, p := range ps {
+		out = append(out, p.ID)
+	}
+	return out
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+	srv := newServer(t, redisfthttp.Options{Filterable: []string{"name", "price", "location", "color"}, MaxPageSize: 3})
+
+	tests := []struct {
+		name   string
+		query  url.Values
+		code   int
+		want   []string
+		errMsg string
+	}{
+		{"all", url.Values{"sort": {"price"}}, 200, []string{"1", "2", "3"}, ""},
+		{"tag", url.Values{"color": {"red,blue"}, "sort": {"price:desc"}}, 200, []string{"3", "2", "1"}, ""},
+		{"range", url.Values{"price": {"40:100"}, "sort": {"price"}}, 200, []string{"2", "3"}, ""},
+		{"open ranges", url.Values{"price": {":20", "100:"}, "sort": {"price"}}, 200, []string{"1", "4"}, ""},
+		{"operators", url.Values{"price": {"gt:19.9"}, "color": {"nin:blue"}, "sort": {"price"}}, 200, []string{"3", "4"}, ""},
+		{"alternative operators", url.Values{"price": {"lt:20", "between:50,70"}, "sort": {"price"}}, 200, []string{"1", "3"}, ""},
+		{"near", url.Values{"near": {"29,41,20km"}, "sort": {"-price"}}, 200, []string{"4", "2", "1"}, ""},
+		{"text and geo", url.Values{"name": {"starcraft"}, "location": {"29,41,20km"}}, 200, []string{"4"}, ""},
+		{"not filterable", url.Values{"secret": {"x"}}, 400, nil, "redisft: invalid filter: secret: field is not filterable"},
+		{"unknown", url.Values{"colour": {"red"}}, 400, nil, "redisft: invalid filter: colour: unknown field"},
+		{"bad number", url.Values{"price": {"cheap"}}, 400, nil, `redisft: invalid filter: price=cheap: "cheap" is not a number`},
+		{"not sortable", url.Values{"sort": {"name"}}, 400, nil, `redisft: invalid filter: sort=name: cannot sort by "name"`},
+		{"offset", url.Values{"offset": {"2"}}, 400, nil, "offset is not supported; use page_size and page_token"},
+		{"near and location", url.Values{"near": {"29,41,20km"}, "location": {"29,41,5km"}}, 400, nil, "redisft: invalid filter: near: filters the same field as location"},
+		{"page too large", url.Values{"page_size": {"4"}}, 400, nil, "page_size must be between 1 and 3"},
+		{"bad token", url.Values{"page_token": {"x"}}, 400, nil, "redisft: invalid page token"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var res searchResult
+			code := call(t, "GET", srv.URL+"/search?"+tc.query.Encode(), "", &res)
+			if code != tc.code || res.Error != tc.errMsg {
+				t.Fatalf("status %d error %q, want %d %q", code, res.Error, tc.code, tc.errMsg)
+			}
+			if tc.want != nil && !reflect.DeepEqual(productIDs(res.Items), tc.want) {
+				t.Errorf("items = %v, want %v", productIDs(res.Items), tc.want)
+			}
+		})
+	}
+}
+
+func TestSearchPagesWithJSONBody(t *testing.T) {
+	t.Parallel()
+	srv := newServer(t, redisfthttp.Options{})
+
+	var got []string
+	token := ""
+	for {
+		body, _ := json.Marshal(map[string]any{"filters": map[string]any{"color": []string{"red", "green"}}, "sort": "price", "page_size": 2, "page_token": token})
+		var res searchResult
+		if code := call(t, "POST", srv.URL+"/search", string(body), &res); code != 200 {
+			t.Fatalf("status %d: %s", code, res.Error)
+		}
+		if token == "" && res.Total != 3 {
+			t.Errorf("total = %d, want 3", res.Total)
+		}
+		got = append(got, productIDs(res.Items)...)
+		if token = res.NextPageToken; token == "" {
+			break
+		}
+	}
+	if want := []string{"1", "3", "4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+
+	var res searchResult
+	if code := call(t, "POST", srv.URL+"/search", `{"filter": {}}`, &res); code != 400 {
+		t.Errorf("unknown body field: status %d", code)
+	}
+
+	var bad struct {
+		Fields []map[string]string `json:"fields"`
+	}
+	code := call(t, "POST", srv.URL+"/search", `{"filters": {"price": ["cheap"], "colour": "red"}}`, &bad)
+	want := []map[string]string{
+		{"param": "colour", "error": "unknown field"},
+		{"param": "price", "value": "cheap", "error": `"cheap" is not a number`},
+	}
+	if code != 400 || !reflect.DeepEqual(bad.Fields, want) {
+		t.Errorf("invalid filters: status %d, fields %v", code, bad.Fields)
+	}
+}
+
+func TestDocs(t *testing.T) {
+	t.Parallel()
+	ro := newServer(t, redisfthttp.Options{})
+	var p Product
+	if code := call(t, "GET", ro.URL+"/docs/2", "", &p); code != 200 || p.Name != "Go programming" {
+		t.Fatalf("get: %d %+v", code, p)
+	}
+	var e map[string]string
+	if code := call(t, "GET", ro.URL+"/docs/9", "", &e); code != 404 {
+		t.Fatalf("get missing: %d %v", code, e)
+	}
+	if code := call(t, "DELETE", ro.URL+"/docs/2", "", nil); code != http.StatusMethodNotAllowed {
+		t.Fatalf("delete on read-only handler: %d", code)
+	}
+
+	rw := newServer(t, redisfthttp.Options{AllowWrites: true})
+	if code := call(t, "PUT", rw.URL+"/docs/5", `{"ID": "5", "Name": "Rust book", "Price": 30, "Color": "blue"}`, &p); code != 200 {
+		t.Fatalf("put: %d", code)
+	}
+	if code := call(t, "PATCH", rw.URL+"/docs/5", `{"Price": 35}`, &p); code != 200 || p.Price != 35 || p.Name != "Rust book" {
+		t.Fatalf("patch: %d %+v", code, p)
+	}
+	if code := call(t, "PATCH", rw.URL+"/docs/9", `{"Price": 35}`, &e); code != 404 {
+		t.Fatalf("patch missing: %d", code)
+	}
+	var res searchResult
+	call(t, "GET", rw.URL+"/search?color=blue&sort=price", "", &res)
+	if want := []string{"5", "2"}; !reflect.DeepEqual(productIDs(res.Items), want) {
+		t.Fatalf("search after put = %v, want %v", productIDs(res.Items), want)
+	}
+	var put Product
+	if code := call(t, "PUT", rw.URL+"/docs/5", `{"ID": "5", "Name": "Rust book, 2nd edition", "Price": 40}`, &put); code != 200 ||
+		put != (Product{ID: "5", Name: "Rust book, 2nd edition", Price: 40}) {
+		t.Fatalf("put replacing: %d %+v", code, put)
+	}
+	var got Product
+	if code := call(t, "GET", rw.URL+"/docs/5", "", &got); code != 200 || got != put {
+		t.Fatalf("get after put = %d %+v, want %+v", code, got, put)
+	}
+	if code := call(t, "DELETE", rw.URL+"/docs/5", "", nil); code != http.StatusNoContent {
+		t.Fatalf("delete: %d", code)
+	}
+	if code := call(t, "GET", rw.URL+"/docs/5", "", &e); code != 404 {
+		t.Fatalf("get after delete: %d", code)
+	}
+}
+
+func TestPatchRacingDelete(t *testing.T) {
+	t.Parallel()
+	fake := redisfttest.NewServer()
+	cli := fake.Client()
+	t.Cleanup(func() { cli.Close() })
+	ctx := context.Background()
+	repo := redisft.NewRepo[Product](cli)
+	if err := repo.Insert(ctx, "1", &Product{ID: "1", Name: "Go programming", Price: 45}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := redisfthttp.NewHandler(repo, redisfthttp.Options{AllowWrites: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	// The document is deleted after PATCH found it but before it is written.
+	fake.BeforeCommand("HSET", func() { fake.Dial().Del(ctx, "product:1") })
+	var e map[string]any
+	if code := call(t, "PATCH", srv.URL+"/docs/1", `{"Price": 50}`, &e); code != 404 {
+		t.Fatalf("patch racing delete: %d %v", code, e)
+	}
+	if h := fake.Hash("product:1"); h != nil {
+		t.Errorf("patch recreated the deleted document: %v", h)
+	}
+}
+
+func TestNewHandlerValidatesAllowLists(t *testing.T) {
+	t.Parallel()
+	repo := redisft.NewRepo[Product](redisfttest.NewServer().Client())
+	for _, opts := range []redisfthttp.Options{
+		{Filterable: []string{"nope"}},
+		{Sortable: []string{"name"}},
+		{DefaultPageSize: 50, MaxPageSize: 10},
+	} {
+		if _, err := redisfthttp.NewHandler(repo, opts); err == nil {
+			t.Errorf("NewHandler(%+v): expected error", opts)
+		}
+	}
+}
+
+func TestSearchFacets(t *testing.T) {
+	t.Parallel()
+	srv := newServer(t, redisfthttp.Options{Filterable: []string{"price", "color"}})
+
+	var res struct {
+		Total  int64 `json:"total"`
+		Facets []struct {
+			Field  string `json:"field"`
+			Values []struct {
+				Value string `json:"value"`
+				Count int64  `json:"count"`
+			} `json:"values"`
+		} `json:"facets"`
+		Fields []map[string]string `json:"fields"`
+	}
+	if code := call(t, "GET", srv.URL+"/search?price=lt:100&facets=color&facet_limit=2&page_size=1", "", &res); code != 200 {
+		t.Fatalf("status %d", code)
+	}
+	got, _ := json.Marshal(res.Facets)
+	if want := `[{"field":"color","values":[{"value":"red","count":2},{"value":"blue","count":1}]}]`; string(got) != want {
+		t.Errorf("facets = %s, want %s", got, want)
+	}
+
+	if code := call(t, "POST", srv.URL+"/search", `{"facets": ["secret", "color"], "facet_limit": 1000}`, &res); code != 400 {
+		t.Fatalf("status %d", code)
+	}
+	want := []map[string]string{
+		{"param": "facets", "value": "secret", "error": "field is not facetable"},
+		{"param": "facet_limit", "value": "1000", "error": "must be between 1 and 100"},
+	}
+	if !reflect.DeepEqual(res.Fields, want) {
+		t.Errorf("fields = %v, want %v", res.Fields, want)
+	}
+}
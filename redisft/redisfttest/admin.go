@@ -0,0 +1,214 @@
+package redisfttest
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FT.INFO idx
+//
+// Only the sections redisft reads are returned: index_name, index_options,
+// index_definition, attributes and num_docs.
+func cmdFTInfo(s *Server, c *conn, args []string) any {
+	if len(args) != 2 {
+		return wrongArgs(args)
+	}
+	ix, errR := s.lookupIndex(args[1])
+	if errR != nil {
+		return errR
+	}
+	prefixes := make([]any, len(ix.prefixes))
+	for i, p := range ix.prefixes {
+		prefixes[i] = p
+	}
+	attrs := make([]any, len(ix.fields))
+	for i, f := range ix.fields {
+		a := []any{"identifier", f.name, "attribute", f.alias, "type", f.typ}
+		if f.typ == "TAG" {
+			a = append(a, "SEPARATOR", f.sep)
+		}
+		if f.sortable {
+			a = append(a, "SORTABLE")
+		}
+		attrs[i] = a
+	}
+	return []any{
+		"index_name", ix.name,
+		"index_options", []any{},
+		"index_definition", []any{"key_type", "HASH", "prefixes", prefixes, "default_score", "1"},
+		"attributes", attrs,
+		"num_docs", strconv.Itoa(len(s.docs(ix))),
+	}
+}
+
+// FT.TAGVALS idx field
+//
+// Values are returned in the order they are first met, as RediSearch makes
+// no promise about the order.
+func cmdFTTagVals(s *Server, c *conn, args []string) any {
+	if len(args) != 3 {
+		return wrongArgs(args)
+	}
+	ix, errR := s.lookupIndex(args[1])
+	if errR != nil {
+		return errR
+	}
+	f := ix.field(args[2])
+	if f == nil {
+		return errorf("No such field `%s`", args[2])
+	}
+	if f.typ != "TAG" {
+		return errorf("Not a tag field")
+	}
+	seen := map[string]bool{}
+	out := []any{}
+	for _, d := range s.docs(ix) {
+		for _, t := range d.tags(f) {
+			if !seen[t] {
+				seen[t] = true
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+// FT.ALIASADD alias idx | FT.ALIASUPDATE alias idx
+func cmdFTAliasAdd(s *Server, c *conn, args []string) any {
+	if len(args) != 3 {
+		return wrongArgs(args)
+	}
+	if _, ok := s.indexes[args[2]]; !ok {
+		return errorf("Unknown index name (or name is an alias itself)")
+	}
+	if _, taken := s.aliases[args[1]]; taken && strings.EqualFold(args[0], "FT.ALIASADD") {
+		return errorf("Alias already exists")
+	}
+	if s.aliases == nil {
+		s.aliases = map[string]string{}
+	}
+	s.aliases[args[1]] = args[2]
+	return status("OK")
+}
+
+// FT.ALIASDEL alias
+func cmdFTAliasDel(s *Server, c *conn, args []string) any {
+	if len(args) != 2 {
+		return wrongArgs(args)
+	}
+	if _, ok := s.aliases[args[1]]; !ok {
+		return errorf("Alias does not exist")
+	}
+	delete(s.aliases, args[1])
+	return status("OK")
+}
+
+// FT.SUGADD key string score [INCR] [PAYLOAD payload]
+func cmdFTSugAdd(s *Server, c *conn, args []string) any {
+	if len(args) < 4 {
+		return wrongArgs(args)
+	}
+	score, err := strconv.ParseFloat(args[3], 64)
+	if err != nil {
+		return errorf("ERR invalid score")
+	}
+	if s.suggestions == nil {
+		s.suggestions = map[string]map[string]float64{}
+	}
+	dict := s.suggestions[args[1]]
+	if dict == nil {
+		dict = map[string]float64{}
+		s.suggestions[args[1]] = dict
+	}
+	if containsFold(args[4:], "INCR") {
+		score += dict[args[2]]
+	}
+	dict[args[2]] = score
+	return len(dict)
+}
+
+// FT.SUGGET key prefix [FUZZY] [MAX n] [WITHSCORES]
+//
+// FUZZY accepts one edit between the prefix and the start of an entry.
+func cmdFTSugGet(s *Server, c *conn, args []string) any {
+	if len(args) < 3 {
+		return wrongArgs(args)
+	}
+	fuzzy, withScores, max := false, false, 5
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "FUZZY":
+			fuzzy = true
+		case "WITHSCORES":
+			withScores = true
+		case "MAX":
+			n, ok := atoi(arg(args, i+1))
+			if !ok {
+				return errorf("ERR invalid MAX")
+			}
+			max = n
+			i++
+		}
+	}
+	prefix := strings.ToLower(args[2])
+	type sug struct {
+		s     string
+		score float64
+	}
+	var out []sug
+	for str, score := range s.suggestions[args[1]] {
+		lower := strings.ToLower(str)
+		if strings.HasPrefix(lower, prefix) ||
+			fuzzy && len(lower) >= len(prefix)-1 && editDistance(prefix, lower[:min(len(lower), len(prefix))]) <= 1 {
+			out = append(out, sug{str, score})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].score != out[j].score {
+			return out[i].score > out[j].score
+		}
+		return out[i].s < out[j].s
+	})
+	if len(out) > max {
+		out = out[:max]
+	}
+	reply := []any{}
+	for _, sg := range out {
+		reply = append(reply, sg.s)
+		if withScores {
+			reply = append(reply, strconv.FormatFloat(sg.score, 'f', -1, 64))
+		}
+	}
+	return reply
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,408 @@
+package redisfttest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// row is one record flowing through an FT.AGGREGATE pipeline. vals holds
+// every property the row can reference; keys lists the ones returned to the
+// client, in order.
+type row struct {
+	keys []string
+	vals map[string]any
+}
+
+func (r *row) get(name string) any { return r.vals[name] }
+
+func (r *row) set(name string, v any) {
+	if !containsStr(r.keys, name) {
+		r.keys = append(r.keys, name)
+	}
+	r.vals[name] = v
+}
+
+func containsStr(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+type reducer struct {
+	fn    string
+	args  []string
+	alias string
+}
+
+// FT.AGGREGATE idx query [LOAD …] [GROUPBY … REDUCE …]* [APPLY … AS …]*
+// [FILTER …] [SORTBY …] [LIMIT …]
+func cmdFTAggregate(s *Server, c *conn, args []string) any {
+	if len(args) < 3 {
+		return wrongArgs(args)
+	}
+	ix, errR := s.lookupIndex(args[1])
+	if errR != nil {
+		return errR
+	}
+	docs, errR := s.match(ix, args[2], nil, nil)
+	if errR != nil {
+		return errR
+	}
+	rows := make([]*row, 0, len(docs))
+	for _, d := range docs {
+		r := &row{vals: map[string]any{"__key": d.key}}
+		for _, f := range ix.fields {
+			if v, ok := d.value(f); ok {
+				r.vals[f.alias] = v
+			}
+		}
+		rows = append(rows, r)
+	}
+
+	total := -1
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "VERBATIM":
+		case "DIALECT", "TIMEOUT":
+			i++
+		case "LOAD":
+			if arg(args, i+1) == "*" {
+				for k, r := range rows {
+					if docs == nil {
+						break
+					}
+					for f, v := range docs[k].hash {
+						r.set(f, v)
+					}
+				}
+				i++
+				continue
+			}
+			n, ok := atoi(arg(args, i+1))
+			if !ok || i+1+n >= len(args) {
+				return errorf("Bad arguments for LOAD")
+			}
+			for _, f := range args[i+2 : i+2+n] {
+				f = strings.TrimPrefix(f, "@")
+				for k, r := range rows {
+					if docs == nil {
+						break
+					}
+					if v, ok := docs[k].hash[f]; ok {
+						r.set(f, v)
+					}
+				}
+			}
+			i += 1 + n
+		case "GROUPBY":
+			n, ok := atoi(arg(args, i+1))
+			if !ok || i+1+n >= len(args) {
+				return errorf("Bad arguments for GROUPBY")
+			}
+			props := make([]string, n)
+			for k, p := range args[i+2 : i+2+n] {
+				props[k] = strings.TrimPrefix(p, "@")
+			}
+			i += 1 + n
+			var reds []reducer
+			for strings.EqualFold(arg(args, i+1), "REDUCE") {
+				red := reducer{fn: strings.ToUpper(arg(args, i+2))}
+				nargs, ok := atoi(arg(args, i+3))
+				if !ok || nargs < 0 || i+3+nargs >= len(args) {
+					return errorf("Bad arguments for REDUCE")
+				}
+				for _, a := range args[i+4 : i+4+nargs] {
+					red.args = append(red.args, strings.TrimPrefix(a, "@"))
+				}
+				i += 3 + nargs
+				if strings.EqualFold(arg(args, i+1), "AS") {
+					red.alias = arg(args, i+2)
+					i += 2
+				} else {
+					red.alias = "__generated_alias" + strings.ToLower(red.fn) + strings.Join(red.args, ",")
+				}
+				reds = append(reds, red)
+			}
+			var err error
+			if rows, err = groupBy(rows, props, reds); err != nil {
+				return errorReply(err.Error())
+			}
+			// Grouped rows no longer carry the documents they came from.
+			docs = nil
+		case "APPLY", "FILTER":
+			e, err := parseExpr(arg(args, i+1))
+			if err != nil {
+				return errorReply(err.Error())
+			}
+			if strings.EqualFold(args[i], "FILTER") {
+				kept := rows[:0]
+				var keptDocs []*doc
+				for k, r := range rows {
+					if truthy(e(r)) {
+						kept = append(kept, r)
+						if docs != nil {
+							keptDocs = append(keptDocs, docs[k])
+						}
+					}
+				}
+				rows = kept
+				if docs != nil {
+					docs = keptDocs
+				}
+				i++
+				continue
+			}
+			if !strings.EqualFold(arg(args, i+2), "AS") {
+				return errorf("Bad arguments for APPLY: missing AS")
+			}
+			name := arg(args, i+3)
+			for _, r := range rows {
+				r.set(name, e(r))
+			}
+			i += 3
+		case "SORTBY":
+			n, ok := atoi(arg(args, i+1))
+			if !ok || i+1+n >= len(args) {
+				return errorf("Bad arguments for SORTBY")
+			}
+			keys := args[i+2 : i+2+n]
+			i += 1 + n
+			limit := 10 // RediSearch keeps 10 rows unless MAX is given
+			if strings.EqualFold(arg(args, i+1), "MAX") {
+				limit, _ = atoi(arg(args, i+2))
+				i += 2
+			}
+			sortRows(rows, docs, keys)
+			if limit < len(rows) {
+				rows = rows[:limit]
+				if docs != nil {
+					docs = docs[:limit]
+				}
+			}
+		case "LIMIT":
+			off, ok1 := atoi(arg(args, i+1))
+			lim, ok2 := atoi(arg(args, i+2))
+			if !ok1 || !ok2 || off < 0 || lim < 0 {
+				return errorf("Bad arguments for LIMIT")
+			}
+			total = len(rows)
+			rows = rows[min(off, len(rows)):min(off+lim, len(rows))]
+			if docs != nil {
+				docs = docs[min(off, len(docs)):min(off+lim, len(docs))]
+			}
+			i += 2
+		default:
+			return errorf("Unknown argument `%s`", args[i])
+		}
+	}
+
+	if total < 0 {
+		total = len(rows)
+	}
+	out := []any{int64(total)}
+	for _, r := range rows {
+		fields := []any{}
+		for _, k := range r.keys {
+			fields = append(fields, k, replyValue(r.vals[k]))
+		}
+		out = append(out, fields)
+	}
+	return out
+}
+
+func replyValue(v any) any {
+	switch t := v.(type) {
+	case []any:
+		out := make([]any, len(t))
+		for i, e := range t {
+			out[i] = replyValue(e)
+		}
+		return out
+	case nil, string, float64:
+		return t
+	}
+	return fmt.Sprint(v)
+}
+
+func groupBy(rows []*row, props []string, reds []reducer) ([]*row, error) {
+	for _, red := range reds {
+		switch red.fn {
+		case "COUNT", "COUNT_DISTINCT", "SUM", "MIN", "MAX", "AVG", "TOLIST":
+		default:
+			return nil, fmt.Errorf("Unknown reducer `%s`", red.fn)
+		}
+	}
+	type group struct {
+		key  []any
+		rows []*row
+	}
+	var order []string
+	groups := map[string]*group{}
+	for _, r := range rows {
+		for _, key := range expandKeys(r, props) {
+			id := fmt.Sprint(key...)
+			g, ok := groups[id]
+			if !ok {
+				g = &group{key: key}
+				groups[id] = g
+				order = append(order, id)
+			}
+			g.rows = append(g.rows, r)
+		}
+	}
+
+	out := make([]*row, 0, len(order))
+	for _, id := range order {
+		g := groups[id]
+		r := &row{vals: map[string]any{}}
+		for k, p := range props {
+			r.set(p, g.key[k])
+		}
+		for _, red := range reds {
+			r.set(red.alias, reduce(red, g.rows))
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// expandKeys returns the group keys of r; array values (e.g. from split)
+// put the row into one group per element.
+func expandKeys(r *row, props []string) [][]any {
+	keys := [][]any{{}}
+	for _, p := range props {
+		vals := []any{r.get(p)}
+		if arr, ok := r.get(p).([]any); ok {
+			vals = arr
+		}
+		var next [][]any
+		for _, k := range keys {
+			for _, v := range vals {
+				next = append(next, append(append([]any{}, k...), v))
+			}
+		}
+		keys = next
+	}
+	return keys
+}
+
+func reduce(red reducer, rows []*row) any {
+	var prop string
+	if len(red.args) > 0 {
+		prop = red.args[0]
+	}
+	switch red.fn {
+	case "COUNT":
+		return float64(len(rows))
+	case "COUNT_DISTINCT":
+		seen := map[string]bool{}
+		for _, r := range rows {
+			if v := r.get(prop); v != nil {
+				seen[toString(v)] = true
+			}
+		}
+		return float64(len(seen))
+	case "TOLIST":
+		seen := map[string]bool{}
+		out := []any{}
+		for _, r := range rows {
+			if v := r.get(prop); v != nil && !seen[toString(v)] {
+				seen[toString(v)] = true
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+	var acc float64
+	n := 0
+	for _, r := range rows {
+		f, ok := toNumber(r.get(prop))
+		if !ok {
+			continue
+		}
+		switch {
+		case n == 0:
+			acc = f
+		case red.fn == "MIN":
+			acc = min(acc, f)
+		case red.fn == "MAX":
+			acc = max(acc, f)
+		default:
+			acc += f
+		}
+		n++
+	}
+	if red.fn == "AVG" && n > 0 {
+		acc /= float64(n)
+	}
+	return acc
+}
+
+// sortRows sorts by "@prop [ASC|DESC] …"; docs, when present, is kept in
+// step with rows.
+func sortRows(rows []*row, docs []*doc, spec []string) {
+	type key struct {
+		prop string
+		desc bool
+	}
+	var keys []key
+	for i := 0; i < len(spec); i++ {
+		k := key{prop: strings.TrimPrefix(spec[i], "@")}
+		if i+1 < len(spec) && (strings.EqualFold(spec[i+1], "ASC") || strings.EqualFold(spec[i+1], "DESC")) {
+			k.desc = strings.EqualFold(spec[i+1], "DESC")
+			i++
+		}
+		keys = append(keys, k)
+	}
+	idx := make([]int, len(rows))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		for _, k := range keys {
+			x, y := rows[idx[a]].get(k.prop), rows[idx[b]].get(k.prop)
+			if x == nil || y == nil {
+				if (x == nil) != (y == nil) {
+					return y == nil
+				}
+				continue
+			}
+			fx, okx := toNumber(x)
+			fy, oky := toNumber(y)
+			c := 0
+			if okx && oky {
+				switch {
+				case fx < fy:
+					c = -1
+				case fx > fy:
+					c = 1
+				}
+			} else {
+				c = strings.Compare(toString(x), toString(y))
+			}
+			if c != 0 {
+				if k.desc {
+					return c > 0
+				}
+				return c < 0
+			}
+		}
+		return false
+	})
+	sortedRows := make([]*row, len(rows))
+	for i, j := range idx {
+		sortedRows[i] = rows[j]
+	}
+	copy(rows, sortedRows)
+	if docs != nil {
+		sortedDocs := make([]*doc, len(docs))
+		for i, j := range idx {
+			sortedDocs[i] = docs[j]
+		}
+		copy(docs, sortedDocs)
+	}
+}
@@ -0,0 +1,103 @@
+package redisfttest
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// now is the server clock; FastForward moves it ahead.
+func (s *Server) now() time.Time { return time.Now().Add(s.skew) }
+
+// FastForward advances the server clock by d, expiring keys whose TTL runs
+// out in the meantime.
+func (s *Server) FastForward(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skew += d
+	s.expireKeys()
+}
+
+// expireKeys deletes every key past its expiry. Must hold s.mu.
+func (s *Server) expireKeys() {
+	now := s.now()
+	for k, at := range s.expires {
+		if !now.Before(at) {
+			s.deleteKey(k)
+			s.notify("expired", k)
+		}
+	}
+}
+
+// deleteKey removes key and its TTL. Must hold s.mu.
+func (s *Server) deleteKey(key string) bool {
+	_, ok := s.hashes[key]
+	if ok {
+		s.touch(key)
+	}
+	delete(s.hashes, key)
+	delete(s.expires, key)
+	return ok
+}
+
+// EXPIRE / PEXPIRE / EXPIREAT / PEXPIREAT key value
+func cmdExpire(s *Server, c *conn, args []string) any {
+	if len(args) != 3 {
+		return wrongArgs(args)
+	}
+	n, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return errorf("ERR value is not an integer or out of range")
+	}
+	if _, ok := s.hashes[args[1]]; !ok {
+		return 0
+	}
+	var at time.Time
+	switch strings.ToUpper(args[0]) {
+	case "EXPIRE":
+		at = s.now().Add(time.Duration(n) * time.Second)
+	case "PEXPIRE":
+		at = s.now().Add(time.Duration(n) * time.Millisecond)
+	case "EXPIREAT":
+		at = time.Unix(n, 0)
+	case "PEXPIREAT":
+		at = time.UnixMilli(n)
+	}
+	if s.expires == nil {
+		s.expires = map[string]time.Time{}
+	}
+	s.expires[args[1]] = at
+	s.expireKeys()
+	return 1
+}
+
+// PERSIST key
+func cmdPersist(s *Server, c *conn, args []string) any {
+	if len(args) != 2 {
+		return wrongArgs(args)
+	}
+	if _, ok := s.expires[args[1]]; !ok {
+		return 0
+	}
+	delete(s.expires, args[1])
+	return 1
+}
+
+// TTL / PTTL key
+func cmdTTL(s *Server, c *conn, args []string) any {
+	if len(args) != 2 {
+		return wrongArgs(args)
+	}
+	if _, ok := s.hashes[args[1]]; !ok {
+		return -2
+	}
+	at, ok := s.expires[args[1]]
+	if !ok {
+		return -1
+	}
+	left := at.Sub(s.now())
+	if strings.EqualFold(args[0], "PTTL") {
+		return left.Milliseconds()
+	}
+	return int64((left + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,340 @@
+package redisfttest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// expr is a compiled APPLY / FILTER expression. Values are float64, string,
+// []any (from split) or nil for missing properties.
+type expr func(r *row) any
+
+var binaryPrec = map[string]int{
+	"||": 1, "&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5, "%": 5,
+	"^": 6,
+}
+
+type exprParser struct {
+	s   string
+	pos int
+}
+
+func parseExpr(s string) (expr, error) {
+	p := &exprParser{s: s}
+	e, err := p.parse(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("Syntax error at offset %d near %s", p.pos, p.s[p.pos:])
+	}
+	return e, nil
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *exprParser) op() string {
+	p.skipSpace()
+	for _, op := range []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "%", "^"} {
+		if strings.HasPrefix(p.s[p.pos:], op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *exprParser) parse(minPrec int) (expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.op()
+		prec, ok := binaryPrec[op]
+		if !ok || prec <= minPrec {
+			return left, nil
+		}
+		p.pos += len(op)
+		right, err := p.parse(prec)
+		if err != nil {
+			return nil, err
+		}
+		left = binary(op, left, right)
+	}
+}
+
+func (p *exprParser) unary() (expr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return nil, fmt.Errorf("Syntax error: unexpected end of expression")
+	}
+	switch c := p.s[p.pos]; {
+	case c == '-':
+		p.pos++
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(r *row) any {
+			if f, ok := toNumber(e(r)); ok {
+				return -f
+			}
+			return nil
+		}, nil
+	case c == '!':
+		p.pos++
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(r *row) any { return boolNum(!truthy(e(r))) }, nil
+	case c == '(':
+		p.pos++
+		e, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.s) || p.s[p.pos] != ')' {
+			return nil, fmt.Errorf("Syntax error at offset %d: expected ')'", p.pos)
+		}
+		p.pos++
+		return e, nil
+	case c == '@':
+		p.pos++
+		name := p.ident()
+		return func(r *row) any { return r.get(name) }, nil
+	case c == '"' || c == '\'':
+		p.pos++
+		end := strings.IndexByte(p.s[p.pos:], c)
+		if end < 0 {
+			return nil, fmt.Errorf("Syntax error: unterminated string")
+		}
+		lit := p.s[p.pos : p.pos+end]
+		p.pos += end + 1
+		return func(*row) any { return lit }, nil
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] >= '0' && p.s[p.pos] <= '9' || p.s[p.pos] == '.' || p.s[p.pos] == 'e') {
+			p.pos++
+		}
+		f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Syntax error at offset %d: bad number", start)
+		}
+		return func(*row) any { return f }, nil
+	}
+	name := p.ident()
+	if name == "" {
+		return nil, fmt.Errorf("Syntax error at offset %d near %s", p.pos, p.s[p.pos:])
+	}
+	p.skipSpace()
+	if p.pos >= len(p.s) || p.s[p.pos] != '(' {
+		return nil, fmt.Errorf("Syntax error: unknown symbol %s", name)
+	}
+	p.pos++
+	var args []expr
+	for {
+		p.skipSpace()
+		if p.pos < len(p.s) && p.s[p.pos] == ')' {
+			p.pos++
+			break
+		}
+		a, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+		p.skipSpace()
+		if p.pos < len(p.s) && p.s[p.pos] == ',' {
+			p.pos++
+		}
+	}
+	return call(strings.ToLower(name), args)
+}
+
+func (p *exprParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.s) && isWordByte(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func binary(op string, a, b expr) expr {
+	return func(r *row) any {
+		x, y := a(r), b(r)
+		switch op {
+		case "||":
+			return boolNum(truthy(x) || truthy(y))
+		case "&&":
+			return boolNum(truthy(x) && truthy(y))
+		case "==", "!=", "<", "<=", ">", ">=":
+			var c int
+			fx, okx := toNumber(x)
+			fy, oky := toNumber(y)
+			if okx && oky {
+				switch {
+				case fx < fy:
+					c = -1
+				case fx > fy:
+					c = 1
+				}
+			} else {
+				c = strings.Compare(fmt.Sprint(x), fmt.Sprint(y))
+			}
+			switch op {
+			case "==":
+				return boolNum(c == 0)
+			case "!=":
+				return boolNum(c != 0)
+			case "<":
+				return boolNum(c < 0)
+			case "<=":
+				return boolNum(c <= 0)
+			case ">":
+				return boolNum(c > 0)
+			}
+			return boolNum(c >= 0)
+		}
+		fx, okx := toNumber(x)
+		fy, oky := toNumber(y)
+		if !okx || !oky {
+			return nil
+		}
+		switch op {
+		case "+":
+			return fx + fy
+		case "-":
+			return fx - fy
+		case "*":
+			return fx * fy
+		case "/":
+			if fy == 0 {
+				return nil
+			}
+			return fx / fy
+		case "%":
+			if int64(fy) == 0 {
+				return nil
+			}
+			return float64(int64(fx) % int64(fy))
+		}
+		return math.Pow(fx, fy)
+	}
+}
+
+func call(name string, args []expr) (expr, error) {
+	math1 := map[string]func(float64) float64{
+		"floor": math.Floor, "ceil": math.Ceil, "abs": math.Abs,
+		"sqrt": math.Sqrt, "log": math.Log, "log2": math.Log2, "exp": math.Exp,
+	}
+	if fn, ok := math1[name]; ok {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s needs 1 argument", name)
+		}
+		return func(r *row) any {
+			if f, ok := toNumber(args[0](r)); ok {
+				return fn(f)
+			}
+			return nil
+		}, nil
+	}
+	switch name {
+	case "lower", "upper", "strlen", "exists":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s needs 1 argument", name)
+		}
+		return func(r *row) any {
+			v := args[0](r)
+			switch name {
+			case "exists":
+				return boolNum(v != nil)
+			case "strlen":
+				return float64(len(toString(v)))
+			case "lower":
+				return strings.ToLower(toString(v))
+			}
+			return strings.ToUpper(toString(v))
+		}, nil
+	case "split":
+		if len(args) < 1 || len(args) > 3 {
+			return nil, fmt.Errorf("split needs 1 to 3 arguments")
+		}
+		return func(r *row) any {
+			v := args[0](r)
+			if v == nil {
+				return nil
+			}
+			sep, strip := ",", " "
+			if len(args) > 1 {
+				sep = toString(args[1](r))
+			}
+			if len(args) > 2 {
+				strip = toString(args[2](r))
+			}
+			var out []any
+			for _, part := range strings.Split(toString(v), sep) {
+				if part = strings.Trim(part, strip); part != "" {
+					out = append(out, part)
+				}
+			}
+			return out
+		}, nil
+	}
+	return nil, fmt.Errorf("Unknown function name '%s'", name)
+}
+
+func toNumber(v any) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toString(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return formatFloat(t)
+	}
+	return fmt.Sprint(v)
+}
+
+func truthy(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case float64:
+		return t != 0
+	case string:
+		return t != ""
+	case []any:
+		return len(t) > 0
+	}
+	return true
+}
+
+func boolNum(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,413 @@
+package redisfttest
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type field struct {
+	name          string // hash field
+	alias         string // name used in queries
+	typ           string // TEXT, NUMERIC, TAG, GEO
+	sortable      bool
+	sep           string
+	caseSensitive bool
+}
+
+type index struct {
+	name     string
+	prefixes []string
+	fields   []*field
+}
+
+func (ix *index) field(name string) *field {
+	for _, f := range ix.fields {
+		if f.alias == name {
+			return f
+		}
+	}
+	for _, f := range ix.fields {
+		if strings.EqualFold(f.alias, name) {
+			return f
+		}
+	}
+	return nil
+}
+
+func (ix *index) covers(key string) bool {
+	if len(ix.prefixes) == 0 {
+		return true
+	}
+	for _, p := range ix.prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// doc is an indexed hash as seen by the query evaluator.
+type doc struct {
+	key  string
+	hash map[string]string
+	ix   *index
+
+	tokens map[string][]string
+}
+
+func (d *doc) value(f *field) (string, bool) {
+	v, ok := d.hash[f.name]
+	return v, ok
+}
+
+func (d *doc) textTokens(f *field) []string {
+	if toks, ok := d.tokens[f.name]; ok {
+		return toks
+	}
+	v, _ := d.value(f)
+	toks := tokenize(v)
+	if d.tokens == nil {
+		d.tokens = map[string][]string{}
+	}
+	d.tokens[f.name] = toks
+	return toks
+}
+
+func (d *doc) tags(f *field) []string {
+	v, ok := d.value(f)
+	if !ok {
+		return nil
+	}
+	var out []string
+	for _, t := range strings.Split(v, f.sep) {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if !f.caseSensitive {
+			t = strings.ToLower(t)
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+func (d *doc) number(f *field) (float64, bool) {
+	v, ok := d.value(f)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	return n, err == nil
+}
+
+func (d *doc) geo(f *field) (lon, lat float64, ok bool) {
+	v, ok := d.value(f)
+	if !ok {
+		return 0, 0, false
+	}
+	parts := strings.Split(v, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	lon, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lat, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	return lon, lat, err1 == nil && err2 == nil
+}
+
+// tokenize lower-cases s and splits it on anything but letters and digits,
+// which is close enough to the RediSearch default tokenizer for tests.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// docs returns the hashes covered by ix in key order.
+func (s *Server) docs(ix *index) []*doc {
+	var out []*doc
+	for _, k := range s.sortedKeys() {
+		if ix.covers(k) {
+			out = append(out, &doc{key: k, hash: s.hashes[k], ix: ix})
+		}
+	}
+	return out
+}
+
+func (s *Server) lookupIndex(name string) (*index, any) {
+	if target, ok := s.aliases[name]; ok {
+		name = target
+	}
+	ix, ok := s.indexes[name]
+	if !ok {
+		return nil, errorf("%s: no such index", name)
+	}
+	return ix, nil
+}
+
+// FT.CREATE idx [ON HASH] [PREFIX n p…] … SCHEMA f [AS alias] TYPE [opts…] …
+func cmdFTCreate(s *Server, c *conn, args []string) any {
+	if len(args) < 4 {
+		return wrongArgs(args)
+	}
+	if _, exists := s.indexes[args[1]]; exists {
+		return errorf("Index already exists")
+	}
+	ix := &index{name: args[1]}
+	i := 2
+scan:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "ON":
+			if i+1 >= len(args) || !strings.EqualFold(args[i+1], "HASH") {
+				return errorf("ERR only ON HASH is supported")
+			}
+			i++
+		case "PREFIX":
+			n, ok := atoi(arg(args, i+1))
+			if !ok || i+1+n >= len(args) {
+				return errorf("Bad arguments for PREFIX")
+			}
+			ix.prefixes = append(ix.prefixes, args[i+2:i+2+n]...)
+			i += 1 + n
+		case "LANGUAGE", "LANGUAGE_FIELD", "SCORE", "SCORE_FIELD", "PAYLOAD_FIELD", "FILTER", "TEMPORARY":
+			i++
+		case "STOPWORDS":
+			n, _ := atoi(arg(args, i+1))
+			i += 1 + n
+		case "SCHEMA":
+			break scan
+		}
+	}
+	if i >= len(args) {
+		return errorf("Fields arguments are missing")
+	}
+	for i++; i < len(args); {
+		f := &field{name: args[i], alias: args[i], sep: ","}
+		i++
+		if strings.EqualFold(arg(args, i), "AS") {
+			f.alias = arg(args, i+1)
+			i += 2
+		}
+		f.typ = strings.ToUpper(arg(args, i))
+		switch f.typ {
+		case "TEXT", "NUMERIC", "TAG", "GEO":
+		default:
+			return errorf("Invalid field type for field `%s`", f.name)
+		}
+		i++
+	opts:
+		for i < len(args) {
+			switch strings.ToUpper(args[i]) {
+			case "SORTABLE":
+				f.sortable = true
+			case "UNF", "NOINDEX", "NOSTEM", "WITHSUFFIXTRIE", "INDEXMISSING", "INDEXEMPTY":
+			case "CASESENSITIVE":
+				f.caseSensitive = true
+			case "SEPARATOR":
+				f.sep = arg(args, i+1)
+				i++
+			case "WEIGHT", "PHONETIC":
+				i++
+			default:
+				break opts
+			}
+			i++
+		}
+		ix.fields = append(ix.fields, f)
+	}
+	if len(ix.fields) == 0 {
+		return errorf("Fields arguments are missing")
+	}
+	s.indexes[ix.name] = ix
+	return status("OK")
+}
+
+func arg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+// FT.DROPINDEX idx [DD]
+func cmdFTDropIndex(s *Server, c *conn, args []string) any {
+	if len(args) < 2 {
+		return wrongArgs(args)
+	}
+	ix, ok := s.indexes[args[1]]
+	if !ok {
+		return errorf("Unknown Index name")
+	}
+	if strings.EqualFold(arg(args, 2), "DD") {
+		for _, d := range s.docs(ix) {
+			s.deleteKey(d.key)
+		}
+	}
+	delete(s.indexes, args[1])
+	for a, target := range s.aliases {
+		if target == args[1] {
+			delete(s.aliases, a)
+		}
+	}
+	return status("OK")
+}
+
+func cmdFTList(s *Server, c *conn, args []string) any {
+	names := make([]string, 0, len(s.indexes))
+	for n := range s.indexes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type searchOpts struct {
+	noContent bool
+	inKeys    map[string]bool
+	inFields  map[string]bool
+	ret       []string
+	sortBy    string
+	sortDesc  bool
+	off, lim  int
+}
+
+// FT.SEARCH idx query [options…]
+func cmdFTSearch(s *Server, c *conn, args []string) any {
+	if len(args) < 3 {
+		return wrongArgs(args)
+	}
+	ix, errR := s.lookupIndex(args[1])
+	if errR != nil {
+		return errR
+	}
+	o := searchOpts{lim: 10}
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NOCONTENT":
+			o.noContent = true
+		case "VERBATIM", "NOSTOPWORDS", "WITHSCORES", "WITHPAYLOADS", "WITHSORTKEYS", "EXPLAINSCORE":
+		case "INKEYS", "INFIELDS", "RETURN":
+			n, ok := atoi(arg(args, i+1))
+			if !ok || n < 0 || i+1+n >= len(args) {
+				return errorf("Bad arguments for %s", strings.ToUpper(args[i]))
+			}
+			list := args[i+2 : i+2+n]
+			switch strings.ToUpper(args[i]) {
+			case "INKEYS":
+				o.inKeys = map[string]bool{}
+				for _, k := range list {
+					o.inKeys[k] = true
+				}
+			case "INFIELDS":
+				o.inFields = map[string]bool{}
+				for _, f := range list {
+					o.inFields[f] = true
+				}
+			default:
+				o.ret = append([]string{}, list...)
+			}
+			i += 1 + n
+		case "SORTBY":
+			o.sortBy = strings.TrimPrefix(arg(args, i+1), "@")
+			i++
+			switch strings.ToUpper(arg(args, i+1)) {
+			case "DESC":
+				o.sortDesc = true
+				i++
+			case "ASC":
+				i++
+			}
+		case "LIMIT":
+			off, ok1 := atoi(arg(args, i+1))
+			lim, ok2 := atoi(arg(args, i+2))
+			if !ok1 || !ok2 || off < 0 || lim < 0 {
+				return errorf("Bad arguments for LIMIT")
+			}
+			o.off, o.lim = off, lim
+			i += 2
+		case "DIALECT", "TIMEOUT", "SLOP", "LANGUAGE", "SCORER", "EXPANDER":
+			i++
+		case "PARAMS":
+			n, _ := atoi(arg(args, i+1))
+			i += 1 + n
+		default:
+			return errorf("Unknown argument `%s`", args[i])
+		}
+	}
+
+	matched, errR := s.match(ix, args[2], o.inKeys, o.inFields)
+	if errR != nil {
+		return errR
+	}
+	if o.sortBy != "" {
+		f := ix.field(o.sortBy)
+		if f == nil {
+			return errorf("Property `%s` not loaded nor in schema", o.sortBy)
+		}
+		sortDocs(matched, f, o.sortDesc)
+	}
+
+	out := []any{int64(len(matched))}
+	for i := o.off; i < len(matched) && i < o.off+o.lim; i++ {
+		d := matched[i]
+		out = append(out, d.key)
+		if !o.noContent {
+			out = append(out, flatHash(d.hash, o.ret))
+		}
+	}
+	return out
+}
+
+// match evaluates query against every document of ix.
+func (s *Server) match(ix *index, query string, inKeys, inFields map[string]bool) ([]*doc, any) {
+	n, err := parseQuery(query, ix)
+	if err != nil {
+		return nil, errorReply(err.Error())
+	}
+	var out []*doc
+	for _, d := range s.docs(ix) {
+		if inKeys != nil && !inKeys[d.key] {
+			continue
+		}
+		if n.match(&evalCtx{doc: d, inFields: inFields}) {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+// sortDocs orders docs by f; documents without a value sort last.
+func sortDocs(docs []*doc, f *field, desc bool) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		a, aok := docs[i].value(f)
+		b, bok := docs[j].value(f)
+		if !aok || !bok {
+			return aok && !bok
+		}
+		c := compareValues(a, b, f.typ == "NUMERIC")
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+func compareValues(a, b string, numeric bool) int {
+	if numeric {
+		x, err1 := strconv.ParseFloat(a, 64)
+		y, err2 := strconv.ParseFloat(b, 64)
+		if err1 == nil && err2 == nil {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
@@ -0,0 +1,110 @@
+package redisfttest
+
+import "strings"
+
+// CONFIG GET parameter | CONFIG SET parameter value
+//
+// Only notify-keyspace-events is understood; other parameters are stored
+// and returned verbatim.
+func cmdConfig(s *Server, c *conn, args []string) any {
+	if s.noConfig {
+		return errorf("ERR unknown command 'CONFIG'")
+	}
+	if len(args) < 3 {
+		return wrongArgs(args)
+	}
+	param := strings.ToLower(args[2])
+	switch strings.ToUpper(args[1]) {
+	case "GET":
+		return []string{param, s.config[param]}
+	case "SET":
+		if len(args) != 4 {
+			return wrongArgs(args)
+		}
+		if param == "notify-keyspace-events" && strings.Trim(args[3], "KEg$lshzxetmnA") != "" {
+			return errorf("ERR Invalid argument '%s' for CONFIG SET 'notify-keyspace-events'", args[3])
+		}
+		if s.config == nil {
+			s.config = map[string]string{}
+		}
+		s.config[param] = args[3]
+		return status("OK")
+	}
+	return errorf("ERR unknown subcommand '%s'", args[1])
+}
+
+// DisableConfig makes CONFIG fail as on managed services that rename or
+// block it.
+func (s *Server) DisableConfig() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noConfig = true
+}
+
+// notifyClass maps keyspace events to their notify-keyspace-events class.
+var notifyClass = map[string]byte{
+	"hset": 'h', "hdel": 'h', "hincrby": 'h',
+	"del": 'g', "expire": 'g', "persist": 'g',
+	"expired": 'x',
+	"new":     'n',
+}
+
+// notify publishes a keyspace notification for key when enabled. Must hold
+// s.mu.
+func (s *Server) notify(event, key string) {
+	flags := s.config["notify-keyspace-events"]
+	class := notifyClass[event]
+	if !strings.ContainsRune(flags, 'K') {
+		return
+	}
+	if !strings.ContainsRune(flags, rune(class)) && (class == 'n' || !strings.ContainsRune(flags, 'A')) {
+		return
+	}
+	s.publish("__keyspace@0__:"+key, event)
+}
+
+// notifyWrite publishes the notifications of a successful write command
+// to key; existed tells whether the key was present before.
+func (s *Server) notifyWrite(name, key string, existed bool, reply any) {
+	_, exists := s.hashes[key]
+	switch name {
+	case "HSET", "HMSET", "HINCRBY":
+		if !existed {
+			s.notify("new", key)
+		}
+		if name == "HINCRBY" {
+			s.notify("hincrby", key)
+		} else {
+			s.notify("hset", key)
+		}
+	case "HDEL":
+		if reply != 0 {
+			s.notify("hdel", key)
+			if !exists {
+				s.notify("del", key)
+			}
+		}
+	case "DEL", "UNLINK":
+		if existed {
+			s.notify("del", key)
+		}
+	case "PERSIST":
+		if reply == 1 {
+			s.notify("persist", key)
+		}
+	default: // EXPIRE and friends
+		if reply == 1 {
+			s.notify("expire", key)
+		}
+	}
+}
+
+// KillConnections closes every client connection, as a server restart or
+// network failure would. Clients reconnect on their next command.
+func (s *Server) KillConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.nc.Close()
+	}
+}
@@ -0,0 +1,144 @@
+package redisfttest
+
+import (
+	"bytes"
+	"strings"
+)
+
+// replies is written as several consecutive RESP replies, as SUBSCRIBE does
+// for each channel it joins.
+type replies []any
+
+// SUBSCRIBE channel [channel ...]
+func cmdSubscribe(s *Server, c *conn, args []string) any {
+	if len(args) < 2 {
+		return wrongArgs(args)
+	}
+	if s.subs == nil {
+		s.subs = map[string]map[*conn]bool{}
+	}
+	var out replies
+	for _, ch := range args[1:] {
+		if s.subs[ch] == nil {
+			s.subs[ch] = map[*conn]bool{}
+		}
+		s.subs[ch][c] = true
+		c.channels[ch] = true
+		out = append(out, []any{"subscribe", ch, c.subscriptions()})
+	}
+	return out
+}
+
+// UNSUBSCRIBE [channel ...]
+func cmdUnsubscribe(s *Server, c *conn, args []string) any {
+	chans := args[1:]
+	if len(chans) == 0 {
+		for ch := range c.channels {
+			chans = append(chans, ch)
+		}
+	}
+	var out replies
+	for _, ch := range chans {
+		delete(s.subs[ch], c)
+		delete(c.channels, ch)
+		out = append(out, []any{"unsubscribe", ch, c.subscriptions()})
+	}
+	if len(out) == 0 {
+		return []any{"unsubscribe", nil, 0}
+	}
+	return out
+}
+
+// PSUBSCRIBE pattern [pattern ...]
+func cmdPSubscribe(s *Server, c *conn, args []string) any {
+	if len(args) < 2 {
+		return wrongArgs(args)
+	}
+	if s.psubs == nil {
+		s.psubs = map[string]map[*conn]bool{}
+	}
+	var out replies
+	for _, p := range args[1:] {
+		if s.psubs[p] == nil {
+			s.psubs[p] = map[*conn]bool{}
+		}
+		s.psubs[p][c] = true
+		c.patterns[p] = true
+		out = append(out, []any{"psubscribe", p, c.subscriptions()})
+	}
+	return out
+}
+
+// PUNSUBSCRIBE [pattern ...]
+func cmdPUnsubscribe(s *Server, c *conn, args []string) any {
+	pats := args[1:]
+	if len(pats) == 0 {
+		for p := range c.patterns {
+			pats = append(pats, p)
+		}
+	}
+	var out replies
+	for _, p := range pats {
+		delete(s.psubs[p], c)
+		delete(c.patterns, p)
+		out = append(out, []any{"punsubscribe", p, c.subscriptions()})
+	}
+	if len(out) == 0 {
+		return []any{"punsubscribe", nil, 0}
+	}
+	return out
+}
+
+// PUBLISH channel message
+func cmdPublish(s *Server, c *conn, args []string) any {
+	if len(args) != 3 {
+		return wrongArgs(args)
+	}
+	return s.publish(args[1], args[2])
+}
+
+// publish delivers msg to the subscribers of channel and of matching
+// patterns, and returns how many received it. Must hold s.mu.
+func (s *Server) publish(channel, msg string) int {
+	var buf bytes.Buffer
+	writeReply(&buf, []any{"message", channel, msg})
+	for sub := range s.subs[channel] {
+		sub.send(buf.Bytes())
+	}
+	n := len(s.subs[channel])
+	for p, subs := range s.psubs {
+		if !globMatch(p, channel) {
+			continue
+		}
+		buf.Reset()
+		writeReply(&buf, []any{"pmessage", p, channel, msg})
+		for sub := range subs {
+			sub.send(buf.Bytes())
+			n++
+		}
+	}
+	return n
+}
+
+func (c *conn) subscriptions() int { return len(c.channels) + len(c.patterns) }
+
+// dropSubscriptions forgets c once its connection is gone.
+func (s *Server) dropSubscriptions(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range c.channels {
+		delete(s.subs[ch], c)
+	}
+	for p := range c.patterns {
+		delete(s.psubs[p], c)
+	}
+	delete(s.conns, c)
+}
+
+func isPubSubCommand(name string) bool {
+	switch strings.ToUpper(name) {
+	case "SUBSCRIBE", "UNSUBSCRIBE", "PSUBSCRIBE", "PUNSUBSCRIBE", "PING", "QUIT":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,606 @@
+package redisfttest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// evalCtx is the per-document state of a query evaluation.
+type evalCtx struct {
+	doc      *doc
+	inFields map[string]bool
+}
+
+type node interface {
+	match(c *evalCtx) bool
+}
+
+type (
+	matchAll struct{}
+	andNode  []node
+	orNode   []node
+	notNode  struct{ n node }
+	textNode struct {
+		field *field // nil: every TEXT field (or INFIELDS)
+		kind  termKind
+		term  string
+		words []string // phrase
+		dist  int      // fuzzy
+	}
+	tagNode struct {
+		field  *field
+		values []tagValue
+	}
+	numericNode struct {
+		field        *field
+		lo, hi       float64
+		loExc, hiExc bool
+	}
+	geoNode struct {
+		field            *field
+		lon, lat, radius float64 // radius in meters
+	}
+)
+
+type termKind int
+
+const (
+	termExact termKind = iota
+	termPrefix
+	termSuffix
+	termInfix
+	termFuzzy
+	termPhrase
+)
+
+type tagValue struct {
+	val    string
+	prefix bool
+}
+
+func (matchAll) match(*evalCtx) bool { return true }
+
+func (n andNode) match(c *evalCtx) bool {
+	for _, k := range n {
+		if !k.match(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n orNode) match(c *evalCtx) bool {
+	for _, k := range n {
+		if k.match(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n notNode) match(c *evalCtx) bool { return !n.n.match(c) }
+
+func (n *textNode) match(c *evalCtx) bool {
+	fields := []*field{n.field}
+	if n.field == nil {
+		fields = nil
+		for _, f := range c.doc.ix.fields {
+			if f.typ == "TEXT" && (c.inFields == nil || c.inFields[f.alias]) {
+				fields = append(fields, f)
+			}
+		}
+	}
+	for _, f := range fields {
+		if f.typ == "TAG" {
+			if n.matchTokens(c.doc.tags(f)) {
+				return true
+			}
+			continue
+		}
+		if n.matchTokens(c.doc.textTokens(f)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *textNode) matchTokens(toks []string) bool {
+	if n.kind == termPhrase {
+		if len(n.words) == 0 {
+			return false
+		}
+	outer:
+		for i := 0; i+len(n.words) <= len(toks); i++ {
+			for j, w := range n.words {
+				if toks[i+j] != w {
+					continue outer
+				}
+			}
+			return true
+		}
+		return false
+	}
+	for _, t := range toks {
+		var ok bool
+		switch n.kind {
+		case termExact:
+			ok = t == n.term
+		case termPrefix:
+			ok = strings.HasPrefix(t, n.term)
+		case termSuffix:
+			ok = strings.HasSuffix(t, n.term)
+		case termInfix:
+			ok = strings.Contains(t, n.term)
+		case termFuzzy:
+			ok = levenshtein(t, n.term) <= n.dist
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *tagNode) match(c *evalCtx) bool {
+	tags := c.doc.tags(n.field)
+	for _, want := range n.values {
+		v := want.val
+		if !n.field.caseSensitive {
+			v = strings.ToLower(v)
+		}
+		for _, t := range tags {
+			if t == v || (want.prefix && strings.HasPrefix(t, v)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (n *numericNode) match(c *evalCtx) bool {
+	v, ok := c.doc.number(n.field)
+	if !ok {
+		return false
+	}
+	if v < n.lo || (n.loExc && v == n.lo) {
+		return false
+	}
+	if v > n.hi || (n.hiExc && v == n.hi) {
+		return false
+	}
+	return true
+}
+
+func (n *geoNode) match(c *evalCtx) bool {
+	lon, lat, ok := c.doc.geo(n.field)
+	if !ok {
+		return false
+	}
+	return haversine(n.lon, n.lat, lon, lat) <= n.radius
+}
+
+// haversine returns the distance in meters between two lon/lat points.
+func haversine(lon1, lat1, lon2, lat2 float64) float64 {
+	const earthRadius = 6372797.560856 // same constant as Redis GEO
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// parseQuery parses the RediSearch (dialect 1) query syntax used by the
+// redisft builders. As in RediSearch, '|' binds tighter than the implicit
+// AND between space-separated terms.
+func parseQuery(q string, ix *index) (node, error) {
+	p := &qparser{s: q, ix: ix}
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.errorf("empty query")
+	}
+	n, err := p.parseIntersect(nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.eof() {
+		return nil, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	return n, nil
+}
+
+type qparser struct {
+	s   string
+	pos int
+	ix  *index
+}
+
+func (p *qparser) errorf(format string, args ...any) error {
+	near := p.s[min(p.pos, len(p.s)):]
+	if len(near) > 10 {
+		near = near[:10]
+	}
+	return fmt.Errorf("Syntax error at offset %d near %s: %s", p.pos, near, fmt.Sprintf(format, args...))
+}
+
+func (p *qparser) eof() bool  { return p.pos >= len(p.s) }
+func (p *qparser) peek() byte { return p.s[p.pos] }
+
+func (p *qparser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\n') {
+		p.pos++
+	}
+}
+
+func (p *qparser) expect(c byte) error {
+	p.skipSpace()
+	if p.eof() || p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *qparser) parseIntersect(scope *field, stop byte) (node, error) {
+	var kids andNode
+	for {
+		p.skipSpace()
+		if p.eof() || (stop != 0 && p.peek() == stop) {
+			break
+		}
+		n, err := p.parseUnion(scope)
+		if err != nil {
+			return nil, err
+		}
+		kids = append(kids, n)
+	}
+	switch len(kids) {
+	case 0:
+		return nil, p.errorf("empty expression")
+	case 1:
+		return kids[0], nil
+	}
+	return kids, nil
+}
+
+func (p *qparser) parseUnion(scope *field) (node, error) {
+	n, err := p.parseUnary(scope)
+	if err != nil {
+		return nil, err
+	}
+	or := orNode{n}
+	for {
+		save := p.pos
+		p.skipSpace()
+		if p.eof() || p.peek() != '|' {
+			p.pos = save
+			break
+		}
+		p.pos++
+		p.skipSpace()
+		m, err := p.parseUnary(scope)
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, m)
+	}
+	if len(or) == 1 {
+		return n, nil
+	}
+	return or, nil
+}
+
+func (p *qparser) parseUnary(scope *field) (node, error) {
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.errorf("unexpected end of query")
+	}
+	switch c := p.peek(); c {
+	case '-':
+		p.pos++
+		n, err := p.parseUnary(scope)
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	case '+':
+		p.pos++
+		return p.parseUnary(scope)
+	case '~':
+		p.pos++
+		if _, err := p.parseUnary(scope); err != nil {
+			return nil, err
+		}
+		return matchAll{}, nil
+	case '(':
+		p.pos++
+		n, err := p.parseIntersect(scope, ')')
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(')')
+	case '@':
+		return p.parseField()
+	case '"':
+		return p.parsePhrase(scope)
+	case '*':
+		if p.pos+1 >= len(p.s) || strings.IndexByte(" \t)|", p.s[p.pos+1]) >= 0 {
+			p.pos++
+			return matchAll{}, nil
+		}
+	}
+	return p.parseTerm(scope)
+}
+
+func (p *qparser) parseField() (node, error) {
+	p.pos++ // '@'
+	start := p.pos
+	for !p.eof() && (isWordByte(p.peek()) || p.peek() == '\\') {
+		if p.peek() == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	name := strings.ReplaceAll(p.s[start:p.pos], `\`, "")
+	if name == "" {
+		return nil, p.errorf("missing field name")
+	}
+	if err := p.expect(':'); err != nil {
+		return nil, err
+	}
+	f := p.ix.field(name)
+	if f == nil {
+		return nil, fmt.Errorf("Unknown field at offset %d near %s", start, name)
+	}
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.errorf("missing value for @%s", name)
+	}
+	switch f.typ {
+	case "TAG":
+		if p.peek() != '{' {
+			return nil, p.errorf("expected '{' after @%s:", name)
+		}
+		return p.parseTags(f)
+	case "NUMERIC":
+		if p.peek() != '[' && p.peek() != '(' {
+			return nil, p.errorf("expected numeric range after @%s:", name)
+		}
+		return p.parseNumeric(f)
+	case "GEO":
+		if p.peek() != '[' {
+			return nil, p.errorf("expected geo filter after @%s:", name)
+		}
+		return p.parseGeo(f)
+	}
+	if p.peek() == '(' {
+		p.pos++
+		n, err := p.parseIntersect(f, ')')
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(')')
+	}
+	return p.parseUnary(f)
+}
+
+func (p *qparser) parseTags(f *field) (node, error) {
+	p.pos++ // '{'
+	n := &tagNode{field: f}
+	var cur strings.Builder
+	flush := func(prefix bool) {
+		v := strings.TrimSpace(cur.String())
+		v = strings.TrimPrefix(v, "+")
+		if v != "" {
+			n.values = append(n.values, tagValue{val: v, prefix: prefix})
+		}
+		cur.Reset()
+	}
+	for {
+		if p.eof() {
+			return nil, p.errorf("unterminated tag list")
+		}
+		c := p.peek()
+		p.pos++
+		switch c {
+		case '\\':
+			if !p.eof() {
+				cur.WriteByte(p.peek())
+				p.pos++
+			}
+		case '|':
+			flush(false)
+		case '*':
+			if !p.eof() && (p.peek() == '|' || p.peek() == '}') {
+				flush(true)
+				if p.peek() == '|' {
+					p.pos++
+				}
+				continue
+			}
+			cur.WriteByte(c)
+		case '}':
+			flush(false)
+			if len(n.values) == 0 {
+				return nil, p.errorf("empty tag list")
+			}
+			return n, nil
+		default:
+			cur.WriteByte(c)
+		}
+	}
+}
+
+// rangeTokens reads the space-separated tokens up to the closing ']' (or ')'
+// for numeric ranges written with a leading '(').
+func (p *qparser) rangeTokens() (toks []string, closer byte, err error) {
+	p.pos++
+	start := p.pos
+	for !p.eof() && p.peek() != ']' && p.peek() != ')' {
+		p.pos++
+	}
+	if p.eof() {
+		return nil, 0, p.errorf("unterminated range")
+	}
+	closer = p.peek()
+	toks = strings.FieldsFunc(p.s[start:p.pos], func(r rune) bool { return r == ' ' || r == ',' })
+	p.pos++
+	return toks, closer, nil
+}
+
+func (p *qparser) parseNumeric(f *field) (node, error) {
+	open := p.peek()
+	toks, closer, err := p.rangeTokens()
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) != 2 {
+		return nil, p.errorf("numeric range needs two values")
+	}
+	n := &numericNode{field: f, loExc: open == '(', hiExc: closer == ')'}
+	var ok bool
+	if n.lo, ok = parseBound(toks[0], &n.loExc); !ok {
+		return nil, p.errorf("bad numeric value %q", toks[0])
+	}
+	if n.hi, ok = parseBound(toks[1], &n.hiExc); !ok {
+		return nil, p.errorf("bad numeric value %q", toks[1])
+	}
+	return n, nil
+}
+
+func parseBound(s string, exclusive *bool) (float64, bool) {
+	if strings.HasPrefix(s, "(") {
+		*exclusive = true
+		s = s[1:]
+	}
+	switch strings.ToLower(s) {
+	case "inf", "+inf":
+		return math.Inf(1), true
+	case "-inf":
+		return math.Inf(-1), true
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
+
+func (p *qparser) parseGeo(f *field) (node, error) {
+	toks, _, err := p.rangeTokens()
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) != 4 {
+		return nil, p.errorf("geo filter needs lon lat radius unit")
+	}
+	var vals [3]float64
+	for i := range vals {
+		v, err := strconv.ParseFloat(toks[i], 64)
+		if err != nil {
+			return nil, p.errorf("bad geo value %q", toks[i])
+		}
+		vals[i] = v
+	}
+	scale := map[string]float64{"m": 1, "km": 1000, "mi": 1609.34, "ft": 0.3048}[strings.ToLower(toks[3])]
+	if scale == 0 {
+		return nil, p.errorf("bad geo unit %q", toks[3])
+	}
+	return &geoNode{field: f, lon: vals[0], lat: vals[1], radius: vals[2] * scale}, nil
+}
+
+func (p *qparser) parsePhrase(scope *field) (node, error) {
+	p.pos++ // '"'
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return nil, p.errorf("unterminated phrase")
+		}
+		c := p.peek()
+		p.pos++
+		if c == '\\' && !p.eof() {
+			sb.WriteByte(p.peek())
+			p.pos++
+			continue
+		}
+		if c == '"' {
+			break
+		}
+		sb.WriteByte(c)
+	}
+	return &textNode{field: scope, kind: termPhrase, words: tokenize(sb.String())}, nil
+}
+
+func (p *qparser) parseTerm(scope *field) (node, error) {
+	var sb strings.Builder
+	lead, trail := 0, 0 // unescaped '*' / '%' at the edges
+	var edge byte
+	for !p.eof() {
+		c := p.peek()
+		if c == '\\' && p.pos+1 < len(p.s) {
+			sb.WriteByte(p.s[p.pos+1])
+			p.pos += 2
+			trail = 0
+			continue
+		}
+		if strings.IndexByte(" \t\n()|{}[]@\"~:", c) >= 0 {
+			break
+		}
+		if c == '*' || c == '%' {
+			if sb.Len() == 0 {
+				lead++
+				edge = c
+			} else {
+				trail++
+			}
+			p.pos++
+			continue
+		}
+		if trail > 0 {
+			return nil, p.errorf("unexpected %q inside term", edge)
+		}
+		sb.WriteByte(c)
+		p.pos++
+	}
+	term := strings.ToLower(sb.String())
+	if term == "" {
+		return nil, p.errorf("expected term")
+	}
+	n := &textNode{field: scope, term: term}
+	switch {
+	case edge == '%' || (lead == 0 && trail > 0 && p.s[p.pos-1] == '%'):
+		n.kind, n.dist = termFuzzy, max(lead, trail)
+	case lead > 0 && trail > 0:
+		n.kind = termInfix
+	case lead > 0:
+		n.kind = termSuffix
+	case trail > 0:
+		n.kind = termPrefix
+	}
+	return n, nil
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
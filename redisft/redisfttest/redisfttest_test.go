@@ -0,0 +1,1019 @@
+package redisfttest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bariscan97/redis-ftsearch/redisft"
+	"github.com/bariscan97/redis-ftsearch/redisft/redisfttest"
+)
+
+type Product struct {
+	ID        string    `redis:"text sortable"`
+	Name      string    `redis:"text"`
+	Price     float64   `redis:"numeric sortable"`
+	CreatedAt time.Time `redis:"numeric"`
+	Location  string    `redis:"geo"`
+	Color     string    `redis:"tag"`
+}
+
+func newRepo(t *testing.T) (*redisft.Repository[Product], *redisfttest.Server) {
+	t.Helper()
+	srv := redisfttest.NewServer()
+	cli := srv.Client()
+	t.Cleanup(func() { cli.Close() })
+
+	ctx := context.Background()
+	repo := redisft.NewRepo[Product](cli)
+	if err := repo.CreateIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	err := repo.InsertMany(ctx, map[string]*Product{
+		"1": {ID: "1", Name: "Warcraft strategy guide", Price: 19.9, Location: "29.0,41.0", Color: "red"},
+		"2": {ID: "2", Name: "Go programming", Price: 45, Location: "29.1,41.0", Color: "blue"},
+		"3": {ID: "3", Name: "Redis in action", Price: 60, Location: "-122.4,37.7", Color: "red,green"},
+		"4": {ID: "4", Name: "Starcraft tutorial", Price: 120, Location: "28.9,41.1", Color: "green"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo, srv
+}
+
+func ids(ps []Product) []string {
+	out := make([]string, len(ps))
+	for i, p := range ps {
+		out[i] = p.ID
+	}
+	return out
+}
+
+func TestRepositorySearch(t *testing.T) {
+	repo, _ := newRepo(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		builders []redisft.Builder
+		want     []string
+	}{
+		{"match all", nil, []string{"1", "2", "3", "4"}},
+		{"term", []redisft.Builder{redisft.NewTextQuery("name").Term("redis")}, []string{"3"}},
+		{"prefix or suffix", []redisft.Builder{redisft.NewTextQuery("name").
+			Group(func(q *redisft.QB) { q.Prefix("war").Or().Suffix("craft") })}, []string{"1", "4"}},
+		{"not", []redisft.Builder{redisft.NewTextQuery("name").Suffix("craft").And().Not().Term("guide")}, []string{"4"}},
+		{"exact", []redisft.Builder{redisft.NewTextQuery("name").Exact("redis in")}, []string{"3"}},
+		{"fuzzy", []redisft.Builder{redisft.NewTextQuery("name").Fuzzy("redix", 1)}, []string{"3"}},
+		{"numeric", []redisft.Builder{redisft.NewNumericQuery("price").Between(40, 100)}, []string{"2", "3"}},
+		{"numeric exclusive", []redisft.Builder{redisft.NewNumericQuery("price").Gt(60)}, []string{"4"}},
+		{"numeric union", []redisft.Builder{redisft.NewNumericQuery("price").Lt(20).OrRange(100, 200, true, true)}, []string{"1", "4"}},
+		{"tag", []redisft.Builder{redisft.NewTagQB("color").Any("green")}, []string{"3", "4"}},
+		{"tag not", []redisft.Builder{redisft.NewTagQB("color").Any("red").And().Not().Any("green")}, []string{"1"}},
+		{"geo", []redisft.Builder{redisft.NewGeoQuery("location").Center(29.0, 41.0).Km(20)}, []string{"1", "2", "4"}},
+		{"combined", []redisft.Builder{
+			redisft.NewGeoQuery("location").Center(29.0, 41.0).Km(20),
+			redisft.NewTagQB("color").Any("red", "blue"),
+		}, []string{"1", "2"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := repo.Search(tc.builders...).Exec(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ids(got), tc.want) {
+				t.Errorf("got %v, want %v", ids(got), tc.want)
+			}
+		})
+	}
+}
+
+func TestRepositorySortLimitProjection(t *testing.T) {
+	repo, _ := newRepo(t)
+	ctx := context.Background()
+
+	got, err := repo.Search().SortBy("price", false).Limit(1, 2).Exec(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids(got), []string{"3", "2"}) {
+		t.Errorf("sorted page = %v", ids(got))
+	}
+
+	got, err = repo.Search().Select("id").SortBy("price", true).Limit(0, 1).Exec(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != "1" || got[0].Name != "" {
+		t.Errorf("projected = %+v", got)
+	}
+
+	keys, err := repo.Search(redisft.NewTagQB("color").Any("red")).InKeys("3", "4").ExecIDs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, []string{"3"}) {
+		t.Errorf("in keys = %v", keys)
+	}
+}
+
+func TestRepositoryPage(t *testing.T) {
+	repo, _ := newRepo(t)
+	ctx := context.Background()
+
+	var seen []string
+	tok := ""
+	for i := 0; i < 5; i++ {
+		page, err := repo.Search().SortBy("price", true).Page(ctx, tok, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen = append(seen, ids(page.Items)...)
+		if tok = page.NextToken; tok == "" {
+			break
+		}
+	}
+	if !reflect.DeepEqual(seen, []string{"1", "2", "3", "4"}) {
+		t.Errorf("paged = %v", seen)
+	}
+}
+
+func TestAggregateAndDrop(t *testing.T) {
+	repo, srv := newRepo(t)
+	ctx := context.Background()
+	rc := srv.Dial()
+	defer rc.Close()
+
+	raw, err := rc.Do(ctx, "FT.AGGREGATE", "idx:product", "*",
+		"APPLY", `split(@color, ",")`, "AS", "c",
+		"GROUPBY", 1, "@c", "REDUCE", "COUNT", 0, "AS", "n",
+		"SORTBY", 4, "@n", "DESC", "@c", "ASC").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{int64(3),
+		[]interface{}{"c", "green", "n", "2"},
+		[]interface{}{"c", "red", "n", "2"},
+		[]interface{}{"c", "blue", "n", "1"},
+	}
+	if !reflect.DeepEqual(raw, want) {
+		t.Errorf("aggregate = %v", raw)
+	}
+
+	if err := repo.CreateIndex(ctx); err != nil {
+		t.Errorf("second CreateIndex: %v", err)
+	}
+	if err := repo.DropIndex(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	if keys := srv.Keys(); len(keys) != 0 {
+		t.Errorf("keys after DD = %v", keys)
+	}
+	if err := repo.DropIndex(ctx, false); err != nil {
+		t.Errorf("dropping a missing index: %v", err)
+	}
+	if _, err := repo.Search().Exec(ctx); !errors.Is(err, redisft.ErrIndexNotFound) {
+		t.Errorf("search on dropped index err = %v, want ErrIndexNotFound", err)
+	}
+}
+
+func TestRepositoryErrors(t *testing.T) {
+	repo, _ := newRepo(t)
+	ctx := context.Background()
+
+	p, err := repo.Get(ctx, "2")
+	if err != nil || p.Name != "Go programming" || p.Price != 45 {
+		t.Errorf("Get(2) = %+v, %v", p, err)
+	}
+	if _, err := repo.Get(ctx, "missing"); !errors.Is(err, redisft.ErrNotFound) {
+		t.Errorf("Get(missing) err = %v, want ErrNotFound", err)
+	}
+
+	var se *redisft.QuerySyntaxError
+	_, err = repo.Search(redisft.NewTextQuery("name").Term("x")).Query(badBuilder{}).Exec(ctx)
+	if !errors.As(err, &se) || se.Offset < 0 {
+		t.Errorf("bad query err = %v, want *QuerySyntaxError", err)
+	}
+}
+
+type badBuilder struct{}
+
+func (badBuilder) GetFieldName() string { return "bad" }
+func (badBuilder) Build() string        { return "@name:(unclosed" }
+
+type recordHook struct{ infos []redisft.CommandInfo }
+
+func (h *recordHook) BeforeCommand(ctx context.Context, _ *redisft.CommandInfo) context.Context {
+	return ctx
+}
+
+func (h *recordHook) AfterCommand(_ context.Context, info *redisft.CommandInfo) {
+	h.infos = append(h.infos, *info)
+}
+
+func TestHooks(t *testing.T) {
+	repo, srv := newRepo(t)
+	ctx := context.Background()
+	cli := srv.Client()
+	defer cli.Close()
+	h := &recordHook{}
+	cli.AddHook(h)
+	repo = redisft.NewRepo[Product](cli)
+
+	if _, err := repo.Search(redisft.NewTagQB("color").Any("red")).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get(ctx, "missing"); !errors.Is(err, redisft.ErrNotFound) {
+		t.Fatalf("Get: %v", err)
+	}
+	repo.Search(badBuilder{}).Exec(ctx)
+
+	if len(h.infos) != 3 {
+		t.Fatalf("got %d commands, want 3", len(h.infos))
+	}
+	s := h.infos[0]
+	if s.Op != redisft.OpSearch || s.Index != "idx:product" || s.Query != "@color:{red}" || s.Hits != 2 || s.Err != nil {
+		t.Errorf("search info = %+v", s)
+	}
+	if g := h.infos[1]; g.Op != redisft.OpGet || g.Hits != -1 || g.Err != nil {
+		t.Errorf("get info = %+v", g)
+	}
+	var se *redisft.QuerySyntaxError
+	if e := h.infos[2]; !errors.As(e.Err, &se) {
+		t.Errorf("failed search err = %v", e.Err)
+	}
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	_, srv := newRepo(t)
+	ctx := context.Background()
+	cli, err := redisft.NewClientWithOptions("",
+		redisft.WithRedisClient(srv.Dial()),
+		redisft.WithRetry(redisft.RetryPolicy{MaxAttempts: 3}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	repo := redisft.NewRepo[Product](cli)
+
+	srv.FailNext(2, "LOADING Redis is loading the dataset in memory")
+	got, err := repo.Search().SortBy("price", true).Exec(ctx)
+	if err != nil || len(got) != 4 {
+		t.Fatalf("Exec after transient errors: %v, %d hits", err, len(got))
+	}
+	if st := cli.ResilienceStats(); st.Retries != 2 {
+		t.Errorf("retries = %d, want 2", st.Retries)
+	}
+
+	srv.FailNext(1, "LOADING Redis is loading the dataset in memory")
+	if err := repo.Delete(ctx, "1"); err == nil {
+		t.Error("write was retried without RetryWrites")
+	}
+}
+
+func TestResultCache(t *testing.T) {
+	repo, srv := newRepo(t)
+	ctx := context.Background()
+	cache, err := repo.EnableCache(redisft.CacheOptions{TTL: time.Minute, Broadcast: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	count := func() int {
+		t.Helper()
+		got, err := repo.Search().Exec(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(got)
+	}
+	count()
+	if n := count(); n != 4 || cache.Stats().Hits != 1 {
+		t.Fatalf("cached search: %d docs, stats %+v", n, cache.Stats())
+	}
+
+	// A local write invalidates immediately.
+	if err := repo.Insert(ctx, "5", &Product{ID: "5", Name: "Cache"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 5 {
+		t.Fatalf("after local insert: %d docs", n)
+	}
+
+	// A write from another process arrives through pub/sub.
+	other := srv.Client()
+	defer other.Close()
+	otherRepo := redisft.NewRepo[Product](other)
+	otherCache, err := otherRepo.EnableCache(redisft.CacheOptions{TTL: time.Minute, Broadcast: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherCache.Close()
+	if err := otherRepo.Delete(ctx, "5"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for count() != 4 {
+		if time.Now().After(deadline) {
+			t.Fatal("remote invalidation not received")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if st := cache.Stats(); st.Invalidations < 2 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+type Session struct {
+	User      string    `redis:"tag"`
+	ExpiresAt time.Time `redis:"numeric expire"`
+}
+
+func TestExpiry(t *testing.T) {
+	srv := redisfttest.NewServer()
+	cli := srv.Client()
+	defer cli.Close()
+	ctx := context.Background()
+	repo := redisft.NewRepo[Session](cli)
+	if err := repo.CreateIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if err := repo.Insert(ctx, "a", &Session{User: "a", ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	err := repo.InsertMany(ctx, map[string]*Session{"b": {User: "b"}, "c": {User: "c"}}, redisft.WithTTL(10*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Insert(ctx, "d", &Session{User: "d"}); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, err := repo.TTL(ctx, "a"); err != nil || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("TTL(a) = %s, %v", ttl, err)
+	}
+	if ttl, err := repo.TTL(ctx, "d"); err != nil || ttl != 0 {
+		t.Errorf("TTL(d) = %s, %v", ttl, err)
+	}
+
+	if err := repo.Touch(ctx, "b", 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Persist(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+	srv.FastForward(90 * time.Minute)
+
+	got, err := repo.Search().SortBy("user", true).ExecIDs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"b", "c", "d"}) {
+		t.Errorf("live docs = %v", got)
+	}
+	if _, err := repo.TTL(ctx, "a"); !errors.Is(err, redisft.ErrNotFound) {
+		t.Errorf("TTL(expired) err = %v", err)
+	}
+	if err := repo.Touch(ctx, "a", time.Minute); !errors.Is(err, redisft.ErrNotFound) {
+		t.Errorf("Touch(expired) err = %v", err)
+	}
+}
+
+type Account struct {
+	Owner   string `redis:"tag"`
+	Balance int64  `redis:"numeric"`
+	Version int64  `redis:"numeric version"`
+}
+
+func TestCompareAndUpdate(t *testing.T) {
+	srv := redisfttest.NewServer()
+	cli := srv.Client()
+	defer cli.Close()
+	ctx := context.Background()
+	repo := redisft.NewRepo[Account](cli)
+
+	a := &Account{Owner: "ada", Balance: 10}
+	if err := repo.Insert(ctx, "1", a); err != nil || a.Version != 1 {
+		t.Fatalf("Insert: version %d, err %v", a.Version, err)
+	}
+	if err := repo.Update(ctx, "1", Account{Balance: 20}); err != nil {
+		t.Fatal(err)
+	}
+	v, err := repo.CompareAndUpdate(ctx, "1", 2, Account{Balance: 30})
+	if err != nil || v != 3 {
+		t.Fatalf("CompareAndUpdate = %d, %v", v, err)
+	}
+	if _, err := repo.CompareAndUpdate(ctx, "1", 2, Account{Balance: 40}); !errors.Is(err, redisft.ErrVersionConflict) {
+		t.Fatalf("stale version: err = %v", err)
+	}
+	if _, err := repo.CompareAndUpdate(ctx, "missing", 0, Account{Balance: 1}); !errors.Is(err, redisft.ErrNotFound) {
+		t.Fatalf("missing doc: err = %v", err)
+	}
+	got, err := repo.Get(ctx, "1")
+	if err != nil || got.Balance != 30 || got.Version != 3 || got.Owner != "ada" {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+
+	// Concurrent read-modify-write loops must not lose increments.
+	const workers, rounds = 4, 10
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < rounds; {
+				cur, err := repo.Get(ctx, "1")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				_, err = repo.CompareAndUpdate(ctx, "1", cur.Version, Account{Balance: cur.Balance + 1})
+				switch {
+				case err == nil:
+					n++
+				case !errors.Is(err, redisft.ErrVersionConflict):
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	got, _ = repo.Get(ctx, "1")
+	if got.Balance != 30+workers*rounds {
+		t.Errorf("balance = %d, want %d", got.Balance, 30+workers*rounds)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	srv := redisfttest.NewServer()
+	cli := srv.Client()
+	defer cli.Close()
+	ctx := context.Background()
+	repo := redisft.NewRepo[Account](cli)
+
+	if err := repo.Insert(ctx, "1", &Account{Owner: "ada", Balance: 10}); err != nil {
+		t.Fatal(err)
+	}
+	a := &Account{Balance: 5}
+	if err := repo.Replace(ctx, "1", a); err != nil || a.Version != 2 {
+		t.Fatalf("Replace: version %d, err %v", a.Version, err)
+	}
+	got, err := repo.Get(ctx, "1")
+	if err != nil || *got != (Account{Balance: 5, Version: 2}) {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+}
+
+type Order struct {
+	Customer string  `redis:"tag"`
+	Total    float64 `redis:"numeric"`
+}
+
+type OrderLine struct {
+	Order string  `redis:"tag"`
+	SKU   string  `redis:"tag"`
+	Price float64 `redis:"numeric"`
+}
+
+func TestRunTx(t *testing.T) {
+	srv := redisfttest.NewServer()
+	cli := srv.Client()
+	defer cli.Close()
+	ctx := context.Background()
+	orders := redisft.NewRepo[Order](cli)
+	lines := redisft.NewRepo[OrderLine](cli)
+	accounts := redisft.NewRepo[Account](cli)
+
+	err := cli.RunTx(ctx, func(ctx context.Context, tx *redisft.Tx) error {
+		if err := orders.InsertTx(tx, "o1", &Order{Customer: "ada", Total: 30}); err != nil {
+			return err
+		}
+		if err := lines.InsertTx(tx, "o1:1", &OrderLine{Order: "o1", SKU: "a", Price: 10}); err != nil {
+			return err
+		}
+		return lines.InsertTx(tx, "o1:2", &OrderLine{Order: "o1", SKU: "b", Price: 20})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.Keys(); !reflect.DeepEqual(got, []string{"order:o1", "orderline:o1:1", "orderline:o1:2"}) {
+		t.Fatalf("keys = %v", got)
+	}
+
+	// An error from fn writes nothing.
+	boom := errors.New("boom")
+	err = cli.RunTx(ctx, func(ctx context.Context, tx *redisft.Tx) error {
+		lines.DeleteTx(tx, "o1:1")
+		return boom
+	})
+	if !errors.Is(err, boom) || len(srv.Keys()) != 3 {
+		t.Fatalf("aborted tx: err %v, keys %v", err, srv.Keys())
+	}
+
+	// A concurrent write to a watched key makes fn run again.
+	if err := accounts.Insert(ctx, "1", &Account{Owner: "ada", Balance: 100}); err != nil {
+		t.Fatal(err)
+	}
+	runs := 0
+	err = cli.RunTx(ctx, func(ctx context.Context, tx *redisft.Tx) error {
+		runs++
+		acc, err := accounts.GetTx(ctx, tx, "1")
+		if err != nil {
+			return err
+		}
+		if runs == 1 {
+			accounts.Update(ctx, "1", Account{Balance: acc.Balance + 5})
+			acc.Balance += 5
+		}
+		if err := accounts.UpdateTx(tx, "1", Account{Balance: acc.Balance - 30}); err != nil {
+			return err
+		}
+		return lines.DeleteTx(tx, "o1:2")
+	})
+	if err != nil || runs != 2 {
+		t.Fatalf("RunTx: err %v after %d runs", err, runs)
+	}
+	acc, _ := accounts.Get(ctx, "1")
+	if acc.Balance != 75 || acc.Version != 3 {
+		t.Errorf("account = %+v", acc)
+	}
+	if srv.Hash("orderline:o1:2") != nil {
+		t.Error("DeleteTx not applied")
+	}
+
+	// Deletes and writes are applied in the order they were queued.
+	err = cli.RunTx(ctx, func(ctx context.Context, tx *redisft.Tx) error {
+		if err := lines.DeleteTx(tx, "o1:1"); err != nil {
+			return err
+		}
+		return lines.InsertTx(tx, "o1:1", &OrderLine{Order: "o1", SKU: "c", Price: 15})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := srv.Hash("orderline:o1:1"); h["sku"] != "c" || h["price"] != "15" {
+		t.Errorf("reinserted line = %v", h)
+	}
+
+	// Conflicts on every attempt exhaust the retries.
+	err = cli.RunTx(ctx, func(ctx context.Context, tx *redisft.Tx) error {
+		if err := accounts.WatchTx(ctx, tx, "1"); err != nil {
+			return err
+		}
+		accounts.Update(ctx, "1", Account{Balance: 1})
+		return orders.DeleteTx(tx, "o1")
+	}, redisft.WithTxRetries(1))
+	if !errors.Is(err, redisft.ErrTxConflict) || srv.Hash("order:o1") == nil {
+		t.Errorf("exhausted retries: err %v", err)
+	}
+}
+
+func TestBulkLoad(t *testing.T) {
+	srv := redisfttest.NewServer()
+	cli := srv.Client()
+	defer cli.Close()
+	ctx := context.Background()
+	repo := redisft.NewRepo[Product](cli)
+
+	var calls []redisft.BulkProgress
+	jsonl := `{"ID":"1","Name":"Go programming","Price":45}
+{"ID":"2","Name":"Redis in action","Price":"sixty"}
+
+{"Name":"no id"}
+{"ID":"3","Name":"Starcraft tutorial","Price":120}
+`
+	res, err := repo.LoadJSONL(ctx, strings.NewReader(jsonl), redisft.BulkOptions{
+		IDField:    "ID",
+		BatchSize:  1,
+		OnProgress: func(p redisft.BulkProgress) { calls = append(calls, p) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Read != 4 || res.Written != 2 || res.Failed != 2 || len(res.Errors) != 2 {
+		t.Fatalf("LoadJSONL = %+v", res.BulkProgress)
+	}
+	if len(calls) != 2 || calls[1].Written != 2 {
+		t.Errorf("progress calls = %+v", calls)
+	}
+	if srv.Hash("product:3")["name"] != "Starcraft tutorial" || srv.Hash("product:2") != nil {
+		t.Errorf("stored keys = %v", srv.Keys())
+	}
+
+	csvData := "id,name,price,colour,notes\n" +
+		"10,Warcraft strategy guide,19.9,red,x\n" +
+		"11,Short row\n" +
+		"12,Redis in action,abc,red,\n" +
+		"13,Go in practice,30,blue,\n"
+	res, err = repo.LoadCSV(ctx, strings.NewReader(csvData), redisft.BulkOptions{
+		IDField: "ID",
+		Columns: map[string]string{"colour": "Color", "notes": "-"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Written != 2 || res.Failed != 2 || res.Errors[0].Record != 2 {
+		t.Fatalf("LoadCSV = %+v, errors %v", res.BulkProgress, res.Errors)
+	}
+	if got, err := repo.Get(ctx, "13"); err != nil || got.Color != "blue" || got.Price != 30 {
+		t.Errorf("Get(13) = %+v, %v", got, err)
+	}
+	if _, err := repo.LoadCSV(ctx, strings.NewReader("id,unknown\n1,x\n"), redisft.BulkOptions{IDField: "ID"}); err == nil {
+		t.Error("unknown CSV column: want error")
+	}
+
+	// Per-record Redis errors do not fail the rest of the batch.
+	accounts := redisft.NewRepo[Account](cli)
+	if err := srv.Dial().HSet(ctx, "account:b", "version", "x").Err(); err != nil {
+		t.Fatal(err)
+	}
+	src := make(chan redisft.Record[Account])
+	go func() {
+		defer close(src)
+		for _, id := range []string{"a", "b", "c", ""} {
+			src <- redisft.Record[Account]{ID: id, Doc: &Account{Owner: id, Balance: 1}}
+		}
+	}()
+	res, err = accounts.Load(ctx, src, redisft.BulkOptions{BatchSize: 10, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Written != 2 || res.Failed != 2 {
+		t.Fatalf("Load = %+v, errors %v", res.BulkProgress, res.Errors)
+	}
+	if got, _ := accounts.Get(ctx, "c"); got == nil || got.Version != 1 {
+		t.Errorf("Get(c) = %+v", got)
+	}
+
+	// A failed cache broadcast does not turn written records into failures.
+	cached := redisft.NewRepo[Product](cli)
+	cache, err := cached.EnableCache(redisft.CacheOptions{TTL: time.Minute, Broadcast: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	srv.FailCommand("PUBLISH", 1, "ERR publish failed")
+	res, err = cached.LoadJSONL(ctx, strings.NewReader(`{"ID":"20","Name":"Lua scripting","Price":30}`+"\n"), redisft.BulkOptions{IDField: "ID"})
+	if !errors.Is(err, redisft.ErrCacheBroadcast) || res.Written != 1 || res.Failed != 0 {
+		t.Fatalf("Load with failed broadcast = %+v, %v (errors %v)", res.BulkProgress, err, res.Errors)
+	}
+	if srv.Hash("product:20") == nil {
+		t.Error("record not written")
+	}
+
+	// MaxErrors stops the import early.
+	bad := strings.Repeat("{}\n", 10)
+	res, err = repo.LoadJSONL(ctx, strings.NewReader(bad), redisft.BulkOptions{IDField: "ID", MaxErrors: 3})
+	if !errors.Is(err, redisft.ErrTooManyErrors) || res.Read != 4 {
+		t.Errorf("MaxErrors: read %d, err %v", res.Read, err)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	repo, srv := newRepo(t)
+	ctx := context.Background()
+	cli := srv.Client()
+	defer cli.Close()
+	if err := redisft.NewRepo[Account](cli).Insert(ctx, "1", &Account{Owner: "ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Update(ctx, "4", Product{Price: 1e-7}); err != nil {
+		t.Fatal(err)
+	}
+	want, err := repo.Get(ctx, "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []redisft.Format{redisft.JSONL, redisft.CSV} {
+		t.Run(format.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := repo.Export(ctx, &buf, format)
+			if err != nil || n != 4 {
+				t.Fatalf("Export = %d, %v", n, err)
+			}
+			if strings.Contains(buf.String(), "ada") {
+				t.Errorf("export contains other repository's documents:\n%s", buf.String())
+			}
+			if stored := srv.Hash("product:4")["price"]; format == redisft.CSV && !strings.Contains(buf.String(), ","+stored+",") {
+				t.Errorf("CSV does not hold the stored price %q:\n%s", stored, buf.String())
+			}
+
+			dst := redisfttest.NewServer()
+			dcli := dst.Client()
+			defer dcli.Close()
+			restored := redisft.NewRepo[Product](dcli)
+			res, err := restored.Import(ctx, &buf, format, redisft.BulkOptions{BatchSize: 3})
+			if err != nil || res.Written != 4 || res.Failed != 0 {
+				t.Fatalf("Import = %+v, %v (errors %v)", res, err, res.Errors)
+			}
+			got, err := restored.Get(ctx, "3")
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("Get(3) after import = %+v, %v; want %+v", got, err, want)
+			}
+		})
+	}
+
+	if _, err := repo.Import(ctx, strings.NewReader("id,name\n1,x\n"), redisft.CSV, redisft.BulkOptions{}); err == nil {
+		t.Error("CSV without _id column: want error")
+	}
+}
+
+func TestScanHashes(t *testing.T) {
+	_, srv := newRepo(t)
+	ctx := context.Background()
+	cli := srv.Client()
+	defer cli.Close()
+	if err := srv.Dial().HSet(ctx, "account:1", "owner", "ada").Err(); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	err := cli.ScanHashes(ctx, "product:", func(keys []string, hashes []map[string]string) error {
+		for i, m := range hashes {
+			got[keys[i]] = m["price"]
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 || got["product:3"] != srv.Hash("product:3")["price"] {
+		t.Errorf("ScanHashes = %v", got)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	repo, srv := newRepo(t)
+	ctx := context.Background()
+	if err := srv.Dial().HSet(ctx, "product:1", "price", "19.90").Err(); err != nil {
+		t.Fatal(err)
+	}
+	upper := func(p *Product) error {
+		switch p.ID {
+		case "2":
+			return redisft.ErrSkip
+		case "3":
+			p.Location = ""
+		case "4":
+			return errors.New("boom")
+		}
+		p.Color = strings.ToUpper(p.Color)
+		return nil
+	}
+
+	st, err := repo.Rewrite(ctx, upper, redisft.WithRewriteDryRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Scanned != 4 || st.Changed != 2 || st.Skipped != 1 || st.Failed != 1 || st.Errors[0].ID != "4" {
+		t.Fatalf("dry run stats = %+v", st)
+	}
+	if h := srv.Hash("product:1"); h["price"] != "19.90" || h["color"] != "red" {
+		t.Fatalf("dry run wrote: %v", h)
+	}
+
+	// Stop after the first batch, then resume from the checkpoint.
+	stop := errors.New("stop")
+	var cursor uint64
+	first, err := repo.Rewrite(ctx, upper, redisft.WithRewriteBatch(1),
+		redisft.WithRewriteCheckpoint(func(c uint64, _ redisft.RewriteStats) error {
+			cursor = c
+			return stop
+		}))
+	if !errors.Is(err, stop) || first.Scanned != 1 || cursor == 0 {
+		t.Fatalf("first run: %+v, cursor %d, err %v", first, cursor, err)
+	}
+	rest, err := repo.Rewrite(ctx, upper, redisft.WithRewriteBatch(1), redisft.WithRewriteResume(cursor),
+		redisft.WithRewriteThrottle(time.Millisecond))
+	if err != nil || first.Scanned+rest.Scanned != 4 {
+		t.Fatalf("resumed run: %+v, err %v", rest, err)
+	}
+
+	if h := srv.Hash("product:1"); h["price"] != "19.9" || h["color"] != "RED" {
+		t.Errorf("product:1 = %v", h)
+	}
+	if h := srv.Hash("product:3"); h["color"] != "RED,GREEN" || h["location"] != "" {
+		t.Errorf("product:3 = %v", h)
+	}
+	if h := srv.Hash("product:2"); h["color"] != "blue" {
+		t.Errorf("skipped product:2 = %v", h)
+	}
+	st, err = repo.Rewrite(ctx, upper)
+	if err != nil || st.Changed != 0 || st.Unchanged != 2 {
+		t.Errorf("second rewrite = %+v, %v", st, err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	srv := redisfttest.NewServer()
+	cli := srv.Client()
+	defer cli.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := redisft.NewRepo[Product](cli)
+
+	if err := cli.EnableKeyspaceNotifications(ctx); err != nil {
+		t.Fatal(err)
+	}
+	events, err := repo.Watch(ctx, redisft.WithWatchDocuments())
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := func(want redisft.EventType, id string) redisft.Event[Product] {
+		t.Helper()
+		select {
+		case ev := <-events:
+			if ev.Type != want || ev.ID != id {
+				t.Fatalf("event = %s %q (err %v), want %s %q", ev.Type, ev.ID, ev.Err, want, id)
+			}
+			return ev
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no %s event for %q", want, id)
+		}
+		return redisft.Event[Product]{}
+	}
+
+	if err := repo.Insert(ctx, "1", &Product{Name: "Go programming"}); err != nil {
+		t.Fatal(err)
+	}
+	if ev := next(redisft.EventCreated, "1"); ev.Doc == nil || ev.Doc.Name != "Go programming" {
+		t.Errorf("created doc = %+v, err %v", ev.Doc, ev.Err)
+	}
+	if err := redisft.NewRepo[Account](cli).Insert(ctx, "1", &Account{Owner: "ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Update(ctx, "1", Product{Price: 45}); err != nil {
+		t.Fatal(err)
+	}
+	if ev := next(redisft.EventUpdated, "1"); ev.Doc == nil || ev.Doc.Price != 45 {
+		t.Errorf("updated doc = %+v, err %v", ev.Doc, ev.Err)
+	}
+	if err := repo.Delete(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	next(redisft.EventDeleted, "1")
+
+	if err := repo.Insert(ctx, "2", &Product{Name: "Redis in action"}, redisft.WithTTL(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	next(redisft.EventCreated, "2")
+	srv.FastForward(2 * time.Second)
+	srv.Keys()
+	next(redisft.EventExpired, "2")
+
+	srv.KillConnections()
+	if ev := next(redisft.EventResync, ""); ev.Err == nil {
+		t.Error("resync event without error")
+	}
+	// Pooled connections were killed too; let the client replace them.
+	for i := 0; i < 5; i++ {
+		if cli.Get().Ping(ctx).Err() == nil {
+			break
+		}
+	}
+	if err := repo.Insert(ctx, "3", &Product{Name: "Starcraft tutorial"}); err != nil {
+		t.Fatal(err)
+	}
+	next(redisft.EventCreated, "3")
+
+	cancel()
+	for range events {
+	}
+
+	srv.DisableConfig()
+	if err := cli.EnableKeyspaceNotifications(context.Background()); err == nil {
+		t.Error("EnableKeyspaceNotifications without CONFIG: want error")
+	}
+}
+
+func TestFacets(t *testing.T) {
+	repo, _ := newRepo(t)
+	ctx := context.Background()
+
+	res, err := repo.Search(redisft.NewNumericQuery("price").Lt(100)).SortBy("price", true).Limit(0, 2).
+		Facets("color").ExecFacets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []redisft.Facet{{Field: "color", Values: []redisft.FacetValue{{Value: "red", Count: 2}, {Value: "blue", Count: 1}, {Value: "green", Count: 1}}}}
+	if res.Total != 3 || !reflect.DeepEqual(ids(res.Items), []string{"1", "2"}) || !reflect.DeepEqual(res.Facets, want) {
+		t.Fatalf("ExecFacets = %+v", res)
+	}
+
+	page, err := repo.Search().Facets("color").FacetLimit(1).Page(ctx, "", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []redisft.Facet{{Field: "color", Values: []redisft.FacetValue{{Value: "green", Count: 2}}}}
+	if !reflect.DeepEqual(page.Facets, want) {
+		t.Fatalf("first page facets = %+v, want %+v", page.Facets, want)
+	}
+	next, err := repo.Page(ctx, page.NextToken, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Facets != nil || len(next.Items) != 1 {
+		t.Fatalf("second page = %+v", next)
+	}
+
+	// FacetLimit(0) keeps more than the 10 rows FT.AGGREGATE returns by default.
+	for i := 0; i < 12; i++ {
+		id := strconv.Itoa(10 + i)
+		if err := repo.Insert(ctx, id, &Product{ID: id, Name: "Sticker", Price: 1, Color: "c" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err = repo.Search().Facets("color").FacetLimit(0).ExecFacets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(res.Facets[0].Values); got != 15 {
+		t.Fatalf("FacetLimit(0) returned %d values, want 15", got)
+	}
+}
+
+func TestRangeFacets(t *testing.T) {
+	repo, _ := newRepo(t)
+	ctx := context.Background()
+
+	res, err := repo.Search().Limit(0, 0).
+		RangeFacets(redisft.NewNumericQuery("price").Lt(50).OrRange(50, 100, true, false).Ge(100)).
+		Histogram("price", 50).
+		Histogram("price", 0).
+		ExecFacets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inf := math.Inf(1)
+	want := []redisft.RangeFacet{
+		{Field: "price", Buckets: []redisft.Bucket{{Lo: math.Inf(-1), Hi: 50, Count: 2}, {Lo: 50, Hi: 100, Count: 1}, {Lo: 100, Hi: inf, Count: 1}}},
+		{Field: "price", Buckets: []redisft.Bucket{{Lo: 0, Hi: 50, Count: 2}, {Lo: 50, Hi: 100, Count: 1}, {Lo: 100, Hi: 150, Count: 1}}},
+		{Field: "price", Buckets: []redisft.Bucket{{Lo: 0, Hi: 20, Count: 1}, {Lo: 40, Hi: 60, Count: 1}, {Lo: 60, Hi: 80, Count: 1}, {Lo: 120, Hi: 140, Count: 1}}},
+	}
+	if res.Total != 4 || !reflect.DeepEqual(res.Ranges, want) {
+		t.Fatalf("Ranges = %+v, want %+v", res.Ranges, want)
+	}
+
+	page, err := repo.Search(redisft.NewTagQB("color").Any("green")).Histogram("price", 100).Page(ctx, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []redisft.RangeFacet{{Field: "price", Buckets: []redisft.Bucket{{Lo: 0, Hi: 100, Count: 1}, {Lo: 100, Hi: 200, Count: 1}}}}
+	if !reflect.DeepEqual(page.Ranges, want) {
+		t.Fatalf("page Ranges = %+v, want %+v", page.Ranges, want)
+	}
+
+	empty, err := repo.Search(redisft.NewTagQB("color").Any("purple")).Histogram("price", 0).ExecFacets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(empty.Ranges) != 1 || len(empty.Ranges[0].Buckets) != 0 {
+		t.Fatalf("empty Ranges = %+v", empty.Ranges)
+	}
+}
+
+func TestTagValues(t *testing.T) {
+	repo, _ := newRepo(t)
+	ctx := context.Background()
+
+	vals, err := repo.TagValues(ctx, "color")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"blue", "green", "red"}; !reflect.DeepEqual(vals, want) {
+		t.Fatalf("TagValues = %q, want %q", vals, want)
+	}
+
+	cache, err := repo.CacheTagValues(ctx, "color", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	if !reflect.DeepEqual(cache.Values(), vals) || cache.LoadedAt().IsZero() {
+		t.Fatalf("cached values = %q", cache.Values())
+	}
+	if err := repo.Insert(ctx, "5", &Product{ID: "5", Name: "Lua scripting", Price: 30, Color: "Black"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := cache.Values(); len(got) != 3 {
+		t.Fatalf("values changed before refresh: %q", got)
+	}
+	if err := cache.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"black", "blue", "green", "red"}; !reflect.DeepEqual(cache.Values(), want) || cache.Err() != nil {
+		t.Fatalf("refreshed values = %q, want %q (err %v)", cache.Values(), want, cache.Err())
+	}
+}
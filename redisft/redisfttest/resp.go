@@ -0,0 +1,120 @@
+package redisfttest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Reply values understood by writeReply. Plain strings are bulk strings,
+// status and errorReply are the "+" and "-" simple types.
+type (
+	status     string
+	errorReply string
+)
+
+func errorf(format string, args ...any) errorReply {
+	return errorReply(fmt.Sprintf(format, args...))
+}
+
+// readCommand reads one RESP array of bulk strings (or an inline command).
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, fmt.Errorf("redisfttest: bad array header %q", line)
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		hdr, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(hdr) == 0 || hdr[0] != '$' {
+			return nil, fmt.Errorf("redisfttest: bad bulk header %q", hdr)
+		}
+		size, err := strconv.Atoi(hdr[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("redisfttest: bad bulk length %q", hdr)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func writeReply(w *bytes.Buffer, v any) {
+	switch t := v.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case status:
+		w.WriteString("+" + string(t) + "\r\n")
+	case errorReply:
+		w.WriteString("-" + strings.ReplaceAll(string(t), "\r\n", " ") + "\r\n")
+	case int:
+		fmt.Fprintf(w, ":%d\r\n", t)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", t)
+	case bool:
+		if t {
+			w.WriteString(":1\r\n")
+		} else {
+			w.WriteString(":0\r\n")
+		}
+	case float64:
+		writeBulk(w, formatFloat(t))
+	case string:
+		writeBulk(w, t)
+	case []string:
+		fmt.Fprintf(w, "*%d\r\n", len(t))
+		for _, s := range t {
+			writeBulk(w, s)
+		}
+	case replies:
+		for _, e := range t {
+			writeReply(w, e)
+		}
+	case []any:
+		if t == nil {
+			w.WriteString("*-1\r\n")
+			return
+		}
+		fmt.Fprintf(w, "*%d\r\n", len(t))
+		for _, e := range t {
+			writeReply(w, e)
+		}
+	default:
+		writeBulk(w, fmt.Sprint(t))
+	}
+}
+
+func writeBulk(w *bytes.Buffer, s string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,632 @@
+// Package redisfttest provides an in-process fake of Redis + RediSearch for
+// hermetic tests of code built on redisft.Repository.
+//
+// The fake speaks RESP over in-memory connections, so the regular go-redis
+// client (pipelines included) talks to it unchanged. It stores hashes and
+// evaluates FT.CREATE, FT.SEARCH, FT.AGGREGATE and FT.DROPINDEX for the query
+// syntax the redisft builders emit: text terms, prefix/suffix/infix/fuzzy
+// terms, exact phrases, tags, numeric ranges, geo radius, negation, unions,
+// SORTBY, LIMIT, RETURN, NOCONTENT, INKEYS and INFIELDS. Scoring, stemming
+// and stop words are not emulated; unsorted results come back in key order.
+package redisfttest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bariscan97/redis-ftsearch/redisft"
+	"github.com/go-redis/redis/v8"
+)
+
+// Server is the in-memory backend. It is safe for concurrent use.
+type Server struct {
+	mu      sync.Mutex
+	hashes  map[string]map[string]string
+	indexes map[string]*index
+	aliases map[string]string // alias → index name
+
+	faults    []string
+	cmdFaults map[string][]string // per command, see FailCommand
+	before    map[string][]func() // see BeforeCommand
+	subs      map[string]map[*conn]bool
+	psubs     map[string]map[*conn]bool
+	conns     map[*conn]bool
+	config    map[string]string
+	noConfig  bool
+
+	suggestions map[string]map[string]float64 // FT.SUGADD dictionaries
+	expires     map[string]time.Time
+	skew        time.Duration
+	versions    map[string]uint64 // bumped on every write, for WATCH
+}
+
+func NewServer() *Server {
+	return &Server{
+		hashes:  map[string]map[string]string{},
+		indexes: map[string]*index{},
+	}
+}
+
+// NewClient returns a redisft.Client backed by a fresh Server.
+func NewClient() *redisft.Client { return NewServer().Client() }
+
+// Dial returns a go-redis client whose connections are served by s.
+func (s *Server) Dial() *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr: "redisfttest",
+		// go-redis retries LOADING and similar errors itself; disable that
+		// so tests observe every reply.
+		MaxRetries: -1,
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go s.serve(server)
+			return client, nil
+		},
+	})
+}
+
+// Pool returns a redisft.ConnPool backed by s.
+func (s *Server) Pool() *Pool { return &Pool{srv: s, client: s.Dial()} }
+
+// Client returns a redisft.Client backed by s.
+func (s *Server) Client() *redisft.Client { return redisft.NewClientFromPool(s.Pool()) }
+
+// Hash returns a copy of the hash stored at key, or nil.
+func (s *Server) Hash(key string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireKeys()
+	h, ok := s.hashes[key]
+	if !ok {
+		return nil
+	}
+	cp := make(map[string]string, len(h))
+	for k, v := range h {
+		cp[k] = v
+	}
+	return cp
+}
+
+// Keys returns all stored keys in sorted order.
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireKeys()
+	return s.sortedKeys()
+}
+
+func (s *Server) sortedKeys() []string {
+	keys := make([]string, 0, len(s.hashes))
+	for k := range s.hashes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Pool is a redisft.ConnPool served by a Server.
+type Pool struct {
+	srv    *Server
+	client *redis.Client
+}
+
+func (p *Pool) Get() redisft.RedisClient { return p.client }
+func (p *Pool) Close() error             { return p.client.Close() }
+func (p *Pool) Server() *Server          { return p.srv }
+
+// conn is the server side of one client connection. Replies are queued and
+// written by a separate goroutine so a large pipeline can never deadlock the
+// synchronous net.Pipe.
+type conn struct {
+	srv *Server
+	nc  net.Conn
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	out    bytes.Buffer
+	closed bool
+
+	// Guarded by srv.mu.
+	channels map[string]bool
+	patterns map[string]bool
+	multi    [][]string // queued commands between MULTI and EXEC
+	watched  map[string]uint64
+}
+
+func (s *Server) serve(nc net.Conn) {
+	c := &conn{srv: s, nc: nc, channels: map[string]bool{}, patterns: map[string]bool{}}
+	c.cond = sync.NewCond(&c.mu)
+	s.mu.Lock()
+	if s.conns == nil {
+		s.conns = map[*conn]bool{}
+	}
+	s.conns[c] = true
+	s.mu.Unlock()
+	go c.writeLoop()
+	defer c.close()
+	defer s.dropSubscriptions(c)
+
+	r := bufio.NewReader(nc)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		var buf bytes.Buffer
+		writeReply(&buf, s.exec(c, args))
+		c.send(buf.Bytes())
+	}
+}
+
+func (c *conn) send(b []byte) {
+	c.mu.Lock()
+	c.out.Write(b)
+	c.cond.Signal()
+	c.mu.Unlock()
+}
+
+func (c *conn) close() {
+	c.mu.Lock()
+	c.closed = true
+	c.cond.Signal()
+	c.mu.Unlock()
+}
+
+func (c *conn) writeLoop() {
+	defer c.nc.Close()
+	for {
+		c.mu.Lock()
+		for c.out.Len() == 0 && !c.closed {
+			c.cond.Wait()
+		}
+		if c.out.Len() == 0 {
+			c.mu.Unlock()
+			return
+		}
+		b := append([]byte(nil), c.out.Bytes()...)
+		c.out.Reset()
+		c.mu.Unlock()
+		if _, err := c.nc.Write(b); err != nil {
+			return
+		}
+	}
+}
+
+type handler func(s *Server, c *conn, args []string) any
+
+var commands map[string]handler
+
+func init() {
+	commands = map[string]handler{
+		"PING":     cmdPing,
+		"ECHO":     cmdEcho,
+		"SELECT":   cmdOK,
+		"HSET":     cmdHSet,
+		"HMSET":    cmdHSet,
+		"HGET":     cmdHGet,
+		"HMGET":    cmdHMGet,
+		"HGETALL":  cmdHGetAll,
+		"HDEL":     cmdHDel,
+		"HLEN":     cmdHLen,
+		"DEL":      cmdDel,
+		"UNLINK":   cmdDel,
+		"EXISTS":   cmdExists,
+		"KEYS":     cmdKeys,
+		"SCAN":     cmdScan,
+		"DBSIZE":   cmdDBSize,
+		"FLUSHALL": cmdFlush,
+		"FLUSHDB":  cmdFlush,
+
+		"SUBSCRIBE":    cmdSubscribe,
+		"UNSUBSCRIBE":  cmdUnsubscribe,
+		"PUBLISH":      cmdPublish,
+		"PSUBSCRIBE":   cmdPSubscribe,
+		"PUNSUBSCRIBE": cmdPUnsubscribe,
+		"CONFIG":       cmdConfig,
+
+		"EXPIRE":    cmdExpire,
+		"PEXPIRE":   cmdExpire,
+		"EXPIREAT":  cmdExpire,
+		"PEXPIREAT": cmdExpire,
+		"PERSIST":   cmdPersist,
+		"TTL":       cmdTTL,
+		"PTTL":      cmdTTL,
+		"HINCRBY":   cmdHIncrBy,
+
+		"WATCH":   cmdWatch,
+		"UNWATCH": cmdUnwatch,
+		"MULTI":   cmdMulti,
+		"EXEC":    cmdExec,
+		"DISCARD": cmdDiscard,
+
+		"FT.CREATE":      cmdFTCreate,
+		"FT.DROPINDEX":   cmdFTDropIndex,
+		"FT.SEARCH":      cmdFTSearch,
+		"FT.AGGREGATE":   cmdFTAggregate,
+		"FT._LIST":       cmdFTList,
+		"FT.INFO":        cmdFTInfo,
+		"FT.ALIASADD":    cmdFTAliasAdd,
+		"FT.ALIASUPDATE": cmdFTAliasAdd,
+		"FT.ALIASDEL":    cmdFTAliasDel,
+		"FT.SUGADD":      cmdFTSugAdd,
+		"FT.SUGGET":      cmdFTSugGet,
+		"FT.TAGVALS":     cmdFTTagVals,
+	}
+}
+
+func (s *Server) exec(c *conn, args []string) any {
+	name := strings.ToUpper(args[0])
+	h, ok := commands[name]
+	if !ok {
+		return errorf("ERR unknown command '%s'", args[0])
+	}
+	s.mu.Lock()
+	var fn func()
+	if f := s.before[name]; len(f) > 0 {
+		fn, s.before[name] = f[0], f[1:]
+	}
+	s.mu.Unlock()
+	if fn != nil {
+		fn()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.subscriptions() > 0 && !isPubSubCommand(name) {
+		return errorf("ERR Can't execute '%s': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context", strings.ToLower(name))
+	}
+	s.expireKeys()
+	if len(s.faults) > 0 {
+		msg := s.faults[0]
+		s.faults = s.faults[1:]
+		return errorReply(msg)
+	}
+	if f := s.cmdFaults[name]; len(f) > 0 {
+		s.cmdFaults[name] = f[1:]
+		return errorReply(f[0])
+	}
+	if c.multi != nil {
+		switch name {
+		case "EXEC", "DISCARD", "MULTI", "WATCH":
+		default:
+			c.multi = append(c.multi, args)
+			return status("QUEUED")
+		}
+	}
+	return s.run(c, name, h, args)
+}
+
+// FailNext makes the next n commands fail with the error reply msg, e.g.
+// "LOADING Redis is loading the dataset in memory".
+func (s *Server) FailNext(n int, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.faults = append(s.faults, msg)
+	}
+}
+
+// FailCommand makes the next n calls of the command cmd fail with the error
+// reply msg, leaving other commands alone.
+func (s *Server) FailCommand(cmd string, n int, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cmdFaults == nil {
+		s.cmdFaults = map[string][]string{}
+	}
+	cmd = strings.ToUpper(cmd)
+	for i := 0; i < n; i++ {
+		s.cmdFaults[cmd] = append(s.cmdFaults[cmd], msg)
+	}
+}
+
+// BeforeCommand makes the server call fn once, just before it handles the
+// next call of the command cmd. fn may send commands of its own over another
+// connection, e.g. to change a key between a client's read and its write.
+func (s *Server) BeforeCommand(cmd string, fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.before == nil {
+		s.before = map[string][]func(){}
+	}
+	cmd = strings.ToUpper(cmd)
+	s.before[cmd] = append(s.before[cmd], fn)
+}
+
+func wrongArgs(args []string) errorReply {
+	return errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(args[0]))
+}
+
+func cmdPing(s *Server, c *conn, args []string) any {
+	if len(c.channels) > 0 {
+		return []any{"pong", arg(args, 1)}
+	}
+	if len(args) > 1 {
+		return args[1]
+	}
+	return status("PONG")
+}
+
+func cmdEcho(s *Server, c *conn, args []string) any {
+	if len(args) != 2 {
+		return wrongArgs(args)
+	}
+	return args[1]
+}
+
+func cmdOK(s *Server, c *conn, args []string) any { return status("OK") }
+
+func cmdHSet(s *Server, c *conn, args []string) any {
+	if len(args) < 4 || len(args)%2 != 0 {
+		return wrongArgs(args)
+	}
+	h, ok := s.hashes[args[1]]
+	if !ok {
+		h = map[string]string{}
+		s.hashes[args[1]] = h
+	}
+	added := 0
+	for i := 2; i < len(args); i += 2 {
+		if _, exists := h[args[i]]; !exists {
+			added++
+		}
+		h[args[i]] = args[i+1]
+	}
+	if strings.EqualFold(args[0], "HMSET") {
+		return status("OK")
+	}
+	return added
+}
+
+func cmdHGet(s *Server, c *conn, args []string) any {
+	if len(args) != 3 {
+		return wrongArgs(args)
+	}
+	v, ok := s.hashes[args[1]][args[2]]
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+func cmdHMGet(s *Server, c *conn, args []string) any {
+	if len(args) < 3 {
+		return wrongArgs(args)
+	}
+	out := make([]any, 0, len(args)-2)
+	for _, f := range args[2:] {
+		if v, ok := s.hashes[args[1]][f]; ok {
+			out = append(out, v)
+		} else {
+			out = append(out, nil)
+		}
+	}
+	return out
+}
+
+func cmdHGetAll(s *Server, c *conn, args []string) any {
+	if len(args) != 2 {
+		return wrongArgs(args)
+	}
+	return flatHash(s.hashes[args[1]], nil)
+}
+
+// flatHash renders h as a field/value list in field order, optionally
+// restricted to the given fields.
+func flatHash(h map[string]string, only []string) []any {
+	out := []any{}
+	if only != nil {
+		for _, f := range only {
+			if v, ok := h[f]; ok {
+				out = append(out, f, v)
+			}
+		}
+		return out
+	}
+	fields := make([]string, 0, len(h))
+	for f := range h {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	for _, f := range fields {
+		out = append(out, f, h[f])
+	}
+	return out
+}
+
+func cmdHDel(s *Server, c *conn, args []string) any {
+	if len(args) < 3 {
+		return wrongArgs(args)
+	}
+	h, ok := s.hashes[args[1]]
+	if !ok {
+		return 0
+	}
+	n := 0
+	for _, f := range args[2:] {
+		if _, ok := h[f]; ok {
+			delete(h, f)
+			n++
+		}
+	}
+	if len(h) == 0 {
+		s.deleteKey(args[1])
+	}
+	return n
+}
+
+func cmdHLen(s *Server, c *conn, args []string) any {
+	if len(args) != 2 {
+		return wrongArgs(args)
+	}
+	return len(s.hashes[args[1]])
+}
+
+func cmdDel(s *Server, c *conn, args []string) any {
+	if len(args) < 2 {
+		return wrongArgs(args)
+	}
+	n := 0
+	for _, k := range args[1:] {
+		if s.deleteKey(k) {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdExists(s *Server, c *conn, args []string) any {
+	if len(args) < 2 {
+		return wrongArgs(args)
+	}
+	n := 0
+	for _, k := range args[1:] {
+		if _, ok := s.hashes[k]; ok {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdKeys(s *Server, c *conn, args []string) any {
+	if len(args) != 2 {
+		return wrongArgs(args)
+	}
+	out := []string{}
+	for _, k := range s.sortedKeys() {
+		if globMatch(args[1], k) {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// cmdScan walks the sorted keyspace; the cursor is a position in it, so
+// keys created or deleted during an iteration may shift what is returned.
+func cmdScan(s *Server, c *conn, args []string) any {
+	if len(args) < 2 || len(args)%2 != 0 {
+		return wrongArgs(args)
+	}
+	pos, err := strconv.Atoi(args[1])
+	if err != nil || pos < 0 {
+		return errorf("ERR invalid cursor")
+	}
+	match, count, typ := "*", 10, "hash"
+	for i := 2; i < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			match = args[i+1]
+		case "COUNT":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count < 1 {
+				return errorf("ERR value is not an integer or out of range")
+			}
+		case "TYPE":
+			typ = strings.ToLower(args[i+1])
+		default:
+			return errorf("ERR syntax error")
+		}
+	}
+	keys := s.sortedKeys()
+	end, next := pos+count, pos+count
+	if end >= len(keys) {
+		end, next = len(keys), 0
+	}
+	out := []string{}
+	if typ == "hash" {
+		for _, k := range keys[min(pos, end):end] {
+			if globMatch(match, k) {
+				out = append(out, k)
+			}
+		}
+	}
+	return []any{strconv.Itoa(next), out}
+}
+
+func cmdDBSize(s *Server, c *conn, args []string) any { return len(s.hashes) }
+
+func cmdFlush(s *Server, c *conn, args []string) any {
+	for k := range s.hashes {
+		s.touch(k)
+	}
+	s.hashes = map[string]map[string]string{}
+	s.expires = nil
+	return status("OK")
+}
+
+// globMatch implements the Redis glob dialect: *, ?, [abc], [^a-z] and \x.
+func globMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if globMatch(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+		case '[':
+			if s == "" {
+				return false
+			}
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				return false
+			}
+			class := pattern[1 : end+1]
+			neg := strings.HasPrefix(class, "^")
+			if neg {
+				class = class[1:]
+			}
+			match := false
+			for i := 0; i < len(class); i++ {
+				if i+2 < len(class) && class[i+1] == '-' {
+					if s[0] >= class[i] && s[0] <= class[i+2] {
+						match = true
+					}
+					i += 2
+				} else if class[i] == s[0] {
+					match = true
+				}
+			}
+			if match == neg {
+				return false
+			}
+			pattern = pattern[end+1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if s == "" || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return s == ""
+}
+
+func atoi(s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
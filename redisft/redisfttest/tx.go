@@ -0,0 +1,133 @@
+package redisfttest
+
+import (
+	"strconv"
+	"strings"
+)
+
+// keyWriters lists commands that modify the keys they name, for WATCH.
+// DEL and UNLINK take several keys; the rest take one at args[1].
+var keyWriters = map[string]bool{
+	"HSET": true, "HMSET": true, "HDEL": true, "HINCRBY": true,
+	"DEL": true, "UNLINK": true, "PERSIST": true,
+	"EXPIRE": true, "PEXPIRE": true, "EXPIREAT": true, "PEXPIREAT": true,
+}
+
+// touch records a modification of key. Must hold s.mu.
+func (s *Server) touch(key string) {
+	if s.versions == nil {
+		s.versions = map[string]uint64{}
+	}
+	s.versions[key]++
+}
+
+// run executes one command, records the keys it modified and publishes
+// their keyspace notifications.
+func (s *Server) run(c *conn, name string, h handler, args []string) any {
+	var keys []string
+	if keyWriters[name] && len(args) >= 2 {
+		keys = args[1:2]
+		if name == "DEL" || name == "UNLINK" {
+			keys = args[1:]
+		}
+	}
+	existed := make([]bool, len(keys))
+	for i, k := range keys {
+		_, existed[i] = s.hashes[k]
+	}
+	reply := h(s, c, args)
+	if _, failed := reply.(errorReply); failed {
+		return reply
+	}
+	for i, k := range keys {
+		s.touch(k)
+		s.notifyWrite(name, k, existed[i], reply)
+	}
+	return reply
+}
+
+// WATCH key [key ...]
+func cmdWatch(s *Server, c *conn, args []string) any {
+	if len(args) < 2 {
+		return wrongArgs(args)
+	}
+	if c.multi != nil {
+		return errorf("ERR WATCH inside MULTI is not allowed")
+	}
+	if c.watched == nil {
+		c.watched = map[string]uint64{}
+	}
+	for _, k := range args[1:] {
+		if _, ok := c.watched[k]; !ok {
+			c.watched[k] = s.versions[k]
+		}
+	}
+	return status("OK")
+}
+
+func cmdUnwatch(s *Server, c *conn, args []string) any {
+	c.watched = nil
+	return status("OK")
+}
+
+func cmdMulti(s *Server, c *conn, args []string) any {
+	if c.multi != nil {
+		return errorf("ERR MULTI calls can not be nested")
+	}
+	c.multi = [][]string{}
+	return status("OK")
+}
+
+func cmdDiscard(s *Server, c *conn, args []string) any {
+	if c.multi == nil {
+		return errorf("ERR DISCARD without MULTI")
+	}
+	c.multi, c.watched = nil, nil
+	return status("OK")
+}
+
+// EXEC runs the queued commands, or replies with a nil array when a watched
+// key changed since WATCH.
+func cmdExec(s *Server, c *conn, args []string) any {
+	if c.multi == nil {
+		return errorf("ERR EXEC without MULTI")
+	}
+	queued, watched := c.multi, c.watched
+	c.multi, c.watched = nil, nil
+	for k, v := range watched {
+		if s.versions[k] != v {
+			return []any(nil)
+		}
+	}
+	out := make([]any, 0, len(queued))
+	for _, q := range queued {
+		name := strings.ToUpper(q[0])
+		out = append(out, s.run(c, name, commands[name], q))
+	}
+	return out
+}
+
+// HINCRBY key field increment
+func cmdHIncrBy(s *Server, c *conn, args []string) any {
+	if len(args) != 4 {
+		return wrongArgs(args)
+	}
+	by, err := strconv.ParseInt(args[3], 10, 64)
+	if err != nil {
+		return errorf("ERR value is not an integer or out of range")
+	}
+	h, ok := s.hashes[args[1]]
+	if !ok {
+		h = map[string]string{}
+		s.hashes[args[1]] = h
+	}
+	var n int64
+	if v, ok := h[args[2]]; ok {
+		if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return errorf("ERR hash value is not an integer")
+		}
+	}
+	n += by
+	h[args[2]] = strconv.FormatInt(n, 10)
+	return n
+}
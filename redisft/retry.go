@@ -0,0 +1,327 @@
+package redisft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting Redis while the circuit
+// breaker of an index is open.
+var ErrCircuitOpen = errors.New("redisft: circuit breaker open")
+
+// RetryPolicy controls how repository operations are retried on transient
+// errors (see IsTransient). Reads (Get, searches, Explain, Profile) and the
+// idempotent index commands are retried up to MaxAttempts; writes only when
+// RetryWrites is set. Note that go-redis retries some errors on its own
+// (Options.MaxRetries) before redisft sees them.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, including the first.
+	MaxAttempts int
+	// RetryWrites allows retrying Insert, InsertMany, Replace, Update,
+	// Delete and RunTx. They overwrite whole fields, so a duplicate is
+	// harmless, but a retry can reorder them with concurrent writers.
+	// Writes of documents with a version field are never retried: a write
+	// that failed ambiguously may have been applied, and repeating it would
+	// increment the version twice.
+	RetryWrites bool
+	// MinBackoff is the delay before the first retry; it doubles on each
+	// further retry up to MaxBackoff. Each delay is jittered to [d/2, d].
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// OnRetry, if set, is called before sleeping for attempt+1.
+	OnRetry func(index string, op Op, attempt int, err error)
+}
+
+// DefaultRetryPolicy retries reads three times with 10ms–500ms backoff.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, MinBackoff: 10 * time.Millisecond, MaxBackoff: 500 * time.Millisecond}
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxBackoff)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// WithRetry enables retries of transient errors.
+func WithRetry(p RetryPolicy) Option {
+	return func(o *options) error {
+		if p.MaxAttempts < 1 {
+			return fmt.Errorf("redisft: WithRetry: MaxAttempts must be at least 1, got %d", p.MaxAttempts)
+		}
+		if p.MinBackoff < 0 || p.MaxBackoff < p.MinBackoff {
+			return fmt.Errorf("redisft: WithRetry: invalid backoff range [%s, %s]", p.MinBackoff, p.MaxBackoff)
+		}
+		o.retry = &p
+		return nil
+	}
+}
+
+// BreakerState is the state of a per-index circuit breaker.
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// BreakerPolicy configures the per-index circuit breaker. After Threshold
+// consecutive transient failures the breaker opens and operations on that
+// index fail with ErrCircuitOpen. After OpenTimeout a single probe is let
+// through; its outcome closes or re-opens the breaker.
+type BreakerPolicy struct {
+	Threshold   int
+	OpenTimeout time.Duration
+	// OnStateChange, if set, is called on every transition.
+	OnStateChange func(index string, from, to BreakerState)
+}
+
+// WithCircuitBreaker enables a circuit breaker per index.
+func WithCircuitBreaker(p BreakerPolicy) Option {
+	return func(o *options) error {
+		if p.Threshold < 1 {
+			return fmt.Errorf("redisft: WithCircuitBreaker: Threshold must be at least 1, got %d", p.Threshold)
+		}
+		if p.OpenTimeout <= 0 {
+			return fmt.Errorf("redisft: WithCircuitBreaker: OpenTimeout must be positive, got %s", p.OpenTimeout)
+		}
+		o.breaker = &p
+		return nil
+	}
+}
+
+// ResilienceStats counts retry and breaker activity since the Client was
+// created.
+type ResilienceStats struct {
+	Retries      uint64 // retries performed
+	BreakerOpens uint64 // closed/half-open → open transitions
+	Rejected     uint64 // operations refused by an open breaker
+}
+
+type resilienceCounters struct {
+	retries, opens, rejected atomic.Uint64
+}
+
+// ResilienceStats returns a snapshot of the retry and breaker counters.
+func (c *Client) ResilienceStats() ResilienceStats {
+	return ResilienceStats{
+		Retries:      c.stats.retries.Load(),
+		BreakerOpens: c.stats.opens.Load(),
+		Rejected:     c.stats.rejected.Load(),
+	}
+}
+
+// BreakerState reports the breaker state of index ("idx:<name>").
+func (c *Client) BreakerState(index string) BreakerState {
+	b := c.breakerFor(index)
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+type breaker struct {
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func (c *Client) breakerFor(index string) *breaker {
+	if c.breakerPolicy == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.breakers == nil {
+		c.breakers = map[string]*breaker{}
+	}
+	b, ok := c.breakers[index]
+	if !ok {
+		b = &breaker{}
+		c.breakers[index] = b
+	}
+	return b
+}
+
+func (c *Client) transition(b *breaker, index string, to BreakerState) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	if to == BreakerOpen {
+		b.openedAt = time.Now()
+		c.stats.opens.Add(1)
+	}
+	if fn := c.breakerPolicy.OnStateChange; fn != nil {
+		fn(index, from, to)
+	}
+}
+
+// allow reports whether an operation may proceed. Must hold b.mu.
+func (c *Client) allow(b *breaker, index string) bool {
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < c.breakerPolicy.OpenTimeout {
+			return false
+		}
+		c.transition(b, index, BreakerHalfOpen)
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// record feeds the outcome of an operation into b. Must hold b.mu.
+func (c *Client) record(b *breaker, index string, err error) {
+	b.probing = false
+	if err == nil || !IsTransient(err) {
+		b.failures = 0
+		c.transition(b, index, BreakerClosed)
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= c.breakerPolicy.Threshold {
+		b.failures = 0
+		c.transition(b, index, BreakerOpen)
+	}
+}
+
+// readOps are safe to retry without RetryPolicy.RetryWrites.
+var readOps = map[Op]bool{
+	OpGet: true, OpSearch: true, OpExplain: true, OpProfile: true, OpTTL: true, OpScan: true, OpAggregate: true, OpTagVals: true,
+	OpCreateIndex: true, OpDropIndex: true,
+}
+
+// attempts returns how many times the command described by info may be
+// tried. It is asked after each failure, since fn may only learn whether it
+// writes versions while it runs (RunTx).
+func (c *Client) attempts(info *CommandInfo) int {
+	if c.retry == nil || !readOps[info.Op] && (!c.retry.RetryWrites || info.versioned) {
+		return 1
+	}
+	return c.retry.MaxAttempts
+}
+
+// run executes fn under the client's breaker and retry policy.
+func (c *Client) run(ctx context.Context, info *CommandInfo, fn func(ctx context.Context) error) error {
+	b := c.breakerFor(info.Index)
+	if b != nil {
+		b.mu.Lock()
+		ok := c.allow(b, info.Index)
+		b.mu.Unlock()
+		if !ok {
+			c.stats.rejected.Add(1)
+			return fmt.Errorf("%w: %s", ErrCircuitOpen, info.Index)
+		}
+	}
+
+	var err error
+	for i := 1; ; i++ {
+		info.Attempts = i
+		if err = fn(ctx); err == nil || i >= c.attempts(info) || !IsTransient(err) {
+			break
+		}
+		c.stats.retries.Add(1)
+		if c.retry.OnRetry != nil {
+			c.retry.OnRetry(info.Index, info.Op, i, err)
+		}
+		t := time.NewTimer(c.retry.backoff(i))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+		case <-t.C:
+			continue
+		}
+		break
+	}
+
+	if b != nil {
+		b.mu.Lock()
+		c.record(b, info.Index, err)
+		b.mu.Unlock()
+	}
+	return err
+}
+
+// IsTransient reports whether err is likely to go away on retry: the server
+// is loading or failing over, the cluster is resharding, or the connection
+// broke. Context cancellation and query errors are not transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	msg := err.Error()
+	if strings.Contains(msg, "connection reset by peer") || strings.Contains(msg, "broken pipe") {
+		return true
+	}
+	return hasTransientPrefix(err)
+}
+
+// hasTransientPrefix looks for a Redis error reply such as "LOADING …"
+// anywhere in err's chain; wrapErr may have wrapped it.
+func hasTransientPrefix(err error) bool {
+	msg := err.Error()
+	for _, prefix := range []string{"LOADING ", "TRYAGAIN ", "CLUSTERDOWN ", "MOVED ", "ASK ", "READONLY ", "MASTERDOWN "} {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		if e := u.Unwrap(); e != nil {
+			return hasTransientPrefix(e)
+		}
+	case interface{ Unwrap() []error }:
+		for _, e := range u.Unwrap() {
+			if hasTransientPrefix(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package redisft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestIsTransient(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{redis.Nil, false},
+		{errors.New("LOADING Redis is loading the dataset in memory"), true},
+		{errors.New("CLUSTERDOWN The cluster is down"), true},
+		{errors.New("TRYAGAIN Multiple keys request during rehashing of slot"), true},
+		{errors.New("MOVED 3999 127.0.0.1:6381"), true},
+		{fmt.Errorf("%w: %w", ErrTimeout, errors.New("READONLY You can't write against a read only replica.")), true},
+		{io.EOF, true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{context.Canceled, false},
+		{fmt.Errorf("%w: %w", ErrTimeout, context.DeadlineExceeded), false},
+		{errors.New("Syntax error at offset 3 near foo"), false},
+		{ErrNotFound, false},
+	}
+	for _, tc := range tests {
+		if got := IsTransient(tc.err); got != tc.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	t.Parallel()
+	p := RetryPolicy{MinBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	for attempt, max := range map[int]time.Duration{1: 10, 2: 20, 3: 40, 4: 50, 10: 50} {
+		max *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			if d := p.backoff(attempt); d < max/2 || d > max {
+				t.Fatalf("backoff(%d) = %s, want in [%s, %s]", attempt, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestClientRun_Breaker(t *testing.T) {
+	t.Parallel()
+	var transitions []string
+	cli, err := NewClientWithOptions("h:1",
+		WithRetry(RetryPolicy{MaxAttempts: 2}),
+		WithCircuitBreaker(BreakerPolicy{Threshold: 2, OpenTimeout: 20 * time.Millisecond,
+			OnStateChange: func(_ string, from, to BreakerState) {
+				transitions = append(transitions, from.String()+">"+to.String())
+			}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	ctx := context.Background()
+	calls := 0
+	loading := func(context.Context) error { calls++; return errors.New("LOADING busy") }
+	ok := func(context.Context) error { calls++; return nil }
+	read := func() *CommandInfo { return &CommandInfo{Index: "idx:p", Op: OpSearch} }
+
+	// One read = 2 attempts; two failed reads open the breaker.
+	for i := 0; i < 2; i++ {
+		if err := cli.run(ctx, read(), loading); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if calls != 4 || cli.BreakerState("idx:p") != BreakerOpen {
+		t.Fatalf("calls=%d state=%s", calls, cli.BreakerState("idx:p"))
+	}
+	if err := cli.run(ctx, read(), ok); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("open breaker: err = %v", err)
+	}
+	if cli.BreakerState("idx:other") != BreakerClosed {
+		t.Error("breaker is not per index")
+	}
+	// Writes are not retried without RetryWrites.
+	calls = 0
+	cli.run(ctx, &CommandInfo{Index: "idx:w", Op: OpInsert}, loading)
+	if calls != 1 {
+		t.Errorf("write attempted %d times", calls)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if err := cli.run(ctx, read(), ok); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if got := strings.Join(transitions, ","); got != "closed>open,open>half-open,half-open>closed" {
+		t.Errorf("transitions = %v", transitions)
+	}
+	st := cli.ResilienceStats()
+	if st.Retries != 2 || st.BreakerOpens != 1 || st.Rejected != 1 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestClientRun_RetryWrites(t *testing.T) {
+	t.Parallel()
+	cli, err := NewClientWithOptions("h:1", WithRetry(RetryPolicy{MaxAttempts: 3, RetryWrites: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	ctx := context.Background()
+	for _, tc := range []struct {
+		info *CommandInfo
+		want int
+	}{
+		{&CommandInfo{Op: OpInsert}, 3},
+		{&CommandInfo{Op: OpTx}, 3},
+		{&CommandInfo{Op: OpInsert, versioned: true}, 1},
+		{&CommandInfo{Op: OpInsertMany, versioned: true}, 1},
+	} {
+		calls := 0
+		cli.run(ctx, tc.info, func(context.Context) error { calls++; return errors.New("LOADING busy") })
+		if calls != tc.want {
+			t.Errorf("%s (versioned %v) attempted %d times, want %d", tc.info.Op, tc.info.versioned, calls, tc.want)
+		}
+	}
+}
+
+func TestRetryOptions_Invalid(t *testing.T) {
+	t.Parallel()
+	for _, opt := range []Option{
+		WithRetry(RetryPolicy{}),
+		WithRetry(RetryPolicy{MaxAttempts: 2, MinBackoff: time.Second, MaxBackoff: time.Millisecond}),
+		WithCircuitBreaker(BreakerPolicy{OpenTimeout: time.Second}),
+		WithCircuitBreaker(BreakerPolicy{Threshold: 1}),
+	} {
+		if _, err := NewClientWithOptions("h:1", opt); err == nil {
+			t.Error("expected error")
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package redisft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ErrSkip may be returned by a Rewrite function to leave a document as it
+// is without counting it as failed.
+var ErrSkip = errors.New("redisft: skip document")
+
+// RewriteOption customises Rewrite.
+type RewriteOption func(*rewriteOptions)
+
+type rewriteOptions struct {
+	batch      int64
+	throttle   time.Duration
+	dryRun     bool
+	cursor     uint64
+	checkpoint func(cursor uint64, stats RewriteStats) error
+}
+
+// WithRewriteBatch sets the SCAN COUNT hint, and so roughly the number of
+// documents read and written per batch. Default 500.
+func WithRewriteBatch(n int) RewriteOption {
+	return func(o *rewriteOptions) { o.batch = int64(n) }
+}
+
+// WithRewriteThrottle pauses for d after every batch to limit the load on
+// Redis.
+func WithRewriteThrottle(d time.Duration) RewriteOption {
+	return func(o *rewriteOptions) { o.throttle = d }
+}
+
+// WithRewriteDryRun runs the function and reports what would change without
+// writing anything.
+func WithRewriteDryRun() RewriteOption {
+	return func(o *rewriteOptions) { o.dryRun = true }
+}
+
+// WithRewriteResume continues a rewrite from a cursor reported to a
+// checkpoint function. It is not supported on a cluster.
+func WithRewriteResume(cursor uint64) RewriteOption {
+	return func(o *rewriteOptions) { o.cursor = cursor }
+}
+
+// WithRewriteCheckpoint calls fn after every batch has been processed with
+// the cursor to resume from and the statistics so far. Persist the cursor to
+// make the rewrite resumable; a returned error stops it.
+func WithRewriteCheckpoint(fn func(cursor uint64, stats RewriteStats) error) RewriteOption {
+	return func(o *rewriteOptions) { o.checkpoint = fn }
+}
+
+// RewriteStats counts what Rewrite did, or would do in a dry run.
+type RewriteStats struct {
+	Scanned   int // documents read
+	Changed   int // documents whose stored encoding differs after fn
+	Unchanged int
+	Skipped   int // fn returned ErrSkip
+	Failed    int
+	Errors    []*RecordError // Record is the scan position
+}
+
+// Rewrite re-encodes every document under the repository prefix: each hash
+// is decoded into T, passed to fn and, if its encoding now differs from what
+// is stored, the differing fields are written back (fields that became zero
+// are removed). Documents are visited with SCAN in batches, each written in
+// one pipeline. Documents that fail to decode or for which fn returns an
+// error are collected in the stats and left alone.
+//
+// The version field is never written and expiry is kept. Rewrite does not
+// lock documents: a concurrent write between the read and the write-back
+// of the same fields is overwritten.
+func (r *Repository[T]) Rewrite(ctx context.Context, fn func(*T) error, opts ...RewriteOption) (RewriteStats, error) {
+	o := rewriteOptions{batch: scanCount}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.batch < 1 {
+		return RewriteStats{}, fmt.Errorf("redisft: WithRewriteBatch: batch must be positive, got %d", o.batch)
+	}
+	vs, err := versionField(reflect.TypeOf(*new(T)))
+	if err != nil {
+		return RewriteStats{}, err
+	}
+
+	var st RewriteStats
+	err = r.scanKeys(ctx, o.cursor, o.batch, func(keys []string, next uint64) error {
+		hashes, err := r.fetch(ctx, keys)
+		if err != nil {
+			return err
+		}
+		var edits []hashEdit
+		for i, m := range hashes {
+			if len(m) == 0 {
+				continue
+			}
+			st.Scanned++
+			e, err := r.rewriteOne(keys[i], m, fn, vs)
+			switch {
+			case errors.Is(err, ErrSkip):
+				st.Skipped++
+			case err != nil:
+				st.Failed++
+				st.Errors = append(st.Errors, &RecordError{Record: st.Scanned, ID: strings.TrimPrefix(keys[i], r.prefix), Err: err})
+			case e.empty():
+				st.Unchanged++
+			default:
+				st.Changed++
+				edits = append(edits, e)
+			}
+		}
+		if !o.dryRun && len(edits) > 0 {
+			if err := r.applyEdits(ctx, edits); err != nil {
+				return err
+			}
+		}
+		if o.checkpoint != nil {
+			if err := o.checkpoint(next, st); err != nil {
+				return err
+			}
+		}
+		if o.throttle > 0 && next != 0 {
+			t := time.NewTimer(o.throttle)
+			defer t.Stop()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-t.C:
+			}
+		}
+		return nil
+	})
+	return st, err
+}
+
+// hashEdit is the minimal change turning a stored hash into a new encoding.
+type hashEdit struct {
+	key string
+	set map[string]any
+	del []string
+}
+
+func (e hashEdit) empty() bool { return len(e.set) == 0 && len(e.del) == 0 }
+
+// rewriteOne decodes m, applies fn and diffs the result against m.
+func (r *Repository[T]) rewriteOne(key string, m map[string]string, fn func(*T) error, vs versionSpec) (hashEdit, error) {
+	doc, err := decodeHash[T](key, m)
+	if err != nil {
+		return hashEdit{}, err
+	}
+	if err := fn(doc); err != nil {
+		return hashEdit{}, err
+	}
+	enc, err := structToMap(doc)
+	if err != nil {
+		return hashEdit{}, err
+	}
+	e := hashEdit{key: key, set: map[string]any{}}
+	for k, v := range enc {
+		if s := hashValue(v); k != vs.name && m[k] != s {
+			e.set[k] = s
+		}
+	}
+	for _, k := range fieldNames(reflect.TypeOf(*doc)) {
+		if _, ok := enc[k]; !ok && k != vs.name {
+			if _, stored := m[k]; stored {
+				e.del = append(e.del, k)
+			}
+		}
+	}
+	return e, nil
+}
+
+func (r *Repository[T]) applyEdits(ctx context.Context, edits []hashEdit) error {
+	rc := r.cli.Get()
+	err := r.do(ctx, &CommandInfo{Op: OpRewrite}, func(ctx context.Context) error {
+		pipe := rc.Pipeline()
+		for _, e := range edits {
+			if len(e.set) > 0 {
+				pipe.HSet(ctx, e.key, e.set)
+			}
+			if len(e.del) > 0 {
+				pipe.HDel(ctx, e.key, e.del...)
+			}
+		}
+		_, err := pipe.Exec(ctx)
+		return wrapErr(err, "")
+	})
+	return r.written(ctx, err)
+}
@@ -0,0 +1,89 @@
+package redisft
+
+import (
+	"reflect"
+	"strings"
+)
+
+type FieldType string
+
+const (
+	FieldText    FieldType = "TEXT"
+	FieldNumeric FieldType = "NUMERIC"
+	FieldTag     FieldType = "TAG"
+	FieldGeo     FieldType = "GEO"
+)
+
+// fieldSpec is one indexed field as declared by a `redis:"…"` struct tag.
+type fieldSpec struct {
+	name     string
+	typ      FieldType
+	sortable bool
+}
+
+type schema []fieldSpec
+
+// metaTokens are struct tag tokens interpreted by the repository rather
+// than sent to FT.CREATE.
+var metaTokens = map[string]bool{expireToken: true, versionToken: true}
+
+// parseSchema reads the `redis` tags of t, mirroring generateIndexQuery.
+func parseSchema(t reflect.Type) schema {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var s schema
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		var toks []string
+		for _, tok := range strings.Fields(sf.Tag.Get("redis")) {
+			if tok = strings.ToUpper(tok); !metaTokens[tok] {
+				toks = append(toks, tok)
+			}
+		}
+		if len(toks) == 0 {
+			continue
+		}
+		fs := fieldSpec{name: strings.ToLower(sf.Name)}
+		for _, tok := range toks {
+			switch tok {
+			case "SORTABLE":
+				fs.sortable = true
+			case string(FieldText), string(FieldNumeric), string(FieldTag), string(FieldGeo):
+				fs.typ = FieldType(tok)
+			}
+		}
+		s = append(s, fs)
+	}
+	return s
+}
+
+func (s schema) field(name string) (fieldSpec, bool) {
+	name = strings.ToLower(strings.TrimPrefix(name, "@"))
+	for _, f := range s {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return fieldSpec{}, false
+}
+
+// SchemaField describes one indexed field of a repository.
+type SchemaField struct {
+	Name     string // hash field name, lower-case
+	Type     FieldType
+	Sortable bool
+}
+
+// Schema returns the indexed fields declared by the struct tags of T, in
+// declaration order.
+func (r *Repository[T]) Schema() []SchemaField {
+	out := make([]SchemaField, len(r.schema))
+	for i, f := range r.schema {
+		out[i] = SchemaField{Name: f.name, Type: f.typ, Sortable: f.sortable}
+	}
+	return out
+}
@@ -0,0 +1,148 @@
+package redisft
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TagValues returns the distinct values of a TAG field across the whole
+// index, sorted. RediSearch lowercases them unless the field is
+// CASESENSITIVE. The query built on r is ignored.
+func (r *Repository[T]) TagValues(ctx context.Context, field string) ([]string, error) {
+	fs, ok := r.schema.field(field)
+	if !ok {
+		return nil, fmt.Errorf("redisft: TagValues: unknown field %q", field)
+	}
+	if fs.typ != FieldTag {
+		return nil, fmt.Errorf("redisft: TagValues: field %q is %s, not TAG", field, fs.typ)
+	}
+	rc := r.cli.Get()
+	cmd := []any{"FT.TAGVALS", r.index, fs.name}
+	var raw interface{}
+	err := r.do(ctx, &CommandInfo{Op: OpTagVals, Args: cmd}, func(ctx context.Context) (err error) {
+		raw, err = ftDo(ctx, rc, r.route(), cmd...).Result()
+		return wrapErr(err, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	var vals []string
+	switch t := raw.(type) {
+	case []interface{}:
+		vals = make([]string, 0, len(t))
+		for _, v := range t {
+			vals = append(vals, replyString(v))
+		}
+	case map[interface{}]interface{}: // RESP3 set
+		vals = make([]string, 0, len(t))
+		for v := range t {
+			vals = append(vals, replyString(v))
+		}
+	default:
+		return nil, unexpectedReply("FT.TAGVALS", raw)
+	}
+	return sortedUnique(vals), nil
+}
+
+// sortedUnique sorts vals in place and drops duplicates.
+func sortedUnique(vals []string) []string {
+	sort.Strings(vals)
+	out := vals[:0]
+	for i, v := range vals {
+		if i == 0 || v != vals[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// TagValuesCache holds the values of one TAG field and refreshes them in
+// the background. It is safe for concurrent use.
+type TagValuesCache struct {
+	load func(ctx context.Context) ([]string, error)
+
+	mu   sync.RWMutex
+	vals []string
+	err  error
+	at   time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// CacheTagValues loads the values of field and reloads them every interval
+// until ctx is done or Close is called. The first load happens before it
+// returns; its error is returned. A failed refresh keeps the previous
+// values and is reported by Err.
+func (r *Repository[T]) CacheTagValues(ctx context.Context, field string, interval time.Duration) (*TagValuesCache, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("redisft: CacheTagValues: non-positive interval %v", interval)
+	}
+	c := &TagValuesCache{
+		load: func(ctx context.Context) ([]string, error) { return r.TagValues(ctx, field) },
+		done: make(chan struct{}),
+	}
+	if err := c.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	ctx, c.cancel = context.WithCancel(ctx)
+	go c.loop(ctx, interval)
+	return c, nil
+}
+
+func (c *TagValuesCache) loop(ctx context.Context, interval time.Duration) {
+	defer close(c.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			_ = c.Refresh(ctx)
+		}
+	}
+}
+
+// Refresh reloads the values now.
+func (c *TagValuesCache) Refresh(ctx context.Context) error {
+	vals, err := c.load(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	if err == nil {
+		c.vals, c.at = vals, time.Now()
+	}
+	return err
+}
+
+// Values returns the values of the last successful load. The slice must not
+// be modified.
+func (c *TagValuesCache) Values() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals
+}
+
+// Err returns the error of the last load, or nil if it succeeded.
+func (c *TagValuesCache) Err() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.err
+}
+
+// LoadedAt returns the time of the last successful load.
+func (c *TagValuesCache) LoadedAt() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.at
+}
+
+// Close stops the background refresh and waits for it to finish.
+func (c *TagValuesCache) Close() {
+	c.cancel()
+	<-c.done
+}
@@ -0,0 +1,42 @@
+package redisft
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSortedUnique(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in, want []string
+	}{
+		{[]string{"a", "a"}, []string{"a"}},
+		{[]string{"b"}, []string{"b"}},
+		{[]string{"red", "blue", "red", "green", "blue"}, []string{"blue", "green", "red"}},
+	}
+	for _, tt := range tests {
+		got := sortedUnique(append([]string(nil), tt.in...))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sortedUnique(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRepository_TagValuesValidation(t *testing.T) {
+	t.Parallel()
+	repo := NewRepo[product](&Client{})
+	ctx := context.Background()
+	for _, field := range []string{"missing", "price", "name"} {
+		if _, err := repo.TagValues(ctx, field); err == nil {
+			t.Errorf("TagValues(%q): expected error", field)
+		}
+	}
+	if _, err := repo.CacheTagValues(ctx, "color", 0); err == nil {
+		t.Errorf("CacheTagValues(0): expected error")
+	}
+	if _, err := repo.CacheTagValues(ctx, "price", time.Minute); err == nil {
+		t.Errorf("CacheTagValues(price): expected error")
+	}
+}
@@ -0,0 +1,213 @@
+package redisft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrTxConflict is returned by RunTx when a watched key kept changing until
+// the retries ran out.
+var ErrTxConflict = errors.New("redisft: transaction aborted: watched key changed")
+
+// Tx is a unit of work passed to the function given to RunTx. Writes queued
+// through InsertTx, UpdateTx and DeleteTx are sent in one MULTI/EXEC when the
+// function returns; reads through GetTx and WatchTx WATCH the keys they
+// touch, so the commit fails if another client changes them first.
+//
+// In Redis Cluster all keys of a transaction must share a slot, which in
+// practice limits cross-repository transactions to single-node deployments.
+type Tx struct {
+	cli    *Client
+	rtx    *redis.Tx
+	ops    []txOp
+	caches map[*ResultCache]bool
+}
+
+// txOp is one queued write: a document write, or the deletion of del when w
+// is nil. Ops are sent in the order they were queued.
+type txOp struct {
+	w   *docWrite
+	del string
+}
+
+// TxOption configures RunTx.
+type TxOption func(*txConfig)
+
+type txConfig struct{ retries int }
+
+// WithTxRetries sets how many times RunTx re-runs the function after a
+// watched key changed. The default is 3; 0 disables retries.
+func WithTxRetries(n int) TxOption {
+	return func(c *txConfig) { c.retries = max(n, 0) }
+}
+
+// RunTx calls fn with a fresh Tx and commits the writes it queued. If a key
+// read or watched in fn changed before the commit, fn runs again, up to the
+// configured number of retries, after which ErrTxConflict is returned. An
+// error returned by fn aborts the transaction without writing anything.
+func (c *Client) RunTx(ctx context.Context, fn func(ctx context.Context, tx *Tx) error, opts ...TxOption) error {
+	cfg := txConfig{retries: 3}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	rc := c.Get()
+	w, ok := rc.(watcher)
+	if !ok {
+		return fmt.Errorf("redisft: RunTx: %T does not support transactions", rc)
+	}
+	for attempt := 0; ; attempt++ {
+		var tx *Tx
+		info := &CommandInfo{Op: OpTx}
+		err := c.do(ctx, info, func(ctx context.Context) error {
+			return w.Watch(ctx, func(rtx *redis.Tx) error {
+				tx = &Tx{cli: c, rtx: rtx, caches: map[*ResultCache]bool{}}
+				if err := fn(ctx, tx); err != nil {
+					return err
+				}
+				info.versioned = info.versioned || tx.versioned()
+				return tx.commit(ctx)
+			})
+		})
+		if errors.Is(err, redis.TxFailedErr) {
+			if attempt < cfg.retries {
+				continue
+			}
+			return fmt.Errorf("%w (after %d attempts)", ErrTxConflict, attempt+1)
+		}
+		if tx != nil {
+			err = tx.finish(ctx, err)
+		}
+		return err
+	}
+}
+
+// versioned reports whether the queued writes increment a version field.
+func (tx *Tx) versioned() bool {
+	for _, op := range tx.ops {
+		if op.w != nil && op.w.ver.ok() {
+			return true
+		}
+	}
+	return false
+}
+
+func (tx *Tx) commit(ctx context.Context) error {
+	if len(tx.ops) == 0 {
+		return nil
+	}
+	_, err := tx.rtx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, op := range tx.ops {
+			if op.w == nil {
+				pipe.Del(ctx, op.del)
+				continue
+			}
+			op.w.queue(ctx, pipe)
+		}
+		return nil
+	})
+	if errors.Is(err, redis.TxFailedErr) {
+		return err
+	}
+	return wrapErr(err, "")
+}
+
+// finish writes new versions back after a commit and clears the caches of
+// every repository the transaction wrote to.
+func (tx *Tx) finish(ctx context.Context, err error) error {
+	if err == nil {
+		for _, op := range tx.ops {
+			if op.w != nil {
+				op.w.done()
+			}
+		}
+	}
+	if len(tx.ops) == 0 {
+		return err
+	}
+	for c := range tx.caches {
+		if perr := c.invalidate(ctx, tx.cli.Get()); err == nil && perr != nil {
+			err = fmt.Errorf("%w: %w", ErrCacheBroadcast, perr)
+		}
+	}
+	return err
+}
+
+func (r *Repository[T]) checkTx(tx *Tx) error {
+	if tx == nil || tx.rtx == nil {
+		return errors.New("redisft: Tx used outside RunTx")
+	}
+	if tx.cli != r.cli {
+		return errors.New("redisft: repository belongs to a different Client than the Tx")
+	}
+	if r.cache != nil {
+		tx.caches[r.cache] = true
+	}
+	return nil
+}
+
+// InsertTx queues an Insert of doc into tx.
+func (r *Repository[T]) InsertTx(tx *Tx, id string, doc *T, opts ...InsertOption) error {
+	if err := r.checkTx(tx); err != nil {
+		return err
+	}
+	o, err := newInsertOptions(opts)
+	if err != nil {
+		return err
+	}
+	w, err := r.newWrite(id, doc, &o)
+	if err != nil {
+		return err
+	}
+	tx.ops = append(tx.ops, txOp{w: w})
+	return nil
+}
+
+// UpdateTx queues an Update of document id into tx.
+func (r *Repository[T]) UpdateTx(tx *Tx, id string, patch T) error {
+	if err := r.checkTx(tx); err != nil {
+		return err
+	}
+	w, err := r.newWrite(id, patch, nil)
+	if err != nil {
+		return err
+	}
+	tx.ops = append(tx.ops, txOp{w: w})
+	return nil
+}
+
+// DeleteTx queues the deletion of document id into tx.
+func (r *Repository[T]) DeleteTx(tx *Tx, id string) error {
+	if err := r.checkTx(tx); err != nil {
+		return err
+	}
+	tx.ops = append(tx.ops, txOp{del: r.key(id)})
+	return nil
+}
+
+// WatchTx makes tx fail on commit if any of the documents changes first.
+func (r *Repository[T]) WatchTx(ctx context.Context, tx *Tx, ids ...string) error {
+	if err := r.checkTx(tx); err != nil {
+		return err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = r.key(id)
+	}
+	return wrapErr(tx.rtx.Watch(ctx, keys...).Err(), "")
+}
+
+// GetTx watches and loads document id inside tx.
+func (r *Repository[T]) GetTx(ctx context.Context, tx *Tx, id string) (*T, error) {
+	if err := r.WatchTx(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	key := r.key(id)
+	m, err := tx.rtx.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, wrapErr(err, "")
+	}
+	return decodeHash[T](key, m)
+}
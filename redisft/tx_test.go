@@ -0,0 +1,15 @@
+package redisft
+
+import "testing"
+
+func TestTx_Misuse(t *testing.T) {
+	t.Parallel()
+	a, b := &Client{}, &Client{}
+	r := NewRepo[product](a)
+	if err := r.DeleteTx(nil, "1"); err == nil {
+		t.Error("nil Tx: expected error")
+	}
+	if err := r.DeleteTx(&Tx{cli: b}, "1"); err == nil {
+		t.Error("Tx outside RunTx: expected error")
+	}
+}
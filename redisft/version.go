@@ -0,0 +1,115 @@
+package redisft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// versionToken marks an integer field as the document version:
+//
+//	Version int64 `redis:"numeric version"`
+//
+// Insert, InsertMany and Update increment it atomically (HINCRBY) and write
+// the new value back into the struct where they can; CompareAndUpdate only
+// applies a patch when it still matches. Like `expire`, the token is not
+// sent to FT.CREATE.
+const versionToken = "VERSION"
+
+// versionSpec locates the version field of a document type.
+type versionSpec struct {
+	index int    // -1 when the type is not versioned
+	name  string // hash field name
+}
+
+func (v versionSpec) ok() bool { return v.index >= 0 }
+
+// set stores n into the version field of doc, which must be a pointer.
+func (v versionSpec) set(doc any, n int64) {
+	f := reflect.ValueOf(doc).Elem().Field(v.index)
+	switch f.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f.SetUint(uint64(n))
+	default:
+		f.SetInt(n)
+	}
+}
+
+func versionField(t reflect.Type) (versionSpec, error) {
+	sf, i := taggedField(t, versionToken)
+	if i < 0 {
+		return versionSpec{index: -1}, nil
+	}
+	switch sf.Type.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return versionSpec{index: i, name: strings.ToLower(sf.Name)}, nil
+	}
+	return versionSpec{index: -1}, fmt.Errorf("redisft: version tag on %s: field must be an integer, is %s", sf.Name, sf.Type)
+}
+
+type watcher interface {
+	Watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error
+}
+
+// CompareAndUpdate applies patch to document id only if its stored version
+// equals expected, and sets the version to expected+1, which it returns.
+// The check and the write run atomically under WATCH/MULTI. A mismatch, or a
+// concurrent write between the two, returns ErrVersionConflict; a missing
+// document returns ErrNotFound. The version field of patch is ignored.
+func (r *Repository[T]) CompareAndUpdate(ctx context.Context, id string, expected int64, patch T) (int64, error) {
+	vs, err := versionField(reflect.TypeOf(patch))
+	if err != nil {
+		return 0, err
+	}
+	if !vs.ok() {
+		return 0, fmt.Errorf("redisft: CompareAndUpdate: %T has no version field", patch)
+	}
+	data, err := structToMap(patch)
+	if err != nil {
+		return 0, err
+	}
+	rc := r.cli.Get()
+	w, ok := rc.(watcher)
+	if !ok {
+		return 0, fmt.Errorf("redisft: CompareAndUpdate: %T does not support WATCH", rc)
+	}
+	key, field, next := r.key(id), vs.name, expected+1
+	data[field] = next
+
+	err = r.do(ctx, &CommandInfo{Op: OpCompareAndUpdate}, func(ctx context.Context) error {
+		err := w.Watch(ctx, func(tx *redis.Tx) error {
+			cur, err := tx.HGet(ctx, key, field).Int64()
+			if errors.Is(err, redis.Nil) {
+				n, err := tx.Exists(ctx, key).Result()
+				if err != nil {
+					return wrapErr(err, "")
+				}
+				if n == 0 {
+					return fmt.Errorf("%w: %s", ErrNotFound, key)
+				}
+			} else if err != nil {
+				return wrapErr(err, "")
+			}
+			if cur != expected {
+				return fmt.Errorf("%w: %s is at version %d, expected %d", ErrVersionConflict, key, cur, expected)
+			}
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				p.HSet(ctx, key, data)
+				return nil
+			})
+			return err
+		}, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			return fmt.Errorf("%w: %s changed concurrently", ErrVersionConflict, key)
+		}
+		return err
+	})
+	if err = r.written(ctx, err); err != nil {
+		return 0, err
+	}
+	return next, nil
+}
@@ -0,0 +1,48 @@
+package redisft
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type account struct {
+	Owner   string `redis:"tag"`
+	Balance int64  `redis:"numeric"`
+	Version int64  `redis:"numeric sortable version"`
+}
+
+func TestVersionField(t *testing.T) {
+	t.Parallel()
+	vs, err := versionField(reflect.TypeOf(account{}))
+	if err != nil || vs.name != "version" || vs.index != 2 {
+		t.Fatalf("versionField = %+v, %v", vs, err)
+	}
+	a := &account{}
+	vs.set(a, 7)
+	if a.Version != 7 {
+		t.Errorf("set: Version = %d", a.Version)
+	}
+	if vs, _ := versionField(reflect.TypeOf(product{})); vs.ok() {
+		t.Error("product is not versioned")
+	}
+	type bad struct {
+		V string `redis:"version"`
+	}
+	if _, err := versionField(reflect.TypeOf(bad{})); err == nil {
+		t.Error("string version field: expected error")
+	}
+
+	got := fmt.Sprint(indexQuery("idx:account", "account:", reflect.TypeOf(account{})))
+	if want := "[idx:account ON HASH PREFIX 1 account: SCHEMA owner TAG balance NUMERIC version NUMERIC SORTABLE]"; got != want {
+		t.Errorf("indexQuery = %s", got)
+	}
+}
+
+func TestCompareAndUpdate_Unversioned(t *testing.T) {
+	t.Parallel()
+	if _, err := NewRepo[product](&Client{}).CompareAndUpdate(context.Background(), "1", 1, product{}); err == nil {
+		t.Error("expected error for type without version field")
+	}
+}
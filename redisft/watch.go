@@ -0,0 +1,285 @@
+package redisft
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// EventType is the kind of change reported by Watch.
+type EventType int
+
+const (
+	EventCreated EventType = iota + 1
+	EventUpdated
+	EventDeleted
+	EventExpired
+	// EventResync is sent after the subscription was re-established
+	// following a connection loss. Changes in between were missed; Err holds
+	// the error that broke the connection.
+	EventResync
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventCreated:
+		return "created"
+	case EventUpdated:
+		return "updated"
+	case EventDeleted:
+		return "deleted"
+	case EventExpired:
+		return "expired"
+	case EventResync:
+		return "resync"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event is one document change reported by Watch.
+type Event[T any] struct {
+	Type EventType
+	ID   string
+	// Doc is the current document for Created and Updated events when
+	// WithWatchDocuments is set. It is loaded after the notification
+	// arrives, so it may already include later changes.
+	Doc *T
+	// Err is set when loading Doc failed, and on EventResync.
+	Err error
+}
+
+// WatchOption customises Watch.
+type WatchOption func(*watchOptions)
+
+type watchOptions struct {
+	docs   bool
+	buffer int
+}
+
+// WithWatchDocuments loads the document for every Created and Updated event.
+func WithWatchDocuments() WatchOption {
+	return func(o *watchOptions) { o.docs = true }
+}
+
+// WithWatchBuffer sets the capacity of the event channel. Default 64.
+func WithWatchBuffer(n int) WatchOption {
+	return func(o *watchOptions) { o.buffer = n }
+}
+
+const (
+	watchMinBackoff = 100 * time.Millisecond
+	watchMaxBackoff = 5 * time.Second
+)
+
+// keyspaceFlags are the notify-keyspace-events classes Watch relies on:
+// keyspace channel, generic, hash, expired and evicted events. keyspaceNew
+// ("new key", Redis 7+) lets Watch tell creations from updates.
+const (
+	keyspaceFlags = "Kghxe"
+	keyspaceNew   = "n"
+)
+
+type patternSubscriber interface {
+	PSubscribe(ctx context.Context, patterns ...string) *redis.PubSub
+}
+
+// Watch subscribes to keyspace notifications for the repository prefix and
+// sends an Event for every document change until ctx is done, when the
+// channel is closed. Notifications must be enabled on the server (see
+// EnableKeyspaceNotifications); without the "new key" class (Redis < 7)
+// creations are reported as EventUpdated. A single write may produce
+// several events, e.g. an Insert that also sets a TTL or a version.
+//
+// Lost connections are re-established with backoff, after which an
+// EventResync is sent. Events are dropped only if ctx is done; a slow
+// consumer blocks the subscription.
+func (r *Repository[T]) Watch(ctx context.Context, opts ...WatchOption) (<-chan Event[T], error) {
+	o := watchOptions{buffer: 64}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.buffer < 0 {
+		return nil, fmt.Errorf("redisft: WithWatchBuffer: negative buffer %d", o.buffer)
+	}
+
+	rc := r.cli.Get()
+	var nodes []patternSubscriber
+	if cc, ok := rc.(*redis.ClusterClient); ok {
+		masters, err := clusterMasters(ctx, cc)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range masters {
+			nodes = append(nodes, m)
+		}
+	} else if ps, ok := rc.(patternSubscriber); ok {
+		nodes = append(nodes, ps)
+	} else {
+		return nil, fmt.Errorf("redisft: Watch: %T does not support pub/sub", rc)
+	}
+
+	pattern := "__keyspace@*__:" + globEscape(r.prefix) + "*"
+	subs := make([]*redis.PubSub, 0, len(nodes))
+	closeAll := func() {
+		for _, s := range subs {
+			s.Close()
+		}
+	}
+	for _, n := range nodes {
+		sub := n.PSubscribe(ctx, pattern)
+		subs = append(subs, sub)
+		// Wait for the confirmation so no change made after Watch returns
+		// can be missed.
+		if _, err := sub.Receive(ctx); err != nil {
+			closeAll()
+			return nil, wrapErr(err, "")
+		}
+	}
+
+	out := make(chan Event[T], o.buffer)
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub *redis.PubSub) {
+			defer wg.Done()
+			r.watchLoop(ctx, sub, o, out)
+		}(sub)
+	}
+	go func() {
+		<-ctx.Done()
+		closeAll()
+		wg.Wait()
+		close(out)
+	}()
+	return out, nil
+}
+
+// watchLoop turns the notifications of one subscription into events.
+func (r *Repository[T]) watchLoop(ctx context.Context, sub *redis.PubSub, o watchOptions, out chan<- Event[T]) {
+	emit := func(ev Event[T]) bool {
+		select {
+		case out <- ev:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	created := map[string]bool{} // keys with a pending "new" notification
+	backoff := watchMinBackoff
+	var lost error
+	for {
+		msg, err := sub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// go-redis reconnects and resubscribes on the next Receive.
+			if lost == nil {
+				lost = wrapErr(err, "")
+			}
+			t := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+			backoff = min(2*backoff, watchMaxBackoff)
+			continue
+		}
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if lost != nil {
+				clear(created)
+				if !emit(Event[T]{Type: EventResync, Err: lost}) {
+					return
+				}
+				lost, backoff = nil, watchMinBackoff
+			}
+		case *redis.Message:
+			ev, ok := r.keyspaceEvent(m, created)
+			if !ok {
+				continue
+			}
+			if o.docs && (ev.Type == EventCreated || ev.Type == EventUpdated) {
+				ev.Doc, ev.Err = r.Get(ctx, ev.ID)
+			}
+			if !emit(ev) {
+				return
+			}
+		}
+	}
+}
+
+// keyspaceEvent maps a keyspace notification to an Event. It reports false
+// for notifications that are not document changes (e.g. "expire").
+func (r *Repository[T]) keyspaceEvent(m *redis.Message, created map[string]bool) (Event[T], bool) {
+	i := strings.Index(m.Channel, "__:")
+	if i < 0 {
+		return Event[T]{}, false
+	}
+	key := m.Channel[i+3:]
+	ev := Event[T]{ID: strings.TrimPrefix(key, r.prefix)}
+	switch m.Payload {
+	case "new":
+		created[key] = true
+		return ev, false
+	case "hset", "hsetnx", "hdel", "hincrby", "hincrbyfloat":
+		ev.Type = EventUpdated
+		if created[key] {
+			ev.Type = EventCreated
+			delete(created, key)
+		}
+	case "rename_to":
+		ev.Type = EventCreated
+	case "del", "evicted", "rename_from":
+		ev.Type = EventDeleted
+		delete(created, key)
+	case "expired":
+		ev.Type = EventExpired
+		delete(created, key)
+	default:
+		return ev, false
+	}
+	return ev, true
+}
+
+// EnableKeyspaceNotifications adds the notify-keyspace-events classes Watch
+// needs to the server configuration, keeping those already enabled. On a
+// cluster every master is configured. It fails where CONFIG is not
+// permitted, as on many managed services; enable the classes "Kghxen" there
+// by other means.
+func (c *Client) EnableKeyspaceNotifications(ctx context.Context) error {
+	rc := c.Get()
+	cur, err := rc.Do(ctx, "CONFIG", "GET", "notify-keyspace-events").StringSlice()
+	if err != nil {
+		return fmt.Errorf("redisft: enabling keyspace notifications: %w", wrapErr(err, ""))
+	}
+	flags := ""
+	if len(cur) == 2 {
+		flags = cur[1]
+	}
+	err = ftBroadcast(ctx, rc, nil, "CONFIG", "SET", "notify-keyspace-events", mergeFlags(flags, keyspaceFlags+keyspaceNew))
+	if err != nil && strings.Contains(err.Error(), "Invalid argument") {
+		// Before Redis 7 there is no "new key" class.
+		err = ftBroadcast(ctx, rc, nil, "CONFIG", "SET", "notify-keyspace-events", mergeFlags(flags, keyspaceFlags))
+	}
+	if err != nil {
+		return fmt.Errorf("redisft: enabling keyspace notifications: %w", err)
+	}
+	return nil
+}
+
+// mergeFlags appends the flags of add missing from cur.
+func mergeFlags(cur, add string) string {
+	for _, f := range add {
+		if !strings.ContainsRune(cur, f) {
+			cur += string(f)
+		}
+	}
+	return cur
+}
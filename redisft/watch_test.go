@@ -0,0 +1,51 @@
+package redisft
+
+import (
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestKeyspaceEvent(t *testing.T) {
+	t.Parallel()
+	r := NewRepo[product](NewClientFromPool(nil))
+	created := map[string]bool{}
+	tests := []struct {
+		channel, payload string
+		want             EventType // 0 means no event
+		id               string
+	}{
+		{"__keyspace@0__:product:1", "hset", EventUpdated, "1"},
+		{"__keyspace@0__:product:2", "new", 0, ""},
+		{"__keyspace@0__:product:2", "hset", EventCreated, "2"},
+		{"__keyspace@0__:product:2", "hincrby", EventUpdated, "2"},
+		{"__keyspace@0__:product:2", "expire", 0, ""},
+		{"__keyspace@0__:product:2", "expired", EventExpired, "2"},
+		{"__keyspace@3__:product:3", "del", EventDeleted, "3"},
+		{"__keyspace@0__:product:4", "rename_to", EventCreated, "4"},
+		{"garbage", "hset", 0, ""},
+	}
+	for _, tt := range tests {
+		ev, ok := r.keyspaceEvent(&redis.Message{Channel: tt.channel, Payload: tt.payload}, created)
+		if ok != (tt.want != 0) || ok && (ev.Type != tt.want || ev.ID != tt.id) {
+			t.Errorf("%s %s: got %v %+v, want %s %q", tt.channel, tt.payload, ok, ev, tt.want, tt.id)
+		}
+	}
+	if len(created) != 0 {
+		t.Errorf("pending creations = %v", created)
+	}
+}
+
+func TestMergeFlags(t *testing.T) {
+	t.Parallel()
+	tests := []struct{ cur, add, want string }{
+		{"", "Kghxe", "Kghxe"},
+		{"AKE", "Kghxen", "AKEghxen"},
+		{"Kghxe", "Kghxe", "Kghxe"},
+	}
+	for _, tt := range tests {
+		if got := mergeFlags(tt.cur, tt.add); got != tt.want {
+			t.Errorf("mergeFlags(%q, %q) = %q, want %q", tt.cur, tt.add, got, tt.want)
+		}
+	}
+}